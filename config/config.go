@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"reflect"
 	"strings"
@@ -11,33 +12,94 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 )
 
-var ErrInvalidSessionSecretLength = errors.New("session secret must be 16, 24, or 32 bytes")
+var (
+	ErrInvalidSessionSecretLength = errors.New("session secret must be 16, 24, or 32 bytes")
+	ErrJWTSigningKeyRequired      = errors.New("either JWT_SIGN_SECRET or JWT_KEYS_FILE is required")
+	ErrAdminKeyRequired           = errors.New("one of ADMIN_TOKEN, ADMIN_KEYS_FILE or ADMIN_OIDC_ISSUER is required")
+	ErrRedirectNotSupported       = errors.New("redirect media delivery mode is not supported by the local storage backend")
+	ErrInvalidUserTokenTTL        = errors.New("USER_TOKEN_DEFAULT_TTL must be positive and not longer than USER_TOKEN_MAX_TTL")
+	ErrOIDCAudienceRequired       = errors.New("ADMIN_OIDC_AUDIENCE is required when ADMIN_OIDC_ISSUER is set")
+)
 
 type Config struct {
 	ServerPort string `env:"SERVER_PORT" envDefault:"8080"`
+	// /metricsはテナントIDをラベルに含むため、公開するポートとは分ける
+	MetricsPort string `env:"METRICS_PORT" envDefault:"9090"`
 
 	// ------------------------ Log ------------------------
 	LogLevel slog.Level `env:"LOG_LEVEL"`
 	LogType  LogType    `env:"LOG_TYPE" envDefault:"json"`
 
 	// ------------------------ Application ------------------------
-	AdminToken    AdminToken    `env:"ADMIN_TOKEN,required"`
+	// 全てのスコープを持つ "default" という名前のキーとして扱う
+	AdminToken    AdminToken    `env:"ADMIN_TOKEN"`
+	AdminKeysFile AdminKeysFile `env:"ADMIN_KEYS_FILE"`
 	MaxUploadSize uint64        `env:"MAX_UPLOAD_SIZE" envDefault:"1073741824"` //1GB
 	EncodeTimeout time.Duration `env:"ENCODE_TIMEOUT" envDefault:"1h"`
-	JWTSigningKey JWTSigningKey `env:"JWT_SIGN_SECRET,required"`
+	// 終了時に実行中のリクエストとエンコードを待つ時間。超えたエンコードは中断して他のホストに任せる
+	// 中断後の後始末に最大10s掛かるため、k8sのterminationGracePeriodSeconds (既定30s) より10s以上短くする
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s"`
+	JWTSigningKey   JWTSigningKey `env:"JWT_SIGN_SECRET"`
+	JWTKeysFile     JWTKeysFile   `env:"JWT_KEYS_FILE"`
+	// 外部で発行されたトークンを受け入れる場合の発行者の設定
+	TrustedIssuersFile TrustedIssuersFile `env:"TRUSTED_ISSUERS_FILE"`
+	// トークンのgrantで "group:<name>" として参照されるメディアのグループ
+	MediaGroupsFile MediaGroupsFile `env:"MEDIA_GROUPS_FILE"`
+	// 環境変数で設定されたdefaultテナント以外のテナント
+	TenantsFile TenantsFile `env:"TENANTS_FILE"`
+
+	// ------------------------ Admin OIDC ------------------------
+	AdminOIDCConfig AdminOIDCConfig `envPrefix:"ADMIN_OIDC_"`
+
+	// ------------------------ User Token ------------------------
+	UserTokenConfig UserTokenConfig `envPrefix:"USER_TOKEN_"`
+
+	// ------------------------ User Session ------------------------
+	UserSessionConfig UserSessionConfig `envPrefix:"USER_SESSION_"`
+
+	// ------------------------ Media Delivery ------------------------
+	MediaDeliveryConfig MediaDeliveryConfig `envPrefix:"MEDIA_DELIVERY_"`
+
+	// ------------------------ Usage ------------------------
+	UsageConfig UsageConfig `envPrefix:"USAGE_"`
+
+	// ------------------------ Source ------------------------
+	SourceConfig SourceConfig `envPrefix:"SOURCE_"`
+
+	// ------------------------ Readiness ------------------------
+	ReadinessConfig ReadinessConfig `envPrefix:"READINESS_"`
+
+	// ------------------------ Janitor ------------------------
+	JanitorConfig JanitorConfig `envPrefix:"JANITOR_"`
 
 	// ------------------------ FFmpeg ------------------------
 	FFmpegConfig FFmpegConfig `envPrefix:"FFMPEG_"`
 
+	// ------------------------ Storage ------------------------
+	StorageBackend StorageBackend `env:"STORAGE_BACKEND" envDefault:"s3"`
+	// localの場合、バケットはこのディレクトリの下のディレクトリになる
+	LocalStorageDir string `env:"LOCAL_STORAGE_DIR" envDefault:"/var/lib/mpeg-dash-encoder"`
+
 	// ------------------------ MinIO ------------------------
-	MinIOEndpoint       string `env:"MINIO_ENDPOINT" envDefault:"localhost:9000"`
-	MinIOAccessKey      string `env:"MINIO_ACCESS_KEY,required"`
-	MinIOSecretKey      string `env:"MINIO_SECRET_KEY,required"`
-	MinIOUseSSL         bool   `env:"MINIO_USE_SSL" envDefault:"false"`
-	MinIOPublicEndpoint string `env:"MINIO_PUBLIC_ENDPOINT" envDefault:""` // localhost:9000
+	// S3互換のストレージ。AWSの場合は "s3.<region>.amazonaws.com"
+	MinIOEndpoint string `env:"MINIO_ENDPOINT" envDefault:"localhost:9000"`
+	// 空の場合は環境変数 (AWS_ACCESS_KEY_ID など) やIAMロールの認証情報を使う
+	MinIOAccessKey      string            `env:"MINIO_ACCESS_KEY"`
+	MinIOSecretKey      string            `env:"MINIO_SECRET_KEY"`
+	MinIOUseSSL         bool              `env:"MINIO_USE_SSL" envDefault:"false"`
+	MinIOPublicEndpoint string            `env:"MINIO_PUBLIC_ENDPOINT" envDefault:""` // localhost:9000
+	MinIOPublicUseSSL   *bool             `env:"MINIO_PUBLIC_USE_SSL"`                // 未設定の場合はMINIO_USE_SSLに従う
+	MinIORegion         string            `env:"MINIO_REGION" envDefault:"us-east-1"`
+	MinIOBucketLookup   MinIOBucketLookup `env:"MINIO_BUCKET_LOOKUP" envDefault:"auto"`
 
 	MinIOSourceUploadBucket SourceClientBucketName  `env:"MINIO_SOURCE_UPLOAD_BUCKET" envDefault:"mpeg-dash-encoder-source-upload"`
 	MinIOOutputBucket       EncodedObjectBucketName `env:"MINIO_OUTPUT_BUCKET" envDefault:"mpeg-dash-encoder-output"`
+	MinIOSystemBucket       SystemBucketName        `env:"MINIO_SYSTEM_BUCKET" envDefault:"mpeg-dash-encoder-system"`
+	// defaultテナントのプレフィックス。他のテナントとバケットを共有する場合に設定する
+	MinIOSourcePrefix string `env:"MINIO_SOURCE_PREFIX"`
+	MinIOOutputPrefix string `env:"MINIO_OUTPUT_PREFIX"`
+
+	MinIOUploadConfig MinIOUploadConfig `envPrefix:"MINIO_UPLOAD_"`
 }
 
 func Load() (Config, error) {
@@ -45,16 +107,45 @@ func Load() (Config, error) {
 	//nolint:exhaustruct
 	if err := env.ParseWithOptions(&cfg, env.Options{
 		FuncMap: map[reflect.Type]env.ParserFunc{
-			reflect.TypeOf(slog.Level(0)):    returnAny(ParseLogLevel),
-			reflect.TypeOf(time.Duration(0)): returnAny(time.ParseDuration),
-			reflect.TypeOf(LogType("")):      returnAny(ParseLogType),
+			reflect.TypeOf(slog.Level(0)):         returnAny(ParseLogLevel),
+			reflect.TypeOf(time.Duration(0)):      returnAny(time.ParseDuration),
+			reflect.TypeOf(LogType("")):           returnAny(ParseLogType),
+			reflect.TypeOf(MediaDeliveryMode("")): returnAny(ParseMediaDeliveryMode),
+			reflect.TypeOf(StorageBackend("")):    returnAny(ParseStorageBackend),
+			reflect.TypeOf(SourceReadMode("")):    returnAny(ParseSourceReadMode),
+			reflect.TypeOf(MinIOBucketLookup("")): returnAny(ParseMinIOBucketLookup),
 		},
 	}); err != nil {
 		return Config{}, err
 	}
+
+	if cfg.JWTSigningKey == "" && cfg.JWTKeysFile == "" {
+		return Config{}, ErrJWTSigningKeyRequired
+	}
+	if cfg.AdminToken == "" && cfg.AdminKeysFile == "" && cfg.AdminOIDCConfig.Issuer == "" {
+		return Config{}, ErrAdminKeyRequired
+	}
+	// 同じIdPが他のクライアント向けに発行したトークンを受け入れないようにする
+	if cfg.AdminOIDCConfig.Issuer != "" && cfg.AdminOIDCConfig.Audience == "" {
+		return Config{}, ErrOIDCAudienceRequired
+	}
+	if cfg.StorageBackend == StorageBackendLocal && cfg.MediaDeliveryConfig.usesRedirect() {
+		return Config{}, ErrRedirectNotSupported
+	}
+	if cfg.UserTokenConfig.DefaultTTL <= 0 || cfg.UserTokenConfig.DefaultTTL > cfg.UserTokenConfig.MaxTTL {
+		return Config{}, ErrInvalidUserTokenTTL
+	}
 	return cfg, nil
 }
 
+// MinIOPublicSecure reports whether the public endpoint is accessed over TLS.
+func (c Config) MinIOPublicSecure() bool {
+	if c.MinIOPublicUseSSL != nil {
+		return *c.MinIOPublicUseSSL
+	}
+	return c.MinIOUseSSL
+}
+
 func returnAny[T any](f func(v string) (t T, err error)) func(v string) (any, error) {
 	return func(v string) (any, error) {
 		t, err := f(v)
@@ -98,3 +189,83 @@ func ParseLogType(v string) (LogType, error) {
 		return LogTypeJSON, nil
 	}
 }
+
+type MediaDeliveryMode string
+
+const (
+	MediaDeliveryModeProxy    MediaDeliveryMode = "proxy"
+	MediaDeliveryModeRedirect MediaDeliveryMode = "redirect"
+)
+
+func ParseMediaDeliveryMode(v string) (MediaDeliveryMode, error) {
+	switch strings.ToLower(v) {
+	case "", "proxy":
+		return MediaDeliveryModeProxy, nil
+	case "redirect":
+		return MediaDeliveryModeRedirect, nil
+	default:
+		return "", fmt.Errorf("invalid media delivery mode: %s", v)
+	}
+}
+
+type SourceReadMode string
+
+const (
+	SourceReadModeDownload SourceReadMode = "download"
+	SourceReadModeStream   SourceReadMode = "stream"
+)
+
+func ParseSourceReadMode(v string) (SourceReadMode, error) {
+	switch strings.ToLower(v) {
+	case "", "download":
+		return SourceReadModeDownload, nil
+	case "stream":
+		return SourceReadModeStream, nil
+	default:
+		return "", fmt.Errorf("invalid source read mode: %s", v)
+	}
+}
+
+type StorageBackend string
+
+const (
+	// StorageBackendS3 is MinIO or any S3 compatible storage.
+	StorageBackendS3    StorageBackend = "s3"
+	StorageBackendLocal StorageBackend = "local"
+)
+
+func ParseStorageBackend(v string) (StorageBackend, error) {
+	switch strings.ToLower(v) {
+	case "", "s3", "minio":
+		return StorageBackendS3, nil
+	case "local":
+		return StorageBackendLocal, nil
+	default:
+		return "", fmt.Errorf("invalid storage backend: %s", v)
+	}
+}
+
+// MinIOBucketLookup is how the bucket is addressed in the request URL.
+type MinIOBucketLookup string
+
+const (
+	// MinIOBucketLookupAuto uses virtual-host style for AWS and Google Cloud Storage, and path style for others.
+	MinIOBucketLookupAuto MinIOBucketLookup = "auto"
+	// MinIOBucketLookupPath is http://endpoint/bucket/key.
+	MinIOBucketLookupPath MinIOBucketLookup = "path"
+	// MinIOBucketLookupVirtualHost is http://bucket.endpoint/key.
+	MinIOBucketLookupVirtualHost MinIOBucketLookup = "virtual-host"
+)
+
+func ParseMinIOBucketLookup(v string) (MinIOBucketLookup, error) {
+	switch strings.ToLower(v) {
+	case "", "auto":
+		return MinIOBucketLookupAuto, nil
+	case "path":
+		return MinIOBucketLookupPath, nil
+	case "virtual-host", "dns":
+		return MinIOBucketLookupVirtualHost, nil
+	default:
+		return "", fmt.Errorf("invalid minio bucket lookup: %s", v)
+	}
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"log/slog"
+	"maps"
 	"reflect"
 	"testing"
+	"time"
 
 	"dario.cat/mergo"
 	_ "github.com/joho/godotenv/autoload"
@@ -29,19 +31,22 @@ func TestLoad(t *testing.T) {
 			envs: map[string]string{},
 			//nolint:exhaustruct
 			want: Config{
-				ServerPort: "8080",
-				LogLevel:   slog.LevelInfo,
+				ServerPort:  "8080",
+				MetricsPort: "9090",
+				LogLevel:    slog.LevelInfo,
 			},
 			wantErr: false,
 		},
 		{
 			name: "normal",
 			envs: map[string]string{
-				"SERVER_PORT": "9000",
+				"SERVER_PORT":  "9000",
+				"METRICS_PORT": "9001",
 			},
 			//nolint:exhaustruct
 			want: Config{
-				ServerPort: "9000",
+				ServerPort:  "9000",
+				MetricsPort: "9001",
 			},
 			wantErr: false,
 		},
@@ -56,10 +61,228 @@ func TestLoad(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "media delivery modes",
+			envs: map[string]string{
+				"MEDIA_DELIVERY_MODES": ".mpd:proxy,.m4s:redirect",
+			},
+			//nolint:exhaustruct
+			want: Config{
+				MediaDeliveryConfig: MediaDeliveryConfig{
+					DefaultMode: MediaDeliveryModeProxy,
+					Modes: map[string]MediaDeliveryMode{
+						".mpd": MediaDeliveryModeProxy,
+						".m4s": MediaDeliveryModeRedirect,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "local storage",
+			envs: map[string]string{
+				"STORAGE_BACKEND":   "local",
+				"LOCAL_STORAGE_DIR": "/data",
+			},
+			//nolint:exhaustruct
+			want: Config{
+				StorageBackend:  StorageBackendLocal,
+				LocalStorageDir: "/data",
+			},
+			wantErr: false,
+		},
+		{
+			name: "local storage does not support redirect",
+			envs: map[string]string{
+				"STORAGE_BACKEND":      "local",
+				"MEDIA_DELIVERY_MODES": ".m4s:redirect",
+			},
+			//nolint:exhaustruct
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name: "s3 virtual-host addressing",
+			envs: map[string]string{
+				"MINIO_ENDPOINT":      "s3.ap-northeast-1.amazonaws.com",
+				"MINIO_REGION":        "ap-northeast-1",
+				"MINIO_BUCKET_LOOKUP": "virtual-host",
+			},
+			//nolint:exhaustruct
+			want: Config{
+				StorageBackend:    StorageBackendS3,
+				MinIOEndpoint:     "s3.ap-northeast-1.amazonaws.com",
+				MinIORegion:       "ap-northeast-1",
+				MinIOBucketLookup: MinIOBucketLookupVirtualHost,
+			},
+			wantErr: false,
+		},
+		{
+			name: "public endpoint over tls",
+			envs: map[string]string{
+				"MINIO_ENDPOINT":        "minio:9000",
+				"MINIO_PUBLIC_ENDPOINT": "media.example.com",
+				"MINIO_PUBLIC_USE_SSL":  "true",
+			},
+			//nolint:exhaustruct
+			want: Config{
+				MinIOEndpoint:       "minio:9000",
+				MinIOPublicEndpoint: "media.example.com",
+				MinIOPublicUseSSL:   ptr(true),
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid storage backend",
+			envs: map[string]string{
+				"STORAGE_BACKEND": "gcs",
+			},
+			//nolint:exhaustruct
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name: "stream source",
+			envs: map[string]string{
+				"SOURCE_READ_MODE":           "stream",
+				"SOURCE_DOWNLOAD_EXTENSIONS": ".mov,.avi",
+			},
+			//nolint:exhaustruct
+			want: Config{
+				SourceConfig: SourceConfig{
+					ReadMode:           SourceReadModeStream,
+					DownloadExtensions: []string{".mov", ".avi"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "source validation",
+			envs: map[string]string{
+				"SOURCE_ALLOWED_FORMATS": "mov,matroska",
+				"SOURCE_ALLOWED_CODECS":  "h264,aac",
+				"SOURCE_MAX_DURATION":    "1h",
+				"SOURCE_MAX_WIDTH":       "1920",
+				"SOURCE_MAX_HEIGHT":      "1080",
+			},
+			//nolint:exhaustruct
+			want: Config{
+				SourceConfig: SourceConfig{
+					ReadMode:       SourceReadModeDownload,
+					AllowedFormats: []string{"mov", "matroska"},
+					AllowedCodecs:  []string{"h264", "aac"},
+					MaxDuration:    time.Hour,
+					MaxWidth:       1920,
+					MaxHeight:      1080,
+					MinStreams:     1,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid source read mode",
+			envs: map[string]string{
+				"SOURCE_READ_MODE": "mmap",
+			},
+			//nolint:exhaustruct
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name: "user token default ttl is longer than max ttl",
+			envs: map[string]string{
+				"USER_TOKEN_DEFAULT_TTL": "48h",
+				"USER_TOKEN_MAX_TTL":     "24h",
+			},
+			//nolint:exhaustruct
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name: "jwt signing key is missing",
+			envs: map[string]string{
+				"JWT_SIGN_SECRET": "",
+			},
+			//nolint:exhaustruct
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name: "jwt keys file",
+			envs: map[string]string{
+				"JWT_SIGN_SECRET": "",
+				"JWT_KEYS_FILE":   "/etc/mpeg-dash-encoder/keys.json",
+			},
+			//nolint:exhaustruct
+			want: Config{
+				JWTKeysFile: "/etc/mpeg-dash-encoder/keys.json",
+			},
+			wantErr: false,
+		},
+		{
+			name: "admin key is missing",
+			envs: map[string]string{
+				"ADMIN_TOKEN": "",
+			},
+			//nolint:exhaustruct
+			want:    Config{},
+			wantErr: true,
+		},
+		{
+			name: "admin keys file",
+			envs: map[string]string{
+				"ADMIN_TOKEN":     "",
+				"ADMIN_KEYS_FILE": "/etc/mpeg-dash-encoder/admin_keys.json",
+			},
+			//nolint:exhaustruct
+			want: Config{
+				AdminKeysFile: "/etc/mpeg-dash-encoder/admin_keys.json",
+			},
+			wantErr: false,
+		},
+		{
+			name: "admin oidc",
+			envs: map[string]string{
+				"ADMIN_TOKEN":              "",
+				"ADMIN_OIDC_ISSUER":        "https://accounts.example.com",
+				"ADMIN_OIDC_GROUP_SCOPES":  "admins:issue-tokens|manage-jobs,viewers:read-only",
+				"ADMIN_OIDC_GROUP_TENANTS": "viewers:team-a",
+				"ADMIN_OIDC_GROUPS_CLAIM":  "roles",
+				"ADMIN_OIDC_AUDIENCE":      "mpeg-dash-encoder",
+			},
+			//nolint:exhaustruct
+			want: Config{
+				AdminOIDCConfig: AdminOIDCConfig{
+					Issuer:   "https://accounts.example.com",
+					Audience: "mpeg-dash-encoder",
+					GroupScopes: map[string]string{
+						"admins":  "issue-tokens|manage-jobs",
+						"viewers": "read-only",
+					},
+					GroupTenants: map[string]string{
+						"viewers": "team-a",
+					},
+					GroupsClaim:         "roles",
+					NameClaim:           "email",
+					JWKSRefreshInterval: time.Hour,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "admin oidc without audience",
+			envs: map[string]string{
+				"ADMIN_TOKEN":       "",
+				"ADMIN_OIDC_ISSUER": "https://accounts.example.com",
+			},
+			//nolint:exhaustruct
+			want:    Config{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var envs = requiredEnvs
+			envs := maps.Clone(requiredEnvs)
 			for k, v := range tt.envs {
 				envs[k] = v
 			}
@@ -85,6 +308,67 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+func TestConfig_MinIOPublicSecure(t *testing.T) {
+	tests := []struct {
+		name string
+		envs map[string]string
+		want bool
+	}{
+		{
+			name: "default",
+			envs: map[string]string{},
+			want: false,
+		},
+		{
+			name: "follow MINIO_USE_SSL",
+			envs: map[string]string{
+				"MINIO_USE_SSL": "true",
+			},
+			want: true,
+		},
+		{
+			name: "override MINIO_USE_SSL",
+			envs: map[string]string{
+				"MINIO_USE_SSL":        "false",
+				"MINIO_PUBLIC_USE_SSL": "true",
+			},
+			want: true,
+		},
+		{
+			name: "disable only for public endpoint",
+			envs: map[string]string{
+				"MINIO_USE_SSL":        "true",
+				"MINIO_PUBLIC_USE_SSL": "false",
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envs := maps.Clone(requiredEnvs)
+			for k, v := range tt.envs {
+				envs[k] = v
+			}
+
+			for k, v := range envs {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := cfg.MinIOPublicSecure(); got != tt.want {
+				t.Errorf("MinIOPublicSecure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
 func equal(got, want Config) (bool, error) {
 	merged := want
 	if err := mergo.Merge(&merged, got); err != nil {
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// TenantConfig is a tenant in TENANTS_FILE.
+// Relative file paths are resolved against the directory of TENANTS_FILE.
+//
+//	{
+//	  "tenants": [
+//	    {
+//	      "id": "team-a",
+//	      "source_bucket": "mpeg-dash-encoder-source-upload",
+//	      "source_prefix": "team-a/",
+//	      "output_bucket": "mpeg-dash-encoder-output",
+//	      "output_prefix": "team-a/",
+//	      "jwt_keys_file": "team-a/keys.json",
+//	      "admin_keys_file": "team-a/admin_keys.json",
+//	      "profile": {"name": "sd", "qualities": ["360p", "720p"]},
+//	      "quota": {"storage_bytes": 107374182400, "encode_minutes": 6000}
+//	    }
+//	  ]
+//	}
+type TenantConfig struct {
+	ID string `json:"id"`
+
+	SourceBucket SourceClientBucketName  `json:"source_bucket"`
+	SourcePrefix string                  `json:"source_prefix"`
+	OutputBucket EncodedObjectBucketName `json:"output_bucket"`
+	OutputPrefix string                  `json:"output_prefix"`
+
+	JWTSigningKey JWTSigningKey `json:"jwt_sign_secret"`
+	JWTKeysFile   JWTKeysFile   `json:"jwt_keys_file"`
+	AdminKeysFile AdminKeysFile `json:"admin_keys_file"`
+	// defaultテナントのみ
+	AdminToken AdminToken `json:"-"`
+
+	Profile entity.EncodingProfile `json:"profile"`
+	Quota   entity.TenantQuota     `json:"quota"`
+}
+
+type tenantsFileFormat struct {
+	Tenants []TenantConfig `json:"tenants"`
+}
+
+// LoadTenants returns the default tenant configured by the environment variables, followed by the tenants in TENANTS_FILE.
+func LoadTenants(cfg Config) ([]TenantConfig, error) {
+	defaultTenant := TenantConfig{
+		ID:            entity.DefaultTenantID,
+		SourceBucket:  cfg.MinIOSourceUploadBucket,
+		SourcePrefix:  cfg.MinIOSourcePrefix,
+		OutputBucket:  cfg.MinIOOutputBucket,
+		OutputPrefix:  cfg.MinIOOutputPrefix,
+		JWTSigningKey: cfg.JWTSigningKey,
+		JWTKeysFile:   cfg.JWTKeysFile,
+		AdminKeysFile: cfg.AdminKeysFile,
+		AdminToken:    cfg.AdminToken,
+	}
+	if err := defaultTenant.validatePrefixes(); err != nil {
+		return nil, fmt.Errorf("invalid default tenant: %w", err)
+	}
+	if cfg.TenantsFile == "" {
+		return []TenantConfig{defaultTenant}, nil
+	}
+
+	tenants, err := loadTenantsFile(cfg.TenantsFile)
+	if err != nil {
+		return nil, err
+	}
+	tenants = append([]TenantConfig{defaultTenant}, tenants...)
+
+	// 他のテナントのオブジェクトを処理しないよう、同じバケット内でプレフィックスが重ならないようにする
+	for i, a := range tenants {
+		for _, b := range tenants[i+1:] {
+			if overlaps(string(a.SourceBucket), a.SourcePrefix, string(b.SourceBucket), b.SourcePrefix) {
+				return nil, fmt.Errorf("source bucket of tenant %s overlaps with tenant %s", b.ID, a.ID)
+			}
+			if overlaps(string(a.OutputBucket), a.OutputPrefix, string(b.OutputBucket), b.OutputPrefix) {
+				return nil, fmt.Errorf("output bucket of tenant %s overlaps with tenant %s", b.ID, a.ID)
+			}
+		}
+	}
+	return tenants, nil
+}
+
+func overlaps(bucketA, prefixA, bucketB, prefixB string) bool {
+	return bucketA == bucketB && (strings.HasPrefix(prefixA, prefixB) || strings.HasPrefix(prefixB, prefixA))
+}
+
+func loadTenantsFile(tenantsFile TenantsFile) ([]TenantConfig, error) {
+	b, err := os.ReadFile(string(tenantsFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+
+	var f tenantsFileFormat
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file: %w", err)
+	}
+
+	baseDir := filepath.Dir(string(tenantsFile))
+	ids := make(map[string]struct{}, len(f.Tenants))
+	for i, t := range f.Tenants {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("invalid tenant %s: %w", t.ID, err)
+		}
+		if _, ok := ids[t.ID]; ok {
+			return nil, fmt.Errorf("duplicate tenant id: %s", t.ID)
+		}
+		ids[t.ID] = struct{}{}
+
+		f.Tenants[i].JWTKeysFile = JWTKeysFile(resolvePath(baseDir, string(t.JWTKeysFile)))
+		f.Tenants[i].AdminKeysFile = AdminKeysFile(resolvePath(baseDir, string(t.AdminKeysFile)))
+	}
+	return f.Tenants, nil
+}
+
+// テナントIDはオブジェクトのプレフィックスやログのディレクトリ名にも使うので、安全な文字に限る
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+func (t TenantConfig) validate() error {
+	switch {
+	case t.ID == "":
+		return errors.New("id is required")
+	case t.ID == entity.DefaultTenantID:
+		return fmt.Errorf("%s is reserved", entity.DefaultTenantID)
+	case !tenantIDPattern.MatchString(t.ID):
+		return fmt.Errorf("id must match %s", tenantIDPattern)
+	case t.SourceBucket == "" || t.OutputBucket == "":
+		return errors.New("source_bucket and output_bucket are required")
+	case t.JWTSigningKey == "" && t.JWTKeysFile == "":
+		return errors.New("jwt_sign_secret or jwt_keys_file is required")
+	case t.AdminKeysFile == "":
+		return errors.New("admin_keys_file is required")
+	}
+	return t.validatePrefixes()
+}
+
+func (t TenantConfig) validatePrefixes() error {
+	if t.SourcePrefix != "" && !strings.HasSuffix(t.SourcePrefix, "/") {
+		return errors.New("source prefix must end with a slash")
+	}
+	if t.OutputPrefix != "" && !strings.HasSuffix(t.OutputPrefix, "/") {
+		return errors.New("output prefix must end with a slash")
+	}
+	return nil
+}
+
+func resolvePath(baseDir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+func TestLoadTenants(t *testing.T) {
+	//nolint:exhaustruct
+	cfg := Config{
+		JWTSigningKey:           "test",
+		AdminToken:              "test",
+		MinIOSourceUploadBucket: "source",
+		MinIOOutputBucket:       "output",
+	}
+
+	tests := []struct {
+		name    string
+		file    string // 空の場合はTENANTS_FILEを設定しない
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "default only",
+			want: []string{entity.DefaultTenantID},
+		},
+		{
+			name: "normal",
+			file: `{"tenants": [
+				{"id": "team-a", "source_bucket": "source-a", "output_bucket": "output-a", "output_prefix": "team-a/", "jwt_sign_secret": "a", "admin_keys_file": "a.json"},
+				{"id": "team-b", "source_bucket": "source-b", "output_bucket": "output-b", "jwt_keys_file": "/etc/b.json", "admin_keys_file": "b.json"}
+			]}`,
+			want: []string{entity.DefaultTenantID, "team-a", "team-b"},
+		},
+		{
+			name:    "reserved id",
+			file:    `{"tenants": [{"id": "default", "source_bucket": "source-a", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "parent directory id",
+			file:    `{"tenants": [{"id": "..", "source_bucket": "source-a", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "id with slash",
+			file:    `{"tenants": [{"id": "team/a", "source_bucket": "source-a", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "id with backslash",
+			file:    `{"tenants": [{"id": "team\\a", "source_bucket": "source-a", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "uppercase id",
+			file:    `{"tenants": [{"id": "Team-A", "source_bucket": "source-a", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "id starting with hyphen",
+			file:    `{"tenants": [{"id": "-team", "source_bucket": "source-a", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "too long id",
+			file:    `{"tenants": [{"id": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "source_bucket": "source-a", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate id",
+			file: `{"tenants": [
+				{"id": "team-a", "source_bucket": "source-a", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"},
+				{"id": "team-a", "source_bucket": "source-b", "output_bucket": "output-b", "jwt_sign_secret": "b", "admin_keys_file": "b.json"}
+			]}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing signing key",
+			file:    `{"tenants": [{"id": "team-a", "source_bucket": "source-a", "output_bucket": "output-a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "prefix without slash",
+			file:    `{"tenants": [{"id": "team-a", "source_bucket": "source-a", "source_prefix": "team-a", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "overlaps with the default tenant",
+			file:    `{"tenants": [{"id": "team-a", "source_bucket": "source", "source_prefix": "team-a/", "output_bucket": "output-a", "jwt_sign_secret": "a", "admin_keys_file": "a.json"}]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			if tt.file != "" {
+				dir := t.TempDir()
+				cfg.TenantsFile = TenantsFile(filepath.Join(dir, "tenants.json"))
+				require.NoError(t, os.WriteFile(string(cfg.TenantsFile), []byte(tt.file), 0o600))
+			}
+
+			got, err := LoadTenants(cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			ids := make([]string, 0, len(got))
+			for _, tenant := range got {
+				ids = append(ids, tenant.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	t.Run("relative paths", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := cfg
+		cfg.TenantsFile = TenantsFile(filepath.Join(dir, "tenants.json"))
+		require.NoError(t, os.WriteFile(string(cfg.TenantsFile), []byte(`{"tenants": [
+			{"id": "team-a", "source_bucket": "source-a", "output_bucket": "output-a", "jwt_keys_file": "team-a/keys.json", "admin_keys_file": "/etc/admin_keys.json"}
+		]}`), 0o600))
+
+		got, err := LoadTenants(cfg)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, JWTKeysFile(filepath.Join(dir, "team-a/keys.json")), got[1].JWTKeysFile)
+		assert.Equal(t, AdminKeysFile("/etc/admin_keys.json"), got[1].AdminKeysFile)
+	})
+}
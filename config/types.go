@@ -1,13 +1,33 @@
 package config
 
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
 type JWTSigningKey string
 
+type JWTKeysFile string
+
+type TrustedIssuersFile string
+
+type MediaGroupsFile string
+
+type TenantsFile string
+
 type SourceClientBucketName string
 
 type EncodedObjectBucketName string
 
+// SystemBucketName is the bucket for the service's own state (e.g. token revocations).
+type SystemBucketName string
+
 type AdminToken string
 
+type AdminKeysFile string
+
 type FFmpegHWAccel string
 
 const (
@@ -29,6 +49,117 @@ const (
 	Veryslow  FFmpegPreset = "veryslow"
 )
 
+type UserTokenConfig struct {
+	DefaultTTL time.Duration `env:"DEFAULT_TTL" envDefault:"24h"`
+	MaxTTL     time.Duration `env:"MAX_TTL" envDefault:"720h"`
+	// 発行側と検証側の時刻のずれを許容する幅
+	Leeway   time.Duration `env:"LEEWAY" envDefault:"30s"`
+	Audience string        `env:"AUDIENCE"`
+
+	RevocationRefreshInterval time.Duration `env:"REVOCATION_REFRESH_INTERVAL" envDefault:"1m"`
+}
+
+type UserSessionConfig struct {
+	CookieName     string `env:"COOKIE_NAME" envDefault:"mpeg_dash_encoder_token"`
+	CookieDomain   string `env:"COOKIE_DOMAIN"`
+	CookieSecure   bool   `env:"COOKIE_SECURE" envDefault:"true"`
+	CookieSameSite string `env:"COOKIE_SAME_SITE" envDefault:"lax"` // lax, strict, none
+}
+
+func (c UserSessionConfig) SameSite() http.SameSite {
+	switch strings.ToLower(c.CookieSameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+type AdminOIDCConfig struct {
+	Issuer   string `env:"ISSUER"`   // 空の場合は無効
+	Audience string `env:"AUDIENCE"` // client ID
+	// group -> scopes (e.g. "admins:issue-tokens|manage-jobs,viewers:read-only")
+	GroupScopes         map[string]string `env:"GROUP_SCOPES" envSeparator:"," envKeyValSeparator:":"`
+	GroupsClaim         string            `env:"GROUPS_CLAIM" envDefault:"groups"`
+	NameClaim           string            `env:"NAME_CLAIM" envDefault:"email"`
+	JWKSRefreshInterval time.Duration     `env:"JWKS_REFRESH_INTERVAL" envDefault:"1h"`
+
+	// group -> tenant (e.g. "team-a-admins:team-a")。省略したグループはdefaultテナントに属する
+	GroupTenants map[string]string `env:"GROUP_TENANTS" envSeparator:"," envKeyValSeparator:":"`
+}
+
+type UsageConfig struct {
+	FlushInterval   time.Duration `env:"FLUSH_INTERVAL" envDefault:"1m"`
+	RefreshInterval time.Duration `env:"REFRESH_INTERVAL" envDefault:"10m"`
+	// テナントのクォータを超えた場合に新しいエンコードを開始しない
+	EnforceQuota bool `env:"ENFORCE_QUOTA" envDefault:"false"`
+}
+
+type MinIOUploadConfig struct {
+	Concurrency int `env:"CONCURRENCY" envDefault:"8"`
+	// ファイルごとのリトライ回数。待ち時間はRetryBaseDelayから倍々に増やす
+	MaxRetries     int           `env:"MAX_RETRIES" envDefault:"3"`
+	RetryBaseDelay time.Duration `env:"RETRY_BASE_DELAY" envDefault:"1s"`
+}
+
+type SourceConfig struct {
+	// streamの場合、ffmpegはダウンロードせずにpresigned URLからソースを読む
+	ReadMode SourceReadMode `env:"READ_MODE" envDefault:"download"`
+	// streamでも先にダウンロードする拡張子 (e.g. ".mov,.avi")。ffprobeで読めない場合もダウンロードする
+	DownloadExtensions []string `env:"DOWNLOAD_EXTENSIONS" envSeparator:","`
+
+	// エンコードする前にffprobeで確認する。空や0の場合は制限しない
+	// ffprobeのformat_nameのいずれかが含まれていればよい
+	AllowedFormats []string      `env:"ALLOWED_FORMATS" envSeparator:"," envDefault:"mov,matroska,avi,asf,mpegts,mpeg,flv,ogg,mp3,wav,flac,aac"`
+	AllowedCodecs  []string      `env:"ALLOWED_CODECS" envSeparator:","`
+	MaxDuration    time.Duration `env:"MAX_DURATION" envDefault:"6h"`
+	MaxWidth       int           `env:"MAX_WIDTH" envDefault:"7680"`
+	MaxHeight      int           `env:"MAX_HEIGHT" envDefault:"4320"`
+	// 音声と映像のストリームの最小数
+	MinStreams int `env:"MIN_STREAMS" envDefault:"1"`
+}
+
+type ReadinessConfig struct {
+	Timeout time.Duration `env:"TIMEOUT" envDefault:"5s"`
+	// 一時ディレクトリの空き容量がこれを下回るとnot readyにする
+	MinFreeDiskBytes uint64 `env:"MIN_FREE_DISK_BYTES" envDefault:"1073741824"` // 1GB
+}
+
+type JanitorConfig struct {
+	Interval time.Duration `env:"INTERVAL" envDefault:"1h"`
+	// エンコード済みでアップロードされていない出力は、アップロードを再開できるようにこの期間残す
+	OutputRetention time.Duration `env:"OUTPUT_RETENTION" envDefault:"24h"`
+	LogRetention    time.Duration `env:"LOG_RETENTION" envDefault:"168h"`
+}
+
+type MediaDeliveryConfig struct {
+	DefaultMode MediaDeliveryMode `env:"DEFAULT_MODE" envDefault:"proxy"`
+	// file extension -> mode (e.g. ".mpd:proxy,.m4s:redirect")
+	Modes         map[string]MediaDeliveryMode `env:"MODES" envSeparator:"," envKeyValSeparator:":"`
+	PresignExpiry time.Duration                `env:"PRESIGN_EXPIRY" envDefault:"5m"`
+}
+
+func (c MediaDeliveryConfig) usesRedirect() bool {
+	if c.DefaultMode == MediaDeliveryModeRedirect {
+		return true
+	}
+	for _, mode := range c.Modes {
+		if mode == MediaDeliveryModeRedirect {
+			return true
+		}
+	}
+	return false
+}
+
+func (c MediaDeliveryConfig) ModeFor(fileName string) MediaDeliveryMode {
+	if mode, ok := c.Modes[strings.ToLower(path.Ext(fileName))]; ok {
+		return mode
+	}
+	return c.DefaultMode
+}
+
 type FFmpegConfig struct {
 	LogDir     string        `env:"LOG_DIR" envDefault:"/var/log/mpeg-dash-encoder/ffmpeg"`
 	FPS        int           `env:"FPS" envDefault:"30"`
@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"fmt"
+	"slices"
+)
+
+type AdminScope string
+
+const (
+	AdminScopeIssueTokens AdminScope = "issue-tokens"
+	AdminScopeManageJobs  AdminScope = "manage-jobs"
+	AdminScopeReadOnly    AdminScope = "read-only"
+)
+
+var AdminScopes = []AdminScope{
+	AdminScopeIssueTokens,
+	AdminScopeManageJobs,
+	AdminScopeReadOnly,
+}
+
+func ParseAdminScope(s string) (AdminScope, error) {
+	scope := AdminScope(s)
+	if !slices.Contains(AdminScopes, scope) {
+		return "", fmt.Errorf("unknown admin scope: %s", s)
+	}
+	return scope, nil
+}
+
+// AdminPrincipal is the caller of the admin API.
+type AdminPrincipal struct {
+	Name     string
+	TenantID string
+	Scopes   []AdminScope
+}
+
+// HasScope reports whether the principal is allowed the scope.
+// Every principal with any scope can read.
+func (p AdminPrincipal) HasScope(scope AdminScope) bool {
+	if scope == AdminScopeReadOnly && len(p.Scopes) > 0 {
+		return true
+	}
+	return slices.Contains(p.Scopes, scope)
+}
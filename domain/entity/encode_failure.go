@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"errors"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+)
+
+// EncodeFailureReason is recorded to the tags of the source, so it must be a valid S3 tag value.
+type EncodeFailureReason string
+
+const (
+	EncodeFailureReasonInvalidInput     EncodeFailureReason = "invalid_input"
+	EncodeFailureReasonUnsupportedCodec EncodeFailureReason = "unsupported_codec"
+	EncodeFailureReasonNoStreams        EncodeFailureReason = "no_streams"
+	EncodeFailureReasonDiskFull         EncodeFailureReason = "disk_full"
+	EncodeFailureReasonHardwareInit     EncodeFailureReason = "hardware_init"
+	EncodeFailureReasonInvalidSource    EncodeFailureReason = "invalid_source"
+	EncodeFailureReasonUnknown          EncodeFailureReason = "unknown"
+)
+
+var encodeFailureReasons = []struct {
+	err    error
+	reason EncodeFailureReason
+}{
+	{domain.ErrInvalidMediaInput, EncodeFailureReasonInvalidInput},
+	{domain.ErrUnsupportedCodec, EncodeFailureReasonUnsupportedCodec},
+	{domain.ErrNoMediaStreams, EncodeFailureReasonNoStreams},
+	{domain.ErrDiskFull, EncodeFailureReasonDiskFull},
+	{domain.ErrHardwareInit, EncodeFailureReasonHardwareInit},
+	{domain.ErrInvalidSource, EncodeFailureReasonInvalidSource},
+}
+
+// EncodeFailureReasonOf returns the reason of an error returned by the encoder.
+func EncodeFailureReasonOf(err error) EncodeFailureReason {
+	for _, r := range encodeFailureReasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return EncodeFailureReasonUnknown
+}
+
+// Permanent reports whether the failure is caused by the source itself, so retrying does not help.
+// Disk full and hardware init failures depend on the host and are retried.
+func (r EncodeFailureReason) Permanent() bool {
+	switch r {
+	case EncodeFailureReasonInvalidInput, EncodeFailureReasonUnsupportedCodec, EncodeFailureReasonNoStreams, EncodeFailureReasonInvalidSource:
+		return true
+	default:
+		return false
+	}
+}
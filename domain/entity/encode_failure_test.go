@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+)
+
+func TestEncodeFailureReasonOf(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		want          EncodeFailureReason
+		wantPermanent bool
+	}{
+		{
+			name:          "wrapped invalid input",
+			err:           fmt.Errorf("failed to encode: %w", fmt.Errorf("failed to run ffmpeg: %w", domain.ErrInvalidMediaInput)),
+			want:          EncodeFailureReasonInvalidInput,
+			wantPermanent: true,
+		},
+		{
+			name:          "no streams",
+			err:           domain.ErrNoMediaStreams,
+			want:          EncodeFailureReasonNoStreams,
+			wantPermanent: true,
+		},
+		{
+			name:          "disk full",
+			err:           fmt.Errorf("failed to run ffmpeg: %w", domain.ErrDiskFull),
+			want:          EncodeFailureReasonDiskFull,
+			wantPermanent: false,
+		},
+		{
+			name:          "unknown",
+			err:           errors.New("exit status 1"),
+			want:          EncodeFailureReasonUnknown,
+			wantPermanent: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EncodeFailureReasonOf(tt.err)
+			if got != tt.want {
+				t.Errorf("EncodeFailureReasonOf() = %v, want %v", got, tt.want)
+			}
+			if got.Permanent() != tt.wantPermanent {
+				t.Errorf("Permanent() = %v, want %v", got.Permanent(), tt.wantPermanent)
+			}
+		})
+	}
+}
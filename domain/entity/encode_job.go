@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+type EncodeJobState string
+
+const (
+	// エンコード待ち
+	EncodeJobStatePending EncodeJobState = "pending"
+	// いずれかのホストがclaimしている
+	EncodeJobStateRunning EncodeJobState = "running"
+	// リトライする失敗の後、claimが期限切れになるのを待っている
+	EncodeJobStateRetrying EncodeJobState = "retrying"
+	// リトライしても成功しない失敗。失敗を消すまでエンコードしない
+	EncodeJobStateFailed EncodeJobState = "failed"
+)
+
+// EncodeJob is the state of the encode of a source, which is recorded to the tags of the source.
+type EncodeJob struct {
+	MediaID          string              `json:"media_id"`
+	TenantID         string              `json:"tenant_id"`
+	State            EncodeJobState      `json:"state"`
+	Hostname         string              `json:"hostname,omitempty"`
+	StartedAt        time.Time           `json:"started_at,omitzero"`
+	LastFailedReason EncodeFailureReason `json:"last_failed_reason,omitempty"`
+	FailedAt         time.Time           `json:"failed_at,omitzero"`
+	FailedReason     EncodeFailureReason `json:"failed_reason,omitempty"`
+	FailedDetail     string              `json:"failed_detail,omitempty"`
+}
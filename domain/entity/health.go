@@ -0,0 +1,22 @@
+package entity
+
+type HealthStatus string
+
+const (
+	HealthStatusOK   HealthStatus = "ok"
+	HealthStatusFail HealthStatus = "fail"
+)
+
+type HealthCheck struct {
+	Name    string       `json:"name"`
+	Status  HealthStatus `json:"status"`
+	Message string       `json:"message,omitempty"`
+}
+
+// NewHealthCheck creates a check which fails if err is not nil. message is used only when it succeeds.
+func NewHealthCheck(name string, err error, message string) HealthCheck {
+	if err != nil {
+		return HealthCheck{Name: name, Status: HealthStatusFail, Message: err.Error()}
+	}
+	return HealthCheck{Name: name, Status: HealthStatusOK, Message: message}
+}
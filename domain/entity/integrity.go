@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// IntegrityManifestFileName is written under the media prefix after the encoded files are uploaded.
+const IntegrityManifestFileName = "manifest.json"
+
+// IntegrityFile is an encoded file. Path is relative to the media prefix.
+type IntegrityFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// IntegrityManifest lists the files which the encoder produced for a media.
+type IntegrityManifest struct {
+	MediaID        string          `json:"media_id"`
+	TenantID       string          `json:"tenant_id,omitempty"`
+	EncoderVersion string          `json:"encoder_version"` // e.g. "ffmpeg version 7.1 ..."
+	Profile        EncodingProfile `json:"profile"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Files          []IntegrityFile `json:"files"`
+}
+
+// IntegrityDigest returns the hex encoded SHA-256 of the manifest bytes, which is signed.
+func IntegrityDigest(manifest []byte) string {
+	sum := sha256.Sum256(manifest)
+	return hex.EncodeToString(sum[:])
+}
+
+// SignedIntegrityManifest is the content of IntegrityManifestFileName.
+// Manifest is kept as written, since the signature covers its bytes rather than the decoded struct.
+// Signature is a JWT signed by the tenant's key, which has the digest of the manifest.
+// KeyID is the kid of the key, which is empty if the shared secret is used.
+type SignedIntegrityManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	KeyID     string          `json:"kid,omitempty"`
+	Signature string          `json:"signature"`
+}
+
+// Decode decodes the signed manifest bytes.
+func (s SignedIntegrityManifest) Decode() (IntegrityManifest, error) {
+	var manifest IntegrityManifest
+	if err := json.Unmarshal(s.Manifest, &manifest); err != nil {
+		return IntegrityManifest{}, fmt.Errorf("failed to decode integrity manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+type IntegrityFileStatus string
+
+const (
+	IntegrityFileStatusOK           IntegrityFileStatus = "ok"
+	IntegrityFileStatusMissing      IntegrityFileStatus = "missing"
+	IntegrityFileStatusSizeMismatch IntegrityFileStatus = "size_mismatch"
+	IntegrityFileStatusHashMismatch IntegrityFileStatus = "hash_mismatch"
+	IntegrityFileStatusError        IntegrityFileStatus = "error"
+)
+
+type IntegrityFileResult struct {
+	Path    string              `json:"path"`
+	Status  IntegrityFileStatus `json:"status"`
+	Message string              `json:"message,omitempty"`
+}
+
+// IntegrityReport is the result of checking the output bucket against the integrity manifest.
+type IntegrityReport struct {
+	MediaID        string                `json:"media_id"`
+	Valid          bool                  `json:"valid"`
+	SignatureValid bool                  `json:"signature_valid"`
+	SignatureError string                `json:"signature_error,omitempty"`
+	Files          []IntegrityFileResult `json:"files"`
+}
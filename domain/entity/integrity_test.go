@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestIntegrityDigest(t *testing.T) {
+	manifest := IntegrityManifest{
+		MediaID:        "movie-1",
+		EncoderVersion: "ffmpeg version 7.1",
+		Profile:        EncodingProfile{Name: "default"},
+		CreatedAt:      time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC),
+		Files: []IntegrityFile{
+			{Path: "dash.mpd", Size: 100, SHA256: "aaaa"},
+			{Path: "init0.m4s", Size: 200, SHA256: "bbbb"},
+		},
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(m IntegrityManifest) IntegrityManifest
+		wantSame bool
+	}{
+		{
+			name:     "same manifest",
+			modify:   func(m IntegrityManifest) IntegrityManifest { return m },
+			wantSame: true,
+		},
+		{
+			name: "file hash changed",
+			modify: func(m IntegrityManifest) IntegrityManifest {
+				m.Files = []IntegrityFile{m.Files[0], {Path: "init0.m4s", Size: 200, SHA256: "cccc"}}
+				return m
+			},
+			wantSame: false,
+		},
+		{
+			name: "file removed",
+			modify: func(m IntegrityManifest) IntegrityManifest {
+				m.Files = m.Files[:1]
+				return m
+			},
+			wantSame: false,
+		},
+		{
+			name: "profile changed",
+			modify: func(m IntegrityManifest) IntegrityManifest {
+				m.Profile.Name = "high"
+				return m
+			},
+			wantSame: false,
+		},
+	}
+
+	want := IntegrityDigest(raw)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.modify(manifest))
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if got := IntegrityDigest(b); (got == want) != tt.wantSame {
+				t.Errorf("IntegrityDigest() = %v, original %v, wantSame %v", got, want, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestSignedIntegrityManifest(t *testing.T) {
+	// デコードで消えるフィールドも署名対象のバイト列には残る
+	raw := []byte(`{"media_id":"movie-1","files":[{"path":"dash.mpd","size":100,"sha256":"aaaa"}],"extra":"tampered"}`)
+	b, err := json.Marshal(SignedIntegrityManifest{Manifest: raw, KeyID: "key-1", Signature: "signature"})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var signed SignedIntegrityManifest
+	if err := json.Unmarshal(b, &signed); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if string(signed.Manifest) != string(raw) {
+		t.Errorf("Manifest = %s, want %s", signed.Manifest, raw)
+	}
+	if signed.KeyID != "key-1" {
+		t.Errorf("KeyID = %v, want key-1", signed.KeyID)
+	}
+
+	manifest, err := signed.Decode()
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if manifest.MediaID != "movie-1" || len(manifest.Files) != 1 {
+		t.Errorf("Decode() = %+v", manifest)
+	}
+}
@@ -0,0 +1,154 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateMediaID(t *testing.T) {
+	tests := []struct {
+		name    string
+		mediaID string
+		wantErr bool
+	}{
+		{name: "simple", mediaID: "movie-1", wantErr: false},
+		{name: "hierarchical", mediaID: "series-42/ep-1", wantErr: true},
+		{name: "dots in segment", mediaID: "a..b", wantErr: false},
+		{name: "empty", mediaID: "", wantErr: true},
+		{name: "dot", mediaID: ".", wantErr: true},
+		{name: "dot dot", mediaID: "..", wantErr: true},
+		{name: "parent traversal", mediaID: "series-42/../secret", wantErr: true},
+		{name: "leading parent", mediaID: "../secret", wantErr: true},
+		{name: "absolute", mediaID: "/secret", wantErr: true},
+		{name: "trailing slash", mediaID: "series-42/", wantErr: true},
+		{name: "double slash", mediaID: "series-42//ep-1", wantErr: true},
+		{name: "backslash", mediaID: `series-42\..\secret`, wantErr: true},
+		{name: "null byte", mediaID: "movie-1\x00", wantErr: true},
+		{name: "newline", mediaID: "movie-1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMediaID(tt.mediaID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMediaID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidMediaID) {
+				t.Errorf("ValidateMediaID() error = %v, want ErrInvalidMediaID", err)
+			}
+		})
+	}
+}
+
+func TestValidateMediaFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		wantErr  bool
+	}{
+		{name: "manifest", fileName: "dash.mpd", wantErr: false},
+		{name: "segment", fileName: "chunk0-00001.m4s", wantErr: false},
+		{name: "empty", fileName: "", wantErr: true},
+		{name: "dot dot", fileName: "..", wantErr: true},
+		{name: "slash", fileName: "../dash.mpd", wantErr: true},
+		{name: "backslash", fileName: `..\dash.mpd`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateMediaFileName(tt.fileName); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMediaFileName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMediaGrant(t *testing.T) {
+	tests := []struct {
+		name    string
+		grant   string
+		wantErr bool
+	}{
+		{name: "exact", grant: "movie-1", wantErr: false},
+		{name: "prefix", grant: "series-42-*", wantErr: false},
+		{name: "glob", grant: "series-4?-ep-[0-9]*", wantErr: false},
+		{name: "hierarchical prefix", grant: "series-42/*", wantErr: true},
+		{name: "dot dot", grant: "..", wantErr: true},
+		{name: "group", grant: "group:anime", wantErr: false},
+		{name: "empty", grant: "", wantErr: true},
+		{name: "empty group", grant: "group:", wantErr: true},
+		{name: "bad pattern", grant: "series-[", wantErr: true},
+		{name: "parent traversal", grant: "series-42/../*", wantErr: true},
+		{name: "escape", grant: `series-42\*`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateMediaGrant(tt.grant); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMediaGrant() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatchMediaGrant(t *testing.T) {
+	groups := MediaGroups{
+		"anime":  {"series-42-*", "movie-1"},
+		"nested": {"group:anime"},
+	}
+
+	tests := []struct {
+		name    string
+		grant   string
+		mediaID string
+		want    bool
+	}{
+		{name: "exact", grant: "movie-1", mediaID: "movie-1", want: true},
+		{name: "exact mismatch", grant: "movie-1", mediaID: "movie-10", want: false},
+		{name: "prefix", grant: "series-42-*", mediaID: "series-42-ep-1", want: true},
+		{name: "prefix itself", grant: "series-42-*", mediaID: "series-42-", want: false},
+		{name: "prefix sibling", grant: "series-42-*", mediaID: "series-420-ep-1", want: false},
+		{name: "prefix slash", grant: "series-42-*", mediaID: "series-42-/../secret", want: false},
+		{name: "prefix backslash", grant: "series-42-*", mediaID: `series-42-..\secret`, want: false},
+		{name: "hierarchical prefix", grant: "series-42/*", mediaID: "series-42/ep-1", want: false},
+		{name: "wildcard", grant: "*", mediaID: "movie-1", want: true},
+		{name: "wildcard traversal", grant: "*", mediaID: "..", want: false},
+		{name: "glob", grant: "series-4?-ep-[0-9]*", mediaID: "series-42-ep-12", want: true},
+		{name: "glob mismatch", grant: "series-4?-ep-[0-9]*", mediaID: "series-42-ep-x", want: false},
+		{name: "bad pattern", grant: "series-[", mediaID: "series-[", want: false},
+		{name: "escaped pattern", grant: `movie-\1`, mediaID: "movie-1", want: false},
+		{name: "group", grant: "group:anime", mediaID: "series-42-ep-1", want: true},
+		{name: "group exact", grant: "group:anime", mediaID: "movie-1", want: true},
+		{name: "group mismatch", grant: "group:anime", mediaID: "movie-2", want: false},
+		{name: "unknown group", grant: "group:drama", mediaID: "movie-1", want: false},
+		{name: "nested group", grant: "group:nested", mediaID: "movie-1", want: false},
+		{name: "group literal", grant: "group:anime", mediaID: "group:anime", want: false},
+		{name: "empty grant", grant: "", mediaID: "movie-1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchMediaGrant(tt.grant, tt.mediaID, groups); got != tt.want {
+				t.Errorf("MatchMediaGrant(%q, %q) = %v, want %v", tt.grant, tt.mediaID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserToken_CanAccessMedia(t *testing.T) {
+	tests := []struct {
+		name      string
+		userToken UserToken
+		mediaID   string
+		want      bool
+	}{
+		{name: "granted", userToken: UserToken{MediaIDs: []string{"movie-1", "series-42-*"}}, mediaID: "series-42-ep-1", want: true},
+		{name: "not granted", userToken: UserToken{MediaIDs: []string{"movie-1"}}, mediaID: "movie-2", want: false},
+		{name: "no grants", userToken: UserToken{}, mediaID: "movie-1", want: false},
+		{name: "all media", userToken: UserToken{AllMedia: true}, mediaID: "movie-1", want: true},
+		{name: "all media traversal", userToken: UserToken{AllMedia: true}, mediaID: "../movie-1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.userToken.CanAccessMedia(tt.mediaID, nil); got != tt.want {
+				t.Errorf("UserToken.CanAccessMedia() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
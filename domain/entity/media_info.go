@@ -0,0 +1,97 @@
+package entity
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+)
+
+// MediaInfo is the result of probing a source file.
+type MediaInfo struct {
+	// 長さが分からない場合は0
+	Duration time.Duration
+	// ffprobeのformat_name。"mov,mp4,m4a,3gp,3g2,mj2"のように複数の名前を持つ
+	FormatNames []string
+	Streams     []MediaStream
+}
+
+type MediaStreamType string
+
+const (
+	MediaStreamTypeVideo MediaStreamType = "video"
+	MediaStreamTypeAudio MediaStreamType = "audio"
+)
+
+type MediaStream struct {
+	Type   MediaStreamType
+	Codec  string
+	Width  int
+	Height int
+}
+
+// SourceConstraints are checked before encoding, so that files which are not media are not fed to ffmpeg.
+// Zero or empty means unlimited.
+type SourceConstraints struct {
+	AllowedFormats []string
+	AllowedCodecs  []string
+	MaxDuration    time.Duration
+	MaxWidth       int
+	MaxHeight      int
+	// 音声と映像のストリームの最小数
+	MinStreams int
+	MaxSize    int64
+}
+
+// ValidateSize is checked before downloading the source.
+func (c SourceConstraints) ValidateSize(size int64) error {
+	if size <= 0 {
+		return fmt.Errorf("%w: file is empty", domain.ErrInvalidSource)
+	}
+	if c.MaxSize > 0 && size > c.MaxSize {
+		return fmt.Errorf("%w: file size %d bytes exceeds the limit %d bytes", domain.ErrInvalidSource, size, c.MaxSize)
+	}
+	return nil
+}
+
+func (c SourceConstraints) Validate(info MediaInfo) error {
+	if len(c.AllowedFormats) > 0 && !slices.ContainsFunc(info.FormatNames, func(name string) bool {
+		return containsFold(c.AllowedFormats, name)
+	}) {
+		return fmt.Errorf("%w: format %s is not allowed", domain.ErrInvalidSource, strings.Join(info.FormatNames, ","))
+	}
+	// 長さが分からない場合 (Duration == 0) は確認しない
+	if c.MaxDuration > 0 && info.Duration > c.MaxDuration {
+		return fmt.Errorf("%w: duration %s exceeds the limit %s", domain.ErrInvalidSource, info.Duration, c.MaxDuration)
+	}
+
+	streams := 0
+	for _, s := range info.Streams {
+		// 字幕やデータのストリームはエンコードしない
+		if s.Type != MediaStreamTypeVideo && s.Type != MediaStreamTypeAudio {
+			continue
+		}
+		streams++
+		if len(c.AllowedCodecs) > 0 && !containsFold(c.AllowedCodecs, s.Codec) {
+			return fmt.Errorf("%w: %s codec %s is not allowed", domain.ErrInvalidSource, s.Type, s.Codec)
+		}
+		if s.Type != MediaStreamTypeVideo {
+			continue
+		}
+		if (c.MaxWidth > 0 && s.Width > c.MaxWidth) || (c.MaxHeight > 0 && s.Height > c.MaxHeight) {
+			return fmt.Errorf("%w: resolution %dx%d exceeds the limit %dx%d", domain.ErrInvalidSource, s.Width, s.Height, c.MaxWidth, c.MaxHeight)
+		}
+	}
+	if streams < c.MinStreams {
+		return fmt.Errorf("%w: %d audio or video streams found, at least %d required", domain.ErrInvalidSource, streams, c.MinStreams)
+	}
+	return nil
+}
+
+func containsFold(list []string, v string) bool {
+	return slices.ContainsFunc(list, func(s string) bool {
+		return strings.EqualFold(strings.TrimSpace(s), v)
+	})
+}
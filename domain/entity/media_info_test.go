@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+)
+
+func TestSourceConstraints_ValidateSize(t *testing.T) {
+	c := SourceConstraints{MaxSize: 100}
+	tests := []struct {
+		name    string
+		size    int64
+		wantErr bool
+	}{
+		{name: "normal", size: 100},
+		{name: "empty", size: 0, wantErr: true},
+		{name: "too large", size: 101, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.ValidateSize(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, domain.ErrInvalidSource) {
+				t.Errorf("ValidateSize() error = %v, want ErrInvalidSource", err)
+			}
+		})
+	}
+}
+
+func TestSourceConstraints_Validate(t *testing.T) {
+	c := SourceConstraints{
+		AllowedFormats: []string{"mp4", "matroska"},
+		AllowedCodecs:  []string{"h264", "aac"},
+		MaxDuration:    time.Hour,
+		MaxWidth:       1920,
+		MaxHeight:      1080,
+		MinStreams:     1,
+	}
+	video := MediaStream{Type: MediaStreamTypeVideo, Codec: "h264", Width: 1920, Height: 1080}
+	audio := MediaStream{Type: MediaStreamTypeAudio, Codec: "aac"}
+
+	tests := []struct {
+		name    string
+		info    MediaInfo
+		wantErr bool
+	}{
+		{
+			name: "normal",
+			info: MediaInfo{Duration: time.Minute, FormatNames: []string{"mov", "mp4", "m4a"}, Streams: []MediaStream{video, audio}},
+		},
+		{
+			name: "audio only",
+			info: MediaInfo{Duration: time.Minute, FormatNames: []string{"matroska", "webm"}, Streams: []MediaStream{audio}},
+		},
+		{
+			name:    "format not allowed",
+			info:    MediaInfo{Duration: time.Minute, FormatNames: []string{"png_pipe"}, Streams: []MediaStream{{Type: MediaStreamTypeVideo, Codec: "png"}}},
+			wantErr: true,
+		},
+		{
+			name:    "codec not allowed",
+			info:    MediaInfo{Duration: time.Minute, FormatNames: []string{"mp4"}, Streams: []MediaStream{{Type: MediaStreamTypeVideo, Codec: "hevc"}}},
+			wantErr: true,
+		},
+		{
+			name:    "too long",
+			info:    MediaInfo{Duration: 2 * time.Hour, FormatNames: []string{"mp4"}, Streams: []MediaStream{video}},
+			wantErr: true,
+		},
+		{
+			name: "unknown duration",
+			info: MediaInfo{FormatNames: []string{"mp4"}, Streams: []MediaStream{video}},
+		},
+		{
+			name:    "resolution too large",
+			info:    MediaInfo{Duration: time.Minute, FormatNames: []string{"mp4"}, Streams: []MediaStream{{Type: MediaStreamTypeVideo, Codec: "h264", Width: 3840, Height: 2160}}},
+			wantErr: true,
+		},
+		{
+			name:    "subtitles only",
+			info:    MediaInfo{Duration: time.Minute, FormatNames: []string{"mp4"}, Streams: []MediaStream{{Type: "subtitle", Codec: "mov_text"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.Validate(tt.info)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, domain.ErrInvalidSource) {
+				t.Errorf("Validate() error = %v, want ErrInvalidSource", err)
+			}
+		})
+	}
+}
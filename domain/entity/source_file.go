@@ -1,6 +1,45 @@
 package entity
 
+import (
+	"net/url"
+	"strings"
+)
+
 type SourceFile struct {
 	ID   string
-	Tags map[string]string
+	Size int64
+	// ソースが置き換えられると変わる値。ETag、またはサイズと更新日時
+	Version string
+	Tags    map[string]string
+	// x-amz-meta-* のユーザーメタデータ。キーはプレフィックスを除いて小文字にしたもの
+	Metadata map[string]string
+}
+
+// EncodeSource is the input of an encode.
+type EncodeSource struct {
+	TenantID string
+	MediaID  string
+	// SourceFile.Version. エンコード済みの出力は同じバージョンのソースの場合だけ再利用する
+	Version string
+	// ffmpegの入力。ダウンロードしたファイルのパス、またはpresigned URL
+	Input string
+}
+
+// RedactInput strips the query and the user info from an http(s) input, which have the signature of a presigned URL.
+// Inputs must be redacted before they are logged or recorded to a span.
+func RedactInput(input string) string {
+	if !strings.HasPrefix(input, "http://") && !strings.HasPrefix(input, "https://") {
+		return input
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		before, _, _ := strings.Cut(input, "?")
+		return before
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
 }
@@ -0,0 +1,26 @@
+package entity
+
+// DefaultTenantID is the tenant configured by the environment variables.
+const DefaultTenantID = "default"
+
+type Tenant struct {
+	ID      string
+	Profile EncodingProfile
+	Quota   TenantQuota
+}
+
+// EncodingProfile overrides the encoder settings. Zero values mean the encoder defaults.
+type EncodingProfile struct {
+	Name      string   `json:"name"`
+	Preset    string   `json:"preset"`
+	FPS       int      `json:"fps"`
+	Qualities []string `json:"qualities"` // e.g. ["360p", "720p"]
+	AudioOnly bool     `json:"audio_only"`
+}
+
+// TenantQuota limits the usage of a tenant. Zero values mean unlimited.
+type TenantQuota struct {
+	StorageBytes int64 `json:"storage_bytes"`
+	// 暦月あたりのエンコード時間 (ソースの長さ × Rendition数)
+	EncodeMinutes float64 `json:"encode_minutes"`
+}
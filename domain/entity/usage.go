@@ -0,0 +1,44 @@
+package entity
+
+import "time"
+
+// UsageDateLayout is the layout of UsageRecord.Date.
+const UsageDateLayout = time.DateOnly
+
+// UsageRecord is the usage of a media in a day.
+type UsageRecord struct {
+	Date     string `json:"date"` // e.g. "2025-01-31" (Asia/Tokyo)
+	TenantID string `json:"tenant_id"`
+	MediaID  string `json:"media_id,omitempty"`
+
+	// ソースの長さ × Rendition数
+	EncodeMinutes float64 `json:"encode_minutes"`
+	// 出力バケットに保存したバイト数
+	StoredBytes int64 `json:"stored_bytes"`
+	// GetMediaFileで配信したバイト数。presigned URLへのリダイレクトは含まない
+	ServedBytes int64 `json:"served_bytes"`
+}
+
+func (r *UsageRecord) Add(other UsageRecord) {
+	r.EncodeMinutes += other.EncodeMinutes
+	r.StoredBytes += other.StoredBytes
+	r.ServedBytes += other.ServedBytes
+}
+
+// TenantUsage is the usage counted against TenantQuota.
+type TenantUsage struct {
+	StoredBytes int64
+	// 今月のエンコード時間
+	EncodeMinutes float64
+}
+
+// Exceeds reports whether the usage has reached the quota.
+func (u TenantUsage) Exceeds(quota TenantQuota) bool {
+	if quota.StorageBytes > 0 && u.StoredBytes >= quota.StorageBytes {
+		return true
+	}
+	if quota.EncodeMinutes > 0 && u.EncodeMinutes >= quota.EncodeMinutes {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "testing"
+
+func TestTenantUsage_Exceeds(t *testing.T) {
+	quota := TenantQuota{StorageBytes: 1000, EncodeMinutes: 60}
+	tests := []struct {
+		name  string
+		usage TenantUsage
+		quota TenantQuota
+		want  bool
+	}{
+		{name: "under quota", usage: TenantUsage{StoredBytes: 999, EncodeMinutes: 59.9}, quota: quota, want: false},
+		{name: "storage reached", usage: TenantUsage{StoredBytes: 1000}, quota: quota, want: true},
+		{name: "encode minutes reached", usage: TenantUsage{EncodeMinutes: 60}, quota: quota, want: true},
+		{name: "unlimited", usage: TenantUsage{StoredBytes: 1 << 40, EncodeMinutes: 1e6}, quota: TenantQuota{}, want: false},
+		{name: "storage only", usage: TenantUsage{EncodeMinutes: 1e6}, quota: TenantQuota{StorageBytes: 1000}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.usage.Exceeds(tt.quota); got != tt.want {
+				t.Errorf("Exceeds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"slices"
+	"time"
+)
+
+type UserToken struct {
+	ID       string
+	TenantID string
+	Subject  string
+	// MediaIDs はメディアIDまたはgrant (前方一致、glob、グループ)
+	MediaIDs []string
+	// AllMedia is granted by a trusted issuer's scope, and allows access to every media.
+	AllMedia bool
+	// Renditions はメディア内でアクセスできるRepresentationを制限する
+	Renditions RenditionConstraints
+	Audience   []string
+	IssuedAt   time.Time
+	NotBefore  time.Time
+	ExpiresAt  time.Time
+}
+
+func (t UserToken) CanAccessMedia(mediaID string, groups MediaGroups) bool {
+	if ValidateMediaID(mediaID) != nil {
+		return false
+	}
+	if t.AllMedia {
+		return true
+	}
+	return slices.ContainsFunc(t.MediaIDs, func(grant string) bool {
+		return MatchMediaGrant(grant, mediaID, groups)
+	})
+}
+
+// RenditionConstraints restricts the representations of a media. Zero values mean no restriction.
+type RenditionConstraints struct {
+	MaxHeight    int  `json:"max_height,omitempty"`
+	MaxBandwidth int  `json:"max_bandwidth,omitempty"` // bits per second
+	AudioOnly    bool `json:"audio_only,omitempty"`
+}
+
+func (c RenditionConstraints) IsZero() bool {
+	return c == RenditionConstraints{}
+}
+
+// TokenRevocation revokes a single token by ID, or every token of Subject issued before RevokedAt.
+type TokenRevocation struct {
+	// 空の場合はdefaultテナント
+	TenantID  string    `json:"tenant_id,omitempty"`
+	TokenID   string    `json:"token_id,omitempty"`
+	Subject   string    `json:"subject,omitempty"`
+	RevokedAt time.Time `json:"revoked_at"`
+	// ExpiresAt 以降は対象のトークンが全て失効しているため、失効情報も不要になる
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -2,4 +2,33 @@ package domain
 
 import "errors"
 
-var ErrInvalidToken = errors.New("invalid token")
+var (
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrInvalidTokenTTL = errors.New("invalid token ttl")
+	// 検証側で設定したaudienceを含まないトークンは検証できない
+	ErrInvalidTokenAudience = errors.New("invalid token audience")
+	ErrTokenRevoked         = errors.New("token revoked")
+
+	ErrInvalidAdminKey = errors.New("invalid admin key")
+
+	ErrUnknownTenant = errors.New("unknown tenant")
+
+	ErrInvalidRenditionConstraints = errors.New("invalid rendition constraints")
+
+	ErrObjectNotFound = errors.New("object not found")
+
+	// ffmpegの失敗の原因
+	ErrInvalidMediaInput = errors.New("invalid or corrupt input")
+	ErrUnsupportedCodec  = errors.New("unsupported codec")
+	ErrNoMediaStreams    = errors.New("input has no audio or video streams")
+	ErrDiskFull          = errors.New("no space left on device")
+	ErrHardwareInit      = errors.New("failed to initialize hardware acceleration")
+
+	ErrInvalidSource = errors.New("invalid source")
+
+	// 失敗していないジョブの失敗は消せない
+	ErrEncodeNotFailed = errors.New("encode has not failed")
+
+	// 実行中のジョブのログは実行しているホストにしかない
+	ErrJobRunningOnOtherHost = errors.New("job is running on another host")
+)
@@ -0,0 +1,146 @@
+package adminkey
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// ADMIN_TOKENで設定されたキーの名前
+const defaultKeyName = "default"
+
+const sha256Prefix = "sha256:"
+
+// adminKeysFileFormat is the format of ADMIN_KEYS_FILE.
+// hash is the SHA-256 of the key (e.g. `printf %s "$KEY" | sha256sum`).
+//
+//	{
+//	  "keys": [
+//	    {"name": "ci", "hash": "sha256:<hex>", "scopes": ["issue-tokens"]}
+//	  ]
+//	}
+type adminKeysFileFormat struct {
+	Keys []adminKeyConfig `json:"keys"`
+}
+
+type adminKeyConfig struct {
+	Name   string   `json:"name"`
+	Hash   string   `json:"hash"`
+	Scopes []string `json:"scopes"`
+}
+
+type adminKey struct {
+	hash      []byte
+	principal entity.AdminPrincipal
+}
+
+type KeyStore struct {
+	keys []adminKey
+}
+
+func NewKeyStore(adminKeysFile config.AdminKeysFile, adminToken config.AdminToken) (*KeyStore, error) {
+	s := &KeyStore{
+		keys: make([]adminKey, 0),
+	}
+
+	if adminToken != "" {
+		hash := sha256.Sum256([]byte(adminToken))
+		s.keys = append(s.keys, adminKey{
+			hash: hash[:],
+			principal: entity.AdminPrincipal{
+				Name:   defaultKeyName,
+				Scopes: entity.AdminScopes,
+			},
+		})
+	}
+
+	if adminKeysFile == "" {
+		return s, nil
+	}
+
+	b, err := os.ReadFile(string(adminKeysFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin keys file: %w", err)
+	}
+
+	var f adminKeysFileFormat
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse admin keys file: %w", err)
+	}
+
+	names := make(map[string]struct{}, len(f.Keys))
+	for _, keyCfg := range f.Keys {
+		key, err := newAdminKey(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load admin key %s: %w", keyCfg.Name, err)
+		}
+		if _, ok := names[keyCfg.Name]; ok || (adminToken != "" && keyCfg.Name == defaultKeyName) {
+			return nil, fmt.Errorf("duplicate admin key name: %s", keyCfg.Name)
+		}
+		names[keyCfg.Name] = struct{}{}
+		s.keys = append(s.keys, key)
+	}
+	return s, nil
+}
+
+func newAdminKey(cfg adminKeyConfig) (adminKey, error) {
+	if cfg.Name == "" {
+		return adminKey{}, errors.New("name is required")
+	}
+
+	hexHash, ok := strings.CutPrefix(cfg.Hash, sha256Prefix)
+	if !ok {
+		return adminKey{}, fmt.Errorf("hash must start with %q", sha256Prefix)
+	}
+	hash, err := hex.DecodeString(hexHash)
+	if err != nil || len(hash) != sha256.Size {
+		return adminKey{}, errors.New("hash must be a hex encoded SHA-256")
+	}
+
+	if len(cfg.Scopes) == 0 {
+		return adminKey{}, errors.New("scopes are required")
+	}
+	scopes := make([]entity.AdminScope, 0, len(cfg.Scopes))
+	for _, s := range cfg.Scopes {
+		scope, err := entity.ParseAdminScope(s)
+		if err != nil {
+			return adminKey{}, err
+		}
+		scopes = append(scopes, scope)
+	}
+
+	return adminKey{
+		hash: hash,
+		principal: entity.AdminPrincipal{
+			Name:   cfg.Name,
+			Scopes: scopes,
+		},
+	}, nil
+}
+
+// Authenticate finds the admin key. Every key is compared in constant time.
+func (s *KeyStore) Authenticate(key string) (entity.AdminPrincipal, bool) {
+	if key == "" {
+		return entity.AdminPrincipal{}, false
+	}
+	hash := sha256.Sum256([]byte(key))
+
+	var found entity.AdminPrincipal
+	ok := false
+	// 一致しても比較を打ち切らない
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare(hash[:], k.hash) == 1 {
+			found = k.principal
+			ok = true
+		}
+	}
+	return found, ok
+}
@@ -0,0 +1,94 @@
+package adminkey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+func hashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return sha256Prefix + hex.EncodeToString(hash[:])
+}
+
+func writeAdminKeysFile(t *testing.T, content string) config.AdminKeysFile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "admin_keys.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return config.AdminKeysFile(path)
+}
+
+func TestKeyStore_Authenticate(t *testing.T) {
+	adminKeysFile := writeAdminKeysFile(t, `{"keys": [
+		{"name": "ci", "hash": "`+hashKey("ci-key")+`", "scopes": ["issue-tokens"]},
+		{"name": "dashboard", "hash": "`+hashKey("dashboard-key")+`", "scopes": ["read-only"]}
+	]}`)
+
+	store, err := NewKeyStore(adminKeysFile, "legacy-key")
+	if err != nil {
+		t.Fatalf("NewKeyStore() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		wantName  string
+		wantScope entity.AdminScope
+		wantOK    bool
+	}{
+		{name: "named key", key: "ci-key", wantName: "ci", wantScope: entity.AdminScopeIssueTokens, wantOK: true},
+		{name: "read only key", key: "dashboard-key", wantName: "dashboard", wantScope: entity.AdminScopeReadOnly, wantOK: true},
+		{name: "admin token", key: "legacy-key", wantName: defaultKeyName, wantScope: entity.AdminScopeManageJobs, wantOK: true},
+		{name: "unknown key", key: "unknown", wantOK: false},
+		{name: "hash itself", key: hashKey("ci-key"), wantOK: false},
+		{name: "empty", key: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := store.Authenticate(tt.key)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.wantName, got.Name)
+				assert.True(t, got.HasScope(tt.wantScope))
+			}
+		})
+	}
+
+	t.Run("read only key can not issue tokens", func(t *testing.T) {
+		got, ok := store.Authenticate("dashboard-key")
+		assert.True(t, ok)
+		assert.False(t, got.HasScope(entity.AdminScopeIssueTokens))
+	})
+}
+
+func TestNewKeyStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "unknown scope", content: `{"keys": [{"name": "a", "hash": "` + hashKey("a") + `", "scopes": ["root"]}]}`, wantErr: true},
+		{name: "no scopes", content: `{"keys": [{"name": "a", "hash": "` + hashKey("a") + `"}]}`, wantErr: true},
+		{name: "plaintext", content: `{"keys": [{"name": "a", "hash": "a", "scopes": ["read-only"]}]}`, wantErr: true},
+		{name: "short hash", content: `{"keys": [{"name": "a", "hash": "sha256:abcd", "scopes": ["read-only"]}]}`, wantErr: true},
+		{name: "no name", content: `{"keys": [{"hash": "` + hashKey("a") + `", "scopes": ["read-only"]}]}`, wantErr: true},
+		{name: "duplicate name", content: `{"keys": [{"name": "a", "hash": "` + hashKey("a") + `", "scopes": ["read-only"]}, {"name": "a", "hash": "` + hashKey("b") + `", "scopes": ["read-only"]}]}`, wantErr: true},
+		{name: "valid", content: `{"keys": [{"name": "a", "hash": "` + hashKey("a") + `", "scopes": ["read-only", "manage-jobs"]}]}`, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyStore(writeAdminKeysFile(t, tt.content), "")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewKeyStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
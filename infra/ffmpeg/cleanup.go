@@ -0,0 +1,183 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RemoveOrphanOutputs removes the output dirs of the media which are not active.
+// Completed outputs are kept for retention so that the upload can be resumed after a crash.
+func (f *FFmpeg) RemoveOrphanOutputs(active func(tenantID string, mediaID string) bool, retention time.Duration) (int, error) {
+	removed := 0
+
+	// テナントごとに分ける前の出力先
+	tempEntries, err := os.ReadDir(os.TempDir())
+	if err != nil {
+		return removed, fmt.Errorf("failed to read temp dir: %w", err)
+	}
+	for _, e := range tempEntries {
+		if !strings.HasPrefix(e.Name(), legacyOutDirPrefix) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(os.TempDir(), e.Name())); err != nil {
+			return removed, fmt.Errorf("failed to remove legacy output dir: %w", err)
+		}
+		removed++
+	}
+
+	root := filepath.Join(os.TempDir(), outDirRoot)
+	tenants, err := os.ReadDir(root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return removed, nil
+		}
+		return removed, fmt.Errorf("failed to read output dir: %w", err)
+	}
+	for _, t := range tenants {
+		tenantID, err := url.PathUnescape(t.Name())
+		if err != nil || !t.IsDir() {
+			continue
+		}
+		n, err := f.removeOrphanTenantOutputs(filepath.Join(root, t.Name()), func(mediaID string) bool {
+			return active(tenantID, mediaID)
+		}, retention)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func (f *FFmpeg) removeOrphanTenantOutputs(dir string, active func(mediaID string) bool, retention time.Duration) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read output dir: %w", err)
+	}
+	removed := 0
+	for _, e := range entries {
+		// markerはディレクトリと一緒に消す
+		name, isMarker := strings.CutSuffix(e.Name(), ".completed")
+		outDir := filepath.Join(dir, name)
+		if isMarker {
+			if _, err := os.Stat(outDir); err == nil {
+				continue
+			}
+			// ディレクトリと一緒に消した
+			if _, err := os.Stat(filepath.Join(dir, e.Name())); errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+		}
+		mediaID, err := url.PathUnescape(name)
+		if err != nil || active(mediaID) {
+			continue
+		}
+
+		// ディレクトリのないmarkerが残っているとエンコードが飛ばされるので、保持期間に関わらず消す
+		if info, err := os.Stat(completedMarker(outDir)); err == nil && !isMarker && time.Since(info.ModTime()) < retention {
+			continue
+		}
+		if err := f.RemoveOutput(outDir); err != nil {
+			return removed, fmt.Errorf("failed to remove orphan output: %w", err)
+		}
+		slog.Info("removed orphan output", slog.String("outDir", outDir))
+		removed++
+	}
+	return removed, nil
+}
+
+// RemoveOldLogs removes the ffmpeg logs which have not been written for retention.
+func (f *FFmpeg) RemoveOldLogs(active func(tenantID string, mediaID string) bool, retention time.Duration) (int, error) {
+	entries, err := os.ReadDir(f.logFileDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read log dir: %w", err)
+	}
+
+	removed := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			// テナントごとに分ける前のログ。もう書き込まれないので保持期間だけで消す
+			if _, ok := strings.CutSuffix(e.Name(), ".log"); !ok {
+				continue
+			}
+			ok, err := removeOldLog(filepath.Join(f.logFileDir, e.Name()), retention)
+			if err != nil {
+				return removed, err
+			}
+			if ok {
+				removed++
+			}
+			continue
+		}
+
+		tenantID, err := url.PathUnescape(e.Name())
+		if err != nil {
+			continue
+		}
+		n, err := f.removeOldTenantLogs(filepath.Join(f.logFileDir, e.Name()), func(mediaID string) bool {
+			return active(tenantID, mediaID)
+		}, retention)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func (f *FFmpeg) removeOldTenantLogs(dir string, active func(mediaID string) bool, retention time.Duration) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read log dir: %w", err)
+	}
+	removed := 0
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".log")
+		if !ok || e.IsDir() {
+			continue
+		}
+		mediaID, err := url.PathUnescape(name)
+		if err != nil || active(mediaID) {
+			continue
+		}
+		ok, err = removeOldLog(filepath.Join(dir, e.Name()), retention)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// removeOldLog removes the log if it has not been written for retention, and reports whether it was removed.
+func removeOldLog(path string, retention time.Duration) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat log file: %w", err)
+	}
+	if time.Since(info.ModTime()) < retention {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to remove log file: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,109 @@
+package ffmpeg
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+)
+
+// stderrPatterns are matched case-insensitively. The former ones take precedence,
+// e.g. a write failure caused by disk full is not reported as invalid data.
+var stderrPatterns = []struct {
+	err      error
+	patterns []string
+}{
+	{domain.ErrDiskFull, []string{
+		"no space left on device",
+	}},
+	{domain.ErrHardwareInit, []string{
+		"device creation failed",
+		"failed to initialise vaapi",
+		"error creating a mfx session",
+		"hwaccel initialisation returned error",
+		"no device available for decoder",
+		"cannot load libcuda",
+		"failed to create d3d11va",
+	}},
+	{domain.ErrNoMediaStreams, []string{
+		"does not contain any stream",
+		"matches no streams",
+	}},
+	{domain.ErrUnsupportedCodec, []string{
+		"decoder (codec",
+		"unknown decoder",
+		"unsupported codec",
+		"codec not currently supported",
+		"could not find tag for codec",
+	}},
+	{domain.ErrInvalidMediaInput, []string{
+		"invalid data found when processing input",
+		"moov atom not found",
+		"end of file",
+		"could not find codec parameters",
+		"error while decoding",
+		"corrupt",
+	}},
+}
+
+// fatalLineCount is the number of the last lines of stderr which are classified.
+// ffmpeg prints the fatal errors at the end, while warnings in the middle (e.g. a corrupt frame) do not stop it.
+const fatalLineCount = 5
+
+// conversionFailed is printed by ffmpeg after the fatal errors.
+const conversionFailed = "conversion failed!"
+
+// classifyStderr returns the line of stderr which shows the cause of the failure and its domain error.
+// Only the fatal lines at the end of stderr are classified. It returns nil if the reason is unknown.
+func classifyStderr(stderr string) (string, error) {
+	lines := fatalLines(stderr)
+	for _, p := range stderrPatterns {
+		for _, line := range lines {
+			lower := strings.ToLower(line)
+			for _, pattern := range p.patterns {
+				if strings.Contains(lower, pattern) {
+					return strings.TrimSpace(line), p.err
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// fatalLines returns the last fatalLineCount lines before "Conversion failed!", or the last lines of stderr if it is not printed.
+func fatalLines(stderr string) []string {
+	// 進捗は\rで上書きされる
+	lines := strings.FieldsFunc(stderr, func(r rune) bool {
+		return r == '\n' || r == '\r'
+	})
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.EqualFold(strings.TrimSpace(lines[i]), conversionFailed) {
+			lines = lines[:i]
+			break
+		}
+	}
+
+	fatal := make([]string, 0, fatalLineCount)
+	for i := len(lines) - 1; i >= 0 && len(fatal) < fatalLineCount; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || isProgressLine(line) {
+			continue
+		}
+		fatal = append(fatal, lines[i])
+	}
+	slices.Reverse(fatal)
+	return fatal
+}
+
+func isProgressLine(line string) bool {
+	return strings.HasPrefix(line, "frame=") || strings.HasPrefix(line, "size=")
+}
+
+// lastLines returns the last n lines of s, to log the cause of a failure without the whole progress output.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
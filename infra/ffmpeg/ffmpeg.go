@@ -2,20 +2,35 @@ package ffmpeg
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
 	"github.com/walnuts1018/mpeg-dash-encoder/util/fileutil"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/mpd"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/random"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	outDirPrefix = "mpeg-dash-encoder-outdir"
+	// 出力先は <outDirRoot>/<テナントID>/<メディアID>
+	outDirRoot = "mpeg-dash-encoder-output"
+	// テナントごとに分ける前の出力先の接頭辞
+	legacyOutDirPrefix   = "mpeg-dash-encoder-outdir"
+	segmentVersionLength = 8
 )
 
 type FFmpeg struct {
@@ -47,12 +62,40 @@ func NewFFMPEG(cfg config.FFmpegConfig) (*FFmpeg, error) {
 }
 
 func (f *FFmpeg) GetOutDirPrefix() string {
-	return outDirPrefix
+	return outDirRoot
 }
 
-func (f *FFmpeg) createArgs(inputFileName, outputDirectory string, audioOnly bool) ([]string, error) {
+// Version returns the first line of `ffmpeg -version`, recorded in the integrity manifest.
+func (f *FFmpeg) Version(ctx context.Context) (string, error) {
+	return binaryVersion(ctx, "ffmpeg")
+}
+
+// createArgs creates the ffmpeg args. segmentVersion is put in the segment names,
+// so that a new encode of the media does not overwrite the segments the published manifest refers to.
+func (f *FFmpeg) createArgs(inputFileName, outputDirectory, segmentVersion string, profile entity.EncodingProfile) ([]string, error) {
 	args := make([]string, 0, 65)
 
+	audioOnly := profile.AudioOnly
+	preset := f.preset
+	if profile.Preset != "" {
+		preset = config.FFmpegPreset(profile.Preset)
+	}
+	fps := f.fps
+	if profile.FPS > 0 {
+		fps = strconv.Itoa(profile.FPS)
+	}
+	videoQualityKeys := f.videoQualityKeys
+	if len(profile.Qualities) > 0 {
+		videoQualityKeys = make([]VideoQualityKey, 0, len(profile.Qualities))
+		for _, q := range profile.Qualities {
+			key := VideoQualityKey(q)
+			if _, ok := videoQualities[key]; !ok {
+				return nil, fmt.Errorf("unknown video quality: %s", q)
+			}
+			videoQualityKeys = append(videoQualityKeys, key)
+		}
+	}
+
 	// hwaccel option
 	switch f.hwAccel {
 	case config.FFmpegHWAccelQSV:
@@ -62,6 +105,15 @@ func (f *FFmpeg) createArgs(inputFileName, outputDirectory string, audioOnly boo
 		)
 	}
 
+	// presigned URLから読む場合、接続が切れてもRangeリクエストで再開する
+	if isHTTPInput(inputFileName) {
+		args = append(args,
+			"-reconnect", "1",
+			"-reconnect_on_network_error", "1",
+			"-reconnect_delay_max", "30",
+		)
+	}
+
 	args = append(args,
 		"-i", inputFileName,
 		"-y",
@@ -71,7 +123,7 @@ func (f *FFmpeg) createArgs(inputFileName, outputDirectory string, audioOnly boo
 
 	if !audioOnly {
 		args = append(args,
-			"-preset", string(f.preset),
+			"-preset", string(preset),
 			"-keyint_min", "100",
 			"-g", "100",
 			"-sc_threshold", "0",
@@ -87,7 +139,7 @@ func (f *FFmpeg) createArgs(inputFileName, outputDirectory string, audioOnly boo
 	}
 
 	args = append(args,
-		"-r", f.fps,
+		"-r", fps,
 		"-c:v", videoCodec,
 		"-c:a", f.audioCodec,
 	)
@@ -98,7 +150,7 @@ func (f *FFmpeg) createArgs(inputFileName, outputDirectory string, audioOnly boo
 	}
 
 	if !audioOnly {
-		for i, quality := range f.videoQualityKeys {
+		for i, quality := range videoQualityKeys {
 			var videoFilter string
 			switch f.hwAccel {
 			case config.FFmpegHWAccelQSV:
@@ -119,8 +171,8 @@ func (f *FFmpeg) createArgs(inputFileName, outputDirectory string, audioOnly boo
 
 	args = append(args,
 		"-map", "0:a",
-		"-init_seg_name", `init$RepresentationID$.$ext$`,
-		"-media_seg_name", `chunk$RepresentationID$-$Number%05d$.$ext$`,
+		"-init_seg_name", "init$RepresentationID$-"+segmentVersion+".$ext$",
+		"-media_seg_name", "chunk$RepresentationID$-"+segmentVersion+"-$Number%05d$.$ext$",
 		"-use_template", "1",
 		"-use_timeline", "1",
 		"-seg_duration", "4",
@@ -136,43 +188,117 @@ func (f *FFmpeg) createArgs(inputFileName, outputDirectory string, audioOnly boo
 		)
 	}
 
-	args = append(args, "-f", "dash", filepath.Join(outputDirectory, "dash.mpd"))
+	args = append(args, "-f", "dash", filepath.Join(outputDirectory, mpd.FileName))
 	return args, nil
 }
 
-func (f *FFmpeg) Encode(mediaID string, sourceFilePath string, audioOnly bool) (string, error) {
-	outDir, err := os.MkdirTemp("", outDirPrefix)
-	if err != nil {
-		return "", err
+func isHTTPInput(input string) bool {
+	return strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://")
+}
+
+// Encode runs ffmpeg. The args and the exit code are recorded to the span in ctx.
+// The output of the same version of the source completed before (e.g. by a run crashed while uploading) is reused.
+func (f *FFmpeg) Encode(ctx context.Context, source entity.EncodeSource, profile entity.EncodingProfile) (string, error) {
+	// アップロード中にクラッシュした場合に再エンコードしないよう、出力先はテナントとメディアIDごとに固定する
+	outDir := f.outDir(source.TenantID, source.MediaID)
+	if version, err := os.ReadFile(completedMarker(outDir)); err == nil && source.Version != "" && string(version) == source.Version {
+		slog.Info("encoded output already exists, skip encoding", slog.String("tenantID", source.TenantID), slog.String("mediaID", source.MediaID), slog.String("outDir", outDir))
+		return outDir, nil
+	}
+	// 置き換えられたソースの出力は使わない
+	if err := f.RemoveOutput(outDir); err != nil {
+		return "", fmt.Errorf("failed to remove incomplete output: %w", err)
+	}
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create output dir: %w", err)
 	}
 
-	args, err := f.createArgs(sourceFilePath, outDir, audioOnly)
+	segmentVersion, err := random.String(segmentVersionLength, random.LowerLetters+random.Numbers)
+	if err != nil {
+		return "", fmt.Errorf("failed to create segment version: %w", err)
+	}
+	args, err := f.createArgs(source.Input, outDir, segmentVersion, profile)
 	if err != nil {
 		return "", err
 	}
-	slog.Debug("ffmpeg args", slog.Any("args", args))
+	// presigned URLの署名をログやspanに残さない
+	redactedArgs := redactArgs(args, source.Input)
+	slog.Debug("ffmpeg args", slog.Any("args", redactedArgs))
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.StringSlice("ffmpeg.args", redactedArgs))
 
-	cmd := exec.Command("ffmpeg", args...)
+	// シャットダウンでctxがキャンセルされた場合はffmpegを止める
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	cmd.Dir = outDir
 
-	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 
-	logfile, err := fileutil.CreateFileRecursive(filepath.Join(f.logFileDir, mediaID+".log"))
+	logfile, err := fileutil.CreateFileRecursive(f.LogFilePath(source.TenantID, source.MediaID))
 	if err != nil {
 		return "", fmt.Errorf("failed to create log file: %w", err)
 	}
+	defer logfile.Close()
 
-	cmd.Stdout = io.MultiWriter(logfile, &stdout)
-	cmd.Stderr = io.MultiWriter(logfile, &stderr)
+	stderrWriter := newRedactWriter(io.MultiWriter(logfile, &stderr), source.Input)
+	cmd.Stdout = logfile
+	cmd.Stderr = stderrWriter
 
-	if err := cmd.Run(); err != nil {
+	err = cmd.Run()
+	if err := stderrWriter.Flush(); err != nil {
+		slog.Warn("failed to write ffmpeg log", slog.Any("error", err))
+		// returnしない
+	}
+	if cmd.ProcessState != nil {
+		span.SetAttributes(attribute.Int("ffmpeg.exit_code", cmd.ProcessState.ExitCode()))
+	}
+	if err != nil {
+		// 全体はログファイルに残っている
 		slog.Error("ffmpeg error",
-			slog.String("stdout", stdout.String()),
-			slog.String("stderr", stderr.String()),
+			slog.String("mediaID", source.MediaID),
+			slog.String("stderr", lastLines(stderr.String(), 20)),
 		)
+		if err := os.RemoveAll(outDir); err != nil {
+			slog.Error("failed to remove incomplete output", slog.String("outDir", outDir), slog.Any("error", err))
+			// returnしない
+		}
+		if ctx.Err() == nil {
+			if line, reason := classifyStderr(stderr.String()); reason != nil {
+				// URLからの読み込みは通信の失敗でも壊れた入力に見えるので、リトライする
+				if isHTTPInput(source.Input) && errors.Is(reason, domain.ErrInvalidMediaInput) {
+					return "", fmt.Errorf("failed to run ffmpeg: %s: %w", line, err)
+				}
+				return "", fmt.Errorf("failed to run ffmpeg: %w: %s: %w", reason, line, err)
+			}
+		}
 		return "", fmt.Errorf("failed to run ffmpeg: %w", err)
 	}
 
+	if err := os.WriteFile(completedMarker(outDir), []byte(source.Version), 0o600); err != nil {
+		return "", fmt.Errorf("failed to create completed marker: %w", err)
+	}
 	return outDir, nil
 }
+
+// LogFilePath returns the path of the log written by Encode.
+// Logs are separated by tenant, since media IDs are unique only within a tenant.
+func (f *FFmpeg) LogFilePath(tenantID string, mediaID string) string {
+	return filepath.Join(f.logFileDir, url.PathEscape(tenantID), url.PathEscape(mediaID)+".log")
+}
+
+func (f *FFmpeg) outDir(tenantID string, mediaID string) string {
+	return filepath.Join(os.TempDir(), outDirRoot, url.PathEscape(tenantID), url.PathEscape(mediaID))
+}
+
+// completedMarker is created next to the output dir so that it is not uploaded with the output.
+// It contains the version of the source.
+func completedMarker(outDir string) string {
+	return outDir + ".completed"
+}
+
+// RemoveOutput removes the output dir returned by Encode.
+func (f *FFmpeg) RemoveOutput(outDir string) error {
+	if err := os.Remove(completedMarker(outDir)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove completed marker: %w", err)
+	}
+	return os.RemoveAll(outDir)
+}
@@ -1,15 +1,23 @@
 package ffmpeg
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
 	"github.com/walnuts1018/mpeg-dash-encoder/util/random"
 )
 
@@ -36,7 +44,7 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 	type args struct {
 		inputFileName   string
 		outputDirectory string
-		audioOnly       bool
+		profile         entity.EncodingProfile
 	}
 	tests := []struct {
 		name   string
@@ -56,7 +64,7 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 			args: args{
 				inputFileName:   "input.mp4",
 				outputDirectory: "Dash",
-				audioOnly:       false,
+				profile:         entity.EncodingProfile{},
 			},
 			want: []string{
 				"-i", "input.mp4",
@@ -94,8 +102,8 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 				"-bufsize:2", "14M",
 
 				"-map", "0:a",
-				"-init_seg_name", `init$RepresentationID$.$ext$`,
-				"-media_seg_name", `chunk$RepresentationID$-$Number%05d$.$ext$`,
+				"-init_seg_name", `init$RepresentationID$-v1.$ext$`,
+				"-media_seg_name", `chunk$RepresentationID$-v1-$Number%05d$.$ext$`,
 				"-use_template", "1",
 				"-use_timeline", "1",
 				"-seg_duration", "4",
@@ -116,7 +124,7 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 			args: args{
 				inputFileName:   "input.mp4",
 				outputDirectory: "Dash",
-				audioOnly:       false,
+				profile:         entity.EncodingProfile{},
 			},
 			want: []string{
 				"-hwaccel", "qsv",
@@ -155,8 +163,8 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 				"-bufsize:2", "14M",
 
 				"-map", "0:a",
-				"-init_seg_name", `init$RepresentationID$.$ext$`,
-				"-media_seg_name", `chunk$RepresentationID$-$Number%05d$.$ext$`,
+				"-init_seg_name", `init$RepresentationID$-v1.$ext$`,
+				"-media_seg_name", `chunk$RepresentationID$-v1-$Number%05d$.$ext$`,
 				"-use_template", "1",
 				"-use_timeline", "1",
 				"-seg_duration", "4",
@@ -177,7 +185,7 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 			args: args{
 				inputFileName:   "input.mp4",
 				outputDirectory: "Dash",
-				audioOnly:       true,
+				profile:         entity.EncodingProfile{AudioOnly: true},
 			},
 			want: []string{
 				"-i", "input.mp4",
@@ -189,8 +197,8 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 				"-c:a", "aac",
 				"-pix_fmt", "yuv420p",
 				"-map", "0:a",
-				"-init_seg_name", `init$RepresentationID$.$ext$`,
-				"-media_seg_name", `chunk$RepresentationID$-$Number%05d$.$ext$`,
+				"-init_seg_name", `init$RepresentationID$-v1.$ext$`,
+				"-media_seg_name", `chunk$RepresentationID$-v1-$Number%05d$.$ext$`,
 				"-use_template", "1",
 				"-use_timeline", "1",
 				"-seg_duration", "4",
@@ -211,7 +219,7 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 			args: args{
 				inputFileName:   "input.mp4",
 				outputDirectory: "Dash",
-				audioOnly:       true,
+				profile:         entity.EncodingProfile{AudioOnly: true},
 			},
 			want: []string{
 				"-hwaccel", "qsv",
@@ -224,8 +232,92 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 				"-c:v", "h264_qsv",
 				"-c:a", "aac",
 				"-map", "0:a",
-				"-init_seg_name", `init$RepresentationID$.$ext$`,
-				"-media_seg_name", `chunk$RepresentationID$-$Number%05d$.$ext$`,
+				"-init_seg_name", `init$RepresentationID$-v1.$ext$`,
+				"-media_seg_name", `chunk$RepresentationID$-v1-$Number%05d$.$ext$`,
+				"-use_template", "1",
+				"-use_timeline", "1",
+				"-seg_duration", "4",
+				"-adaptation_sets", `id=0,streams=a`,
+				"-f", "dash",
+				filepath.Join("Dash", "dash.mpd"),
+			},
+		},
+		{
+			name: "profile override, no hwAccel",
+			ffmpeg: config.FFmpegConfig{
+				LogDir:     "./log",
+				FPS:        30,
+				Preset:     config.Veryslow,
+				HWAccel:    config.FFmpegHWAccelNone,
+				AudioCodec: "aac",
+			},
+			args: args{
+				inputFileName:   "input.mp4",
+				outputDirectory: "Dash",
+				profile: entity.EncodingProfile{
+					Preset:    "fast",
+					FPS:       24,
+					Qualities: []string{"720p"},
+				},
+			},
+			want: []string{
+				"-i", "input.mp4",
+				"-y",
+				"-hide_banner",
+				"-progress", "-",
+				"-preset", "fast",
+				"-keyint_min", "100",
+				"-g", "100",
+				"-sc_threshold", "0",
+				"-r", "24",
+				"-c:v", "libx264",
+				"-c:a", "aac",
+				"-pix_fmt", "yuv420p",
+				"-map", "v:0?",
+				"-filter:v:0", "scale=-1:720",
+				"-b:v:0", "4.5M",
+				"-maxrate:0", "4.8M",
+				"-bufsize:0", "8M",
+				"-map", "0:a",
+				"-init_seg_name", `init$RepresentationID$-v1.$ext$`,
+				"-media_seg_name", `chunk$RepresentationID$-v1-$Number%05d$.$ext$`,
+				"-use_template", "1",
+				"-use_timeline", "1",
+				"-seg_duration", "4",
+				"-adaptation_sets", `id=0,streams=a id=1,streams=v`,
+				"-f", "dash",
+				filepath.Join("Dash", "dash.mpd"),
+			},
+		},
+		{
+			name: "presigned url input, audio only",
+			ffmpeg: config.FFmpegConfig{
+				LogDir:     "./log",
+				FPS:        30,
+				Preset:     config.Veryslow,
+				HWAccel:    config.FFmpegHWAccelNone,
+				AudioCodec: "aac",
+			},
+			args: args{
+				inputFileName:   "https://minio.example.com/source/input.mp3?X-Amz-Signature=xxx",
+				outputDirectory: "Dash",
+				profile:         entity.EncodingProfile{AudioOnly: true},
+			},
+			want: []string{
+				"-reconnect", "1",
+				"-reconnect_on_network_error", "1",
+				"-reconnect_delay_max", "30",
+				"-i", "https://minio.example.com/source/input.mp3?X-Amz-Signature=xxx",
+				"-y",
+				"-hide_banner",
+				"-progress", "-",
+				"-r", "30",
+				"-c:v", "libx264",
+				"-c:a", "aac",
+				"-pix_fmt", "yuv420p",
+				"-map", "0:a",
+				"-init_seg_name", `init$RepresentationID$-v1.$ext$`,
+				"-media_seg_name", `chunk$RepresentationID$-v1-$Number%05d$.$ext$`,
 				"-use_template", "1",
 				"-use_timeline", "1",
 				"-seg_duration", "4",
@@ -241,11 +333,18 @@ func TestFFMPEG_CreateArgs(t *testing.T) {
 			assert.NoError(t, err)
 			assert.NotNil(t, f)
 
-			got, err := f.createArgs(tt.args.inputFileName, tt.args.outputDirectory, tt.args.audioOnly)
+			got, err := f.createArgs(tt.args.inputFileName, tt.args.outputDirectory, "v1", tt.args.profile)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
+
+	t.Run("unknown quality", func(t *testing.T) {
+		f, err := NewFFMPEG(config.FFmpegConfig{FPS: 30, HWAccel: config.FFmpegHWAccelNone})
+		assert.NoError(t, err)
+		_, err = f.createArgs("input.mp4", "Dash", "v1", entity.EncodingProfile{Qualities: []string{"4k"}})
+		assert.Error(t, err)
+	})
 }
 
 func TestFFMPEG_Encode(t *testing.T) {
@@ -262,9 +361,9 @@ func TestFFMPEG_Encode(t *testing.T) {
 	}
 
 	type args struct {
-		id        string
-		path      string
-		audioOnly bool
+		id      string
+		path    string
+		profile entity.EncodingProfile
 	}
 	type test struct {
 		name    string
@@ -283,9 +382,9 @@ func TestFFMPEG_Encode(t *testing.T) {
 		tests = append(tests, test{
 			name: k,
 			args: args{
-				id:        id,
-				path:      path.Join(testFilesDir, v),
-				audioOnly: false,
+				id:      id,
+				path:    path.Join(testFilesDir, v),
+				profile: entity.EncodingProfile{},
 			},
 			wantErr: false,
 		})
@@ -300,9 +399,9 @@ func TestFFMPEG_Encode(t *testing.T) {
 		tests = append(tests, test{
 			name: k,
 			args: args{
-				id:        id,
-				path:      path.Join(testFilesDir, v),
-				audioOnly: true,
+				id:      id,
+				path:    path.Join(testFilesDir, v),
+				profile: entity.EncodingProfile{AudioOnly: true},
 			},
 			wantErr: false,
 		})
@@ -316,7 +415,12 @@ func TestFFMPEG_Encode(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			hlsDir, err := f.Encode(tt.args.id, filepath.Join(workdir, tt.args.path), tt.args.audioOnly)
+			hlsDir, err := f.Encode(context.Background(), entity.EncodeSource{
+				TenantID: entity.DefaultTenantID,
+				MediaID:  tt.args.id,
+				Version:  "1",
+				Input:    filepath.Join(workdir, tt.args.path),
+			}, tt.args.profile)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("FFMPEG.Encode() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -325,3 +429,368 @@ func TestFFMPEG_Encode(t *testing.T) {
 		})
 	}
 }
+
+func TestParseProbeOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    entity.MediaInfo
+		wantErr bool
+	}{
+		{
+			name:   "normal",
+			output: `{"format": {"duration": "30.526667"}}`,
+			want:   entity.MediaInfo{Duration: 30526667 * time.Microsecond, FormatNames: []string{}, Streams: []entity.MediaStream{}},
+		},
+		{
+			name: "streams",
+			output: `{
+				"streams": [
+					{"codec_name": "h264", "codec_type": "video", "width": 1920, "height": 1080},
+					{"codec_name": "aac", "codec_type": "audio"}
+				],
+				"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "30.526667"}
+			}`,
+			want: entity.MediaInfo{
+				Duration:    30526667 * time.Microsecond,
+				FormatNames: []string{"mov", "mp4", "m4a", "3gp", "3g2", "mj2"},
+				Streams: []entity.MediaStream{
+					{Type: entity.MediaStreamTypeVideo, Codec: "h264", Width: 1920, Height: 1080},
+					{Type: entity.MediaStreamTypeAudio, Codec: "aac"},
+				},
+			},
+		},
+		{
+			name:   "no duration",
+			output: `{"format": {"format_name": "mpegts"}}`,
+			want:   entity.MediaInfo{FormatNames: []string{"mpegts"}, Streams: []entity.MediaStream{}},
+		},
+		{
+			name:   "N/A",
+			output: `{"format": {"duration": "N/A"}}`,
+			want:   entity.MediaInfo{FormatNames: []string{}, Streams: []entity.MediaStream{}},
+		},
+		{
+			name:    "invalid duration",
+			output:  `{"format": {"duration": "thirty"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			output:  `duration=30.5`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProbeOutput([]byte(tt.output))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFFMPEG_Probe_Error(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffprobe is a shell script")
+	}
+	f, err := NewFFMPEG(config.FFmpegConfig{LogDir: t.TempDir(), FPS: 30, HWAccel: config.FFmpegHWAccelNone})
+	assert.NoError(t, err)
+
+	binDir := t.TempDir()
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	writeFFprobe := func(script string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(binDir, "ffprobe"), []byte("#!/bin/sh\n"+script), 0o755))
+	}
+	input := "https://minio.example.com/source/movie-1?X-Amz-Signature=abcdef"
+
+	// 読めない原因は入力とは限らないので、リトライできる失敗にする
+	t.Run("exit code", func(t *testing.T) {
+		writeFFprobe("echo \"$7: Server returned 403 Forbidden\" >&2\nexit 1\n")
+		_, err := f.Probe(context.Background(), input)
+		assert.Error(t, err)
+		assert.False(t, entity.EncodeFailureReasonOf(err).Permanent())
+		assert.Contains(t, err.Error(), "Server returned 403 Forbidden")
+		assert.NotContains(t, err.Error(), "X-Amz-Signature")
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		writeFFprobe("echo 'duration=30.5'\n")
+		_, err := f.Probe(context.Background(), input)
+		assert.Error(t, err)
+		assert.False(t, entity.EncodeFailureReasonOf(err).Permanent())
+	})
+}
+
+func TestFFMPEG_RemoveOrphanOutputs(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	f, err := NewFFMPEG(config.FFmpegConfig{FPS: 30, HWAccel: config.FFmpegHWAccelNone})
+	assert.NoError(t, err)
+
+	createOutput := func(tenantID string, mediaID string, completed bool) string {
+		outDir := f.outDir(tenantID, mediaID)
+		assert.NoError(t, os.MkdirAll(outDir, os.ModePerm))
+		assert.NoError(t, os.WriteFile(filepath.Join(outDir, "manifest.mpd"), []byte("mpd"), 0o644))
+		if completed {
+			assert.NoError(t, os.WriteFile(completedMarker(outDir), []byte("1"), 0o644))
+		}
+		return outDir
+	}
+	active := createOutput("default", "active", false)
+	otherTenant := createOutput("team-a", "active", false)
+	incomplete := createOutput("default", "incomplete", false)
+	completed := createOutput("default", "completed", true)
+	expired := createOutput("default", "expired", true)
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(completedMarker(expired), old, old))
+	dangling := completedMarker(f.outDir("default", "dangling"))
+	assert.NoError(t, os.WriteFile(dangling, nil, 0o644))
+	legacy, err := os.MkdirTemp("", legacyOutDirPrefix)
+	assert.NoError(t, err)
+
+	removed, err := f.RemoveOrphanOutputs(func(tenantID string, mediaID string) bool {
+		return tenantID == "default" && mediaID == "active"
+	}, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, removed)
+
+	assert.DirExists(t, active)
+	assert.NoDirExists(t, otherTenant)
+	assert.DirExists(t, completed)
+	assert.FileExists(t, completedMarker(completed))
+	assert.NoDirExists(t, incomplete)
+	assert.NoDirExists(t, expired)
+	assert.NoFileExists(t, completedMarker(expired))
+	assert.NoFileExists(t, dangling)
+	assert.NoDirExists(t, legacy)
+}
+
+func TestFFMPEG_Encode_Reuse(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	f, err := NewFFMPEG(config.FFmpegConfig{LogDir: t.TempDir(), FPS: 30, HWAccel: config.FFmpegHWAccelNone})
+	assert.NoError(t, err)
+
+	source := entity.EncodeSource{TenantID: "default", MediaID: "movie-1", Version: "etag-1", Input: filepath.Join(t.TempDir(), "not-exist.mp4")}
+	outDir := f.outDir(source.TenantID, source.MediaID)
+	assert.NoError(t, os.MkdirAll(outDir, os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(outDir, "dash.mpd"), []byte("mpd"), 0o644))
+	assert.NoError(t, os.WriteFile(completedMarker(outDir), []byte(source.Version), 0o644))
+
+	got, err := f.Encode(context.Background(), source, entity.EncodingProfile{})
+	assert.NoError(t, err)
+	assert.Equal(t, outDir, got)
+	assert.FileExists(t, filepath.Join(outDir, "dash.mpd"))
+
+	t.Run("other tenant", func(t *testing.T) {
+		other := source
+		other.TenantID = "team-a"
+		assert.NotEqual(t, outDir, f.outDir(other.TenantID, other.MediaID))
+	})
+
+	t.Run("replaced source", func(t *testing.T) {
+		replaced := source
+		replaced.Version = "etag-2"
+		// 入力がないのでffmpegは失敗する
+		_, err := f.Encode(context.Background(), replaced, entity.EncodingProfile{})
+		assert.Error(t, err)
+		assert.NoFileExists(t, filepath.Join(outDir, "dash.mpd"))
+		assert.NoFileExists(t, completedMarker(outDir))
+	})
+}
+
+func TestFFMPEG_Encode_InputError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	logDir := t.TempDir()
+	f, err := NewFFMPEG(config.FFmpegConfig{LogDir: logDir, FPS: 30, HWAccel: config.FFmpegHWAccelNone})
+	assert.NoError(t, err)
+
+	// 入力を読めずに失敗するffmpeg
+	binDir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"for arg; do if [ \"$prev\" = \"-i\" ]; then input=\"$arg\"; fi; prev=\"$arg\"; done\n" +
+		"echo \"$input: Invalid data found when processing input\" >&2\n" +
+		"exit 1\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(binDir, "ffmpeg"), []byte(script), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	t.Run("file", func(t *testing.T) {
+		source := entity.EncodeSource{TenantID: "default", MediaID: "movie-1", Input: "/tmp/source.mp4"}
+		_, err := f.Encode(context.Background(), source, entity.EncodingProfile{})
+		assert.ErrorIs(t, err, domain.ErrInvalidMediaInput)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		source := entity.EncodeSource{
+			TenantID: "default",
+			MediaID:  "movie-2",
+			Input:    "https://minio.example.com/source/movie-2?X-Amz-Signature=abcdef",
+		}
+		_, err := f.Encode(context.Background(), source, entity.EncodingProfile{})
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, domain.ErrInvalidMediaInput, "read errors of a url may be caused by the network")
+		assert.NotContains(t, err.Error(), "X-Amz-Signature")
+
+		log, err := os.ReadFile(f.LogFilePath(source.TenantID, source.MediaID))
+		assert.NoError(t, err)
+		assert.Contains(t, string(log), "https://minio.example.com/source/movie-2: Invalid data found")
+		assert.NotContains(t, string(log), "X-Amz-Signature")
+	})
+}
+
+func TestFFMPEG_RemoveOldLogs(t *testing.T) {
+	logDir := t.TempDir()
+	f, err := NewFFMPEG(config.FFmpegConfig{LogDir: logDir, FPS: 30, HWAccel: config.FFmpegHWAccelNone})
+	assert.NoError(t, err)
+
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	paths := map[string]string{
+		"active":       f.LogFilePath("team-a", "active"),
+		"other tenant": f.LogFilePath("team-b", "active"),
+		"old":          f.LogFilePath("team-a", "old"),
+		"new":          f.LogFilePath("team-a", "new"),
+		"other":        filepath.Join(logDir, "team-a", "other.txt"),
+		"legacy":       filepath.Join(logDir, "active.log"),
+		"legacy new":   filepath.Join(logDir, "new.log"),
+	}
+	for name, path := range paths {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte("log"), 0o644))
+		if name != "new" && name != "legacy new" {
+			assert.NoError(t, os.Chtimes(path, old, old))
+		}
+	}
+	assert.Equal(t, filepath.Join(logDir, "team-a", "active.log"), paths["active"])
+
+	removed, err := f.RemoveOldLogs(func(tenantID string, mediaID string) bool {
+		return tenantID == "team-a" && mediaID == "active"
+	}, 7*24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	assert.FileExists(t, paths["active"])
+	assert.FileExists(t, paths["new"])
+	assert.FileExists(t, paths["other"])
+	assert.FileExists(t, paths["legacy new"])
+	assert.NoFileExists(t, paths["other tenant"], "the same media ID of another tenant is not active")
+	assert.NoFileExists(t, paths["old"])
+	assert.NoFileExists(t, paths["legacy"])
+
+	t.Run("log dir does not exist", func(t *testing.T) {
+		f, err := NewFFMPEG(config.FFmpegConfig{LogDir: filepath.Join(logDir, "missing"), FPS: 30, HWAccel: config.FFmpegHWAccelNone})
+		assert.NoError(t, err)
+		removed, err := f.RemoveOldLogs(func(string, string) bool { return false }, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, removed)
+	})
+}
+
+func TestClassifyStderr(t *testing.T) {
+	tests := []struct {
+		name     string
+		stderr   string
+		wantLine string
+		wantErr  error
+	}{
+		{
+			name:     "invalid data",
+			stderr:   "ffmpeg version 7.1\n[in#0 @ 0x1] Error opening input: Invalid data found when processing input\nError opening input file broken.mp4.\n",
+			wantLine: "[in#0 @ 0x1] Error opening input: Invalid data found when processing input",
+			wantErr:  domain.ErrInvalidMediaInput,
+		},
+		{
+			name:     "unsupported codec",
+			stderr:   "Stream #0:0: Video: none\nDecoder (codec none) not found for input stream #0:0\n",
+			wantLine: "Decoder (codec none) not found for input stream #0:0",
+			wantErr:  domain.ErrUnsupportedCodec,
+		},
+		{
+			name:     "no streams",
+			stderr:   "Stream map '0:a' matches no streams.\nTo ignore this, add a trailing '?' to the map.\n",
+			wantLine: "Stream map '0:a' matches no streams.",
+			wantErr:  domain.ErrNoMediaStreams,
+		},
+		{
+			name:     "disk full after progress",
+			stderr:   "frame=  100 fps=30\rframe=  200 fps=30\r[dash @ 0x1] Error writing trailer: No space left on device\nav_interleaved_write_frame(): Invalid data found when processing input\n",
+			wantLine: "[dash @ 0x1] Error writing trailer: No space left on device",
+			wantErr:  domain.ErrDiskFull,
+		},
+		{
+			name:     "hardware init",
+			stderr:   "[AVHWDeviceContext @ 0x1] Error creating a MFX session: -9.\nDevice creation failed: -1313558101.\n",
+			wantLine: "[AVHWDeviceContext @ 0x1] Error creating a MFX session: -9.",
+			wantErr:  domain.ErrHardwareInit,
+		},
+		{
+			name:     "unknown",
+			stderr:   "Conversion failed!\n",
+			wantLine: "",
+			wantErr:  nil,
+		},
+		{
+			name: "warning before the fatal error",
+			stderr: "[h264 @ 0x1] error while decoding MB 10 20, bytestream -5\n" +
+				"frame=  100 fps=30\r" + strings.Repeat("[h264 @ 0x1] concealing 100 DC, 100 AC, 100 MV errors in P frame\n", fatalLineCount) +
+				"[dash @ 0x1] Error writing trailer: No space left on device\nConversion failed!\n",
+			wantLine: "[dash @ 0x1] Error writing trailer: No space left on device",
+			wantErr:  domain.ErrDiskFull,
+		},
+		{
+			name: "warning only",
+			stderr: "[h264 @ 0x1] error while decoding MB 10 20, bytestream -5\n" +
+				strings.Repeat("[h264 @ 0x1] concealing 100 DC, 100 AC, 100 MV errors in P frame\n", fatalLineCount) +
+				"Conversion failed!\n",
+			wantLine: "",
+			wantErr:  nil,
+		},
+		{
+			name:     "lines after conversion failed",
+			stderr:   "Decoder (codec none) not found for input stream #0:0\nConversion failed!\n[aac @ 0x1] Qavg: 100\n",
+			wantLine: "Decoder (codec none) not found for input stream #0:0",
+			wantErr:  domain.ErrUnsupportedCodec,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := classifyStderr(tt.stderr)
+			assert.Equal(t, tt.wantLine, line)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	input := "https://minio.example.com/source/movie-1?X-Amz-Signature=abcdef"
+	args := []string{"-reconnect", "1", "-i", input, "-f", "dash", "dash.mpd"}
+
+	got := redactArgs(args, input)
+	assert.Equal(t, []string{"-reconnect", "1", "-i", "https://minio.example.com/source/movie-1", "-f", "dash", "dash.mpd"}, got)
+	assert.Equal(t, input, args[3], "original args must not be modified")
+
+	fileArgs := []string{"-i", "/tmp/source"}
+	assert.Equal(t, fileArgs, redactArgs(fileArgs, "/tmp/source"))
+}
+
+func TestRedactWriter(t *testing.T) {
+	input := "https://minio.example.com/source/movie-1?X-Amz-Signature=abcdef"
+	var out bytes.Buffer
+	w := newRedactWriter(&out, input)
+
+	// 入力が書き込みの境界をまたいでも置き換える
+	stderr := "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from '" + input + "':\nframe=  10\rframe=  20\r" + input + ": Server returned 403 Forbidden"
+	for chunk := range slices.Chunk([]byte(stderr), 7) {
+		n, err := w.Write(chunk)
+		assert.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+	}
+	assert.NoError(t, w.Flush())
+
+	assert.NotContains(t, out.String(), "X-Amz-Signature")
+	assert.Equal(t, strings.ReplaceAll(stderr, input, "https://minio.example.com/source/movie-1"), out.String())
+}
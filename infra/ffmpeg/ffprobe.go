@@ -0,0 +1,81 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+type probeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+	} `json:"streams"`
+}
+
+func (f *FFmpeg) Probe(ctx context.Context, sourceFilePath string) (entity.MediaInfo, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=format_name,duration:stream=codec_type,codec_name,width,height",
+		"-of", "json",
+		sourceFilePath,
+	)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// 読めない原因が入力とは限らない (URLの期限切れや通信の失敗など) ので、ErrInvalidMediaInputにはしない
+		return entity.MediaInfo{}, fmt.Errorf("failed to run ffprobe: %w: %s", err, strings.TrimSpace(redactedStderr(stderr.String(), sourceFilePath)))
+	}
+	info, err := parseProbeOutput(stdout.Bytes())
+	if err != nil {
+		return entity.MediaInfo{}, err
+	}
+	return info, nil
+}
+
+func parseProbeOutput(b []byte) (entity.MediaInfo, error) {
+	var out probeOutput
+	if err := json.Unmarshal(b, &out); err != nil {
+		return entity.MediaInfo{}, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	// ストリーミング向けのコンテナなどは長さを持たない。長さが分からない場合は0にする
+	var duration time.Duration
+	if out.Format.Duration != "" && out.Format.Duration != "N/A" {
+		seconds, err := strconv.ParseFloat(out.Format.Duration, 64)
+		if err != nil {
+			return entity.MediaInfo{}, fmt.Errorf("failed to parse duration: %w", err)
+		}
+		duration = time.Duration(seconds * float64(time.Second))
+	}
+	info := entity.MediaInfo{
+		Duration:    duration,
+		FormatNames: strings.FieldsFunc(out.Format.FormatName, func(r rune) bool { return r == ',' }),
+		Streams:     make([]entity.MediaStream, 0, len(out.Streams)),
+	}
+	for _, s := range out.Streams {
+		info.Streams = append(info.Streams, entity.MediaStream{
+			Type:   entity.MediaStreamType(s.CodecType),
+			Codec:  s.CodecName,
+			Width:  s.Width,
+			Height: s.Height,
+		})
+	}
+	return info, nil
+}
@@ -0,0 +1,43 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/fileutil"
+)
+
+// Check checks that ffmpeg and ffprobe can be run and the log dir is writable.
+func (f *FFmpeg) Check(ctx context.Context) []entity.HealthCheck {
+	ffmpegVersion, ffmpegErr := binaryVersion(ctx, "ffmpeg")
+	ffprobeVersion, ffprobeErr := binaryVersion(ctx, "ffprobe")
+
+	logDirErr := os.MkdirAll(f.logFileDir, os.ModePerm)
+	if logDirErr == nil {
+		logDirErr = fileutil.CheckWritable(f.logFileDir)
+	}
+
+	return []entity.HealthCheck{
+		entity.NewHealthCheck("ffmpeg", ffmpegErr, ffmpegVersion),
+		entity.NewHealthCheck("ffprobe", ffprobeErr, ffprobeVersion),
+		entity.NewHealthCheck("ffmpeg_log_dir", logDirErr, f.logFileDir),
+	}
+}
+
+// binaryVersion returns the first line of `<name> -version` (e.g. "ffmpeg version 7.1 Copyright ...").
+func binaryVersion(ctx context.Context, name string) (string, error) {
+	out, err := exec.CommandContext(ctx, name, "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	line, _, err := bufio.NewReader(bytes.NewReader(out)).ReadLine()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s version: %w", name, err)
+	}
+	return string(line), nil
+}
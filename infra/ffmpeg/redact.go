@@ -0,0 +1,73 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// redactArgs returns the args with the input redacted, to log or record them to a span.
+func redactArgs(args []string, input string) []string {
+	redacted := entity.RedactInput(input)
+	if redacted == input {
+		return args
+	}
+	args = slices.Clone(args)
+	for i, arg := range args {
+		if arg == input {
+			args[i] = redacted
+		}
+	}
+	return args
+}
+
+// redactedStderr returns stderr with the input redacted.
+func redactedStderr(stderr string, input string) string {
+	return strings.ReplaceAll(stderr, input, entity.RedactInput(input))
+}
+
+// redactWriter replaces the input in the output of ffmpeg with the redacted one.
+// ffmpeg prints the input, e.g. "Input #0, mov,mp4,m4a,3gp,3g2,mj2, from '<input>':".
+// The input does not contain a line break, so the output is written line by line.
+type redactWriter struct {
+	w        io.Writer
+	input    []byte
+	redacted []byte
+	buf      []byte
+}
+
+func newRedactWriter(w io.Writer, input string) *redactWriter {
+	return &redactWriter{
+		w:        w,
+		input:    []byte(input),
+		redacted: []byte(entity.RedactInput(input)),
+	}
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	if bytes.Equal(r.input, r.redacted) {
+		return r.w.Write(p)
+	}
+	r.buf = append(r.buf, p...)
+	// 進捗は\rで上書きされる
+	if i := bytes.LastIndexAny(r.buf, "\r\n"); i >= 0 {
+		if _, err := r.w.Write(bytes.ReplaceAll(r.buf[:i+1], r.input, r.redacted)); err != nil {
+			return 0, err
+		}
+		r.buf = slices.Clone(r.buf[i+1:])
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line which does not end with a line break.
+func (r *redactWriter) Flush() error {
+	if len(r.buf) == 0 {
+		return nil
+	}
+	_, err := r.w.Write(bytes.ReplaceAll(r.buf, r.input, r.redacted))
+	r.buf = nil
+	return err
+}
@@ -0,0 +1,472 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/anyslice"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	// 未知のkidを受け取った際に、JWKSを再取得する最短の間隔
+	minJWKSRefetchInterval = 10 * time.Second
+	jwksFetchTimeout       = 10 * time.Second
+)
+
+var (
+	ErrUntrustedIssuer = errors.New("untrusted issuer")
+	// 同じ発行元が他のサービス向けに発行したトークンを受け入れないようにする
+	ErrTrustedIssuerAudienceRequired = errors.New("audience is required")
+)
+
+// trustedIssuersFileFormat is the format of TRUSTED_ISSUERS_FILE.
+//
+//	{
+//	  "issuers": [
+//	    {
+//	      "issuer": "https://app.example.com",
+//	      "audience": "mpeg-dash-encoder",
+//	      "jwks_url": "https://app.example.com/.well-known/jwks.json",
+//	      "media_ids_claim": "media",
+//	      "scope_claim": "scope",
+//	      "all_media_scope": "media:read:all",
+//	      "max_ttl": "24h"
+//	    }
+//	  ]
+//	}
+type trustedIssuersFileFormat struct {
+	Issuers []trustedIssuerConfig `json:"issuers"`
+}
+
+type trustedIssuerConfig struct {
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+
+	// 鍵の取得元。いずれか1つ以上が必要
+	JWKSURL             string   `json:"jwks_url"`
+	JWKSFile            string   `json:"jwks_file"`
+	PublicKeyFiles      []string `json:"public_key_files"`
+	JWKSRefreshInterval string   `json:"jwks_refresh_interval"` // e.g. "1h"
+
+	// トークンが属するテナント。省略時はdefault
+	Tenant string `json:"tenant"`
+	// 受け入れるトークンの残りの有効期間の上限。省略時はUSER_TOKEN_MAX_TTL
+	MaxTTL string `json:"max_ttl"` // e.g. "24h"
+
+	MediaIDsClaim string `json:"media_ids_claim"`
+	ScopeClaim    string `json:"scope_claim"`
+	AllMediaScope string `json:"all_media_scope"`
+}
+
+type trustedIssuer struct {
+	cfg        trustedIssuerConfig
+	maxTTL     time.Duration
+	staticKeys []verifyingKey
+	remoteKeys *remoteKeySet
+}
+
+// ExternalVerifier verifies user tokens issued by trusted third parties.
+type ExternalVerifier struct {
+	issuers map[string]*trustedIssuer
+	leeway  time.Duration
+	maxTTL  time.Duration
+}
+
+func NewExternalVerifier(trustedIssuersFile config.TrustedIssuersFile, cfg config.UserTokenConfig) (*ExternalVerifier, error) {
+	v := &ExternalVerifier{
+		issuers: make(map[string]*trustedIssuer),
+		leeway:  cfg.Leeway,
+	}
+	if trustedIssuersFile == "" {
+		return v, nil
+	}
+
+	b, err := os.ReadFile(string(trustedIssuersFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted issuers file: %w", err)
+	}
+
+	var f trustedIssuersFileFormat
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse trusted issuers file: %w", err)
+	}
+
+	baseDir := filepath.Dir(string(trustedIssuersFile))
+	for _, issuerCfg := range f.Issuers {
+		issuer, err := newTrustedIssuer(issuerCfg, baseDir, cfg.MaxTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load trusted issuer %s: %w", issuerCfg.Issuer, err)
+		}
+		if _, ok := v.issuers[issuerCfg.Issuer]; ok {
+			return nil, fmt.Errorf("duplicate trusted issuer: %s", issuerCfg.Issuer)
+		}
+		v.issuers[issuerCfg.Issuer] = issuer
+		v.maxTTL = max(v.maxTTL, issuer.maxTTL)
+	}
+	return v, nil
+}
+
+// MaxTTL returns the longest remaining lifetime of the tokens accepted from the trusted issuers.
+func (v *ExternalVerifier) MaxTTL() time.Duration {
+	return v.maxTTL
+}
+
+func newTrustedIssuer(cfg trustedIssuerConfig, baseDir string, defaultMaxTTL time.Duration) (*trustedIssuer, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("issuer is required")
+	}
+	if cfg.Audience == "" {
+		return nil, ErrTrustedIssuerAudienceRequired
+	}
+	if cfg.JWKSURL == "" && cfg.JWKSFile == "" && len(cfg.PublicKeyFiles) == 0 {
+		return nil, errors.New("jwks_url, jwks_file or public_key_files is required")
+	}
+	if cfg.Tenant == "" {
+		cfg.Tenant = entity.DefaultTenantID
+	}
+	if cfg.MediaIDsClaim == "" {
+		cfg.MediaIDsClaim = media_ids
+	}
+	if cfg.ScopeClaim == "" {
+		cfg.ScopeClaim = "scope"
+	}
+
+	issuer := &trustedIssuer{
+		cfg:    cfg,
+		maxTTL: defaultMaxTTL,
+	}
+	if cfg.MaxTTL != "" {
+		d, err := time.ParseDuration(cfg.MaxTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_ttl: %w", err)
+		}
+		issuer.maxTTL = d
+	}
+	if issuer.maxTTL <= 0 {
+		return nil, errors.New("max_ttl must be positive")
+	}
+
+	if cfg.JWKSFile != "" {
+		b, err := os.ReadFile(resolvePath(baseDir, cfg.JWKSFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwks file: %w", err)
+		}
+		keys, err := parseJWKS(b)
+		if err != nil {
+			return nil, err
+		}
+		issuer.staticKeys = append(issuer.staticKeys, keys...)
+	}
+
+	for _, keyFile := range cfg.PublicKeyFiles {
+		b, err := os.ReadFile(resolvePath(baseDir, keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key: %w", err)
+		}
+		key, err := parsePublicKeyPEM(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", keyFile, err)
+		}
+		key.id = strings.TrimSuffix(filepath.Base(keyFile), filepath.Ext(keyFile))
+		issuer.staticKeys = append(issuer.staticKeys, key)
+	}
+
+	if cfg.JWKSURL != "" {
+		refreshInterval := defaultJWKSRefreshInterval
+		if cfg.JWKSRefreshInterval != "" {
+			d, err := time.ParseDuration(cfg.JWKSRefreshInterval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jwks_refresh_interval: %w", err)
+			}
+			refreshInterval = d
+		}
+		issuer.remoteKeys = &remoteKeySet{
+			url:             cfg.JWKSURL,
+			client:          &http.Client{Timeout: jwksFetchTimeout},
+			refreshInterval: refreshInterval,
+		}
+	}
+	return issuer, nil
+}
+
+func resolvePath(baseDir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+func parseJWKS(b []byte) ([]verifyingKey, error) {
+	var jwks entity.JSONWebKeySet
+	if err := json.Unmarshal(b, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := make([]verifyingKey, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, err := parseJWK(jwk)
+		if err != nil {
+			// 未対応の鍵が混ざっていても他の鍵は使えるようにする
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks has no usable keys")
+	}
+	return keys, nil
+}
+
+func parsePublicKeyPEM(b []byte) (verifyingKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return verifyingKey{}, errors.New("invalid pem")
+	}
+
+	var publicKey crypto.PublicKey
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return verifyingKey{}, err
+	}
+
+	methods, err := signingMethodsFor(publicKey)
+	if err != nil {
+		return verifyingKey{}, err
+	}
+	return verifyingKey{
+		methods:   methods,
+		publicKey: publicKey,
+	}, nil
+}
+
+func (i *trustedIssuer) key(ctx context.Context, kid string) (verifyingKey, error) {
+	keys := i.staticKeys
+	if i.remoteKeys != nil {
+		remoteKeys, err := i.remoteKeys.get(ctx, kid)
+		if err != nil {
+			return verifyingKey{}, err
+		}
+		keys = append(keys[:len(keys):len(keys)], remoteKeys...)
+	}
+
+	if kid == "" {
+		if len(keys) == 1 {
+			return keys[0], nil
+		}
+		return verifyingKey{}, errors.New("kid is missing")
+	}
+	for _, k := range keys {
+		if k.id == kid {
+			return k, nil
+		}
+	}
+	return verifyingKey{}, fmt.Errorf("unknown kid: %s", kid)
+}
+
+func (v *ExternalVerifier) ParseUserToken(ctx context.Context, token string) (entity.UserToken, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("failed to parse token: %w", err)
+	}
+	iss, err := unverified.Claims.GetIssuer()
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("failed to parse iss: %w", err)
+	}
+	issuer, ok := v.issuers[iss]
+	if !ok {
+		return entity.UserToken{}, fmt.Errorf("%w: %s", ErrUntrustedIssuer, iss)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(asymmetricMethods()),
+		jwt.WithIssuer(iss),
+		jwt.WithLeeway(v.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithAudience(issuer.cfg.Audience),
+	}
+
+	t, err := jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := issuer.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if !key.allows(t.Method) {
+			return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+		}
+		return key.publicKey, nil
+	}, options...)
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return entity.UserToken{}, errors.New("failed to parse claims")
+	}
+
+	// 失効情報は最大TTLの間だけ保持するので、それより長く有効なトークンは受け入れない
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("failed to parse exp: %w", err)
+	}
+	if time.Until(exp.Time) > issuer.maxTTL+v.leeway {
+		return entity.UserToken{}, fmt.Errorf("token expires later than max_ttl: %s", issuer.maxTTL)
+	}
+
+	mediaIDs, err := stringsClaim(claims, issuer.cfg.MediaIDsClaim)
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("failed to parse %s: %w", issuer.cfg.MediaIDsClaim, err)
+	}
+
+	userToken, err := userTokenFromClaims(claims, mediaIDs)
+	if err != nil {
+		return entity.UserToken{}, err
+	}
+	userToken.TenantID = issuer.cfg.Tenant
+
+	if issuer.cfg.AllMediaScope != "" {
+		scopes, err := stringsClaim(claims, issuer.cfg.ScopeClaim)
+		if err != nil {
+			return entity.UserToken{}, fmt.Errorf("failed to parse %s: %w", issuer.cfg.ScopeClaim, err)
+		}
+		for _, scope := range scopes {
+			if scope == issuer.cfg.AllMediaScope {
+				userToken.AllMedia = true
+			}
+		}
+	}
+	return userToken, nil
+}
+
+// stringsClaim reads a claim which is either an array of strings or a space separated string.
+func stringsClaim(claims jwt.MapClaims, name string) ([]string, error) {
+	switch v := claims[name].(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return strings.Fields(v), nil
+	case []any:
+		return anyslice.FromAny[string](v)
+	default:
+		return nil, fmt.Errorf("unexpected type: %T", v)
+	}
+}
+
+func asymmetricMethods() []string {
+	return []string{
+		jwt.SigningMethodRS256.Alg(), jwt.SigningMethodRS384.Alg(), jwt.SigningMethodRS512.Alg(),
+		jwt.SigningMethodPS256.Alg(), jwt.SigningMethodPS384.Alg(), jwt.SigningMethodPS512.Alg(),
+		jwt.SigningMethodES256.Alg(), jwt.SigningMethodES384.Alg(), jwt.SigningMethodES512.Alg(),
+		jwt.SigningMethodEdDSA.Alg(),
+	}
+}
+
+type remoteKeySet struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	// 取得中も他の検証を止めないよう、取得はロックの外でまとめて行う
+	group singleflight.Group
+
+	mu        sync.Mutex
+	keys      []verifyingKey
+	fetchedAt time.Time
+}
+
+func (s *remoteKeySet) get(ctx context.Context, kid string) ([]verifyingKey, error) {
+	s.mu.Lock()
+	now := time.Now()
+	stale := now.Sub(s.fetchedAt) > s.refreshInterval
+	// 鍵のローテーション直後は未知のkidが来るので、間隔を空けて再取得する
+	unknown := kid != "" && !s.has(kid) && now.Sub(s.fetchedAt) > minJWKSRefetchInterval
+	keys := s.keys
+	s.mu.Unlock()
+	if !stale && !unknown {
+		return keys, nil
+	}
+
+	// 最初に呼んだリクエストがキャンセルされても、待っている他のリクエストには結果を返す
+	v, err, _ := s.group.Do("jwks", func() (any, error) {
+		return s.refresh(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]verifyingKey), nil
+}
+
+func (s *remoteKeySet) refresh(ctx context.Context) ([]verifyingKey, error) {
+	keys, fetchErr := s.fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetchedAt = time.Now()
+	if fetchErr != nil {
+		if len(s.keys) == 0 {
+			return nil, fetchErr
+		}
+		// 取得に失敗しても、前回取得した鍵で検証を続ける
+		return s.keys, nil
+	}
+	s.keys = keys
+	return s.keys, nil
+}
+
+// has must be called with s.mu held.
+func (s *remoteKeySet) has(kid string) bool {
+	for _, k := range s.keys {
+		if k.id == kid {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *remoteKeySet) fetch(ctx context.Context) ([]verifyingKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jwks request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks: %w", err)
+	}
+	return parseJWKS(b)
+}
@@ -0,0 +1,304 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+const (
+	externalIssuer   = "https://app.example.com"
+	externalAudience = "mpeg-dash-encoder"
+)
+
+func writeTrustedIssuersFile(dir string, issuers []trustedIssuerConfig) config.TrustedIssuersFile {
+	GinkgoHelper()
+
+	b, err := json.Marshal(trustedIssuersFileFormat{Issuers: issuers})
+	Expect(err).NotTo(HaveOccurred())
+
+	path := filepath.Join(dir, "issuers.json")
+	Expect(os.WriteFile(path, b, 0o600)).To(Succeed())
+	return config.TrustedIssuersFile(path)
+}
+
+func signExternalToken(method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	GinkgoHelper()
+
+	now := time.Now()
+	base := jwt.MapClaims{
+		"iss": externalIssuer,
+		"sub": "user-1",
+		"aud": externalAudience,
+		"iat": jwt.NewNumericDate(now),
+		"exp": jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	for k, v := range claims {
+		base[k] = v
+	}
+
+	token := jwt.NewWithClaims(method, base)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	Expect(err).NotTo(HaveOccurred())
+	return signed
+}
+
+var _ = Describe("ExternalVerifier", func() {
+	ctx := context.Background()
+	userTokenConfig := config.UserTokenConfig{
+		MaxTTL: 24 * time.Hour,
+		Leeway: 30 * time.Second,
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	var (
+		server   *httptest.Server
+		jwks     atomic.Value
+		requests atomic.Int32
+	)
+
+	BeforeEach(func() {
+		rsaJWK, err := publicJWK("rsa-1", "", rsaKey.Public())
+		Expect(err).NotTo(HaveOccurred())
+		jwks.Store(entity.JSONWebKeySet{Keys: []entity.JSONWebKey{rsaJWK}})
+		requests.Store(0)
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(jwks.Load())
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("JWKS URL", func() {
+		verifier, err := NewExternalVerifier(writeTrustedIssuersFile(GinkgoT().TempDir(), []trustedIssuerConfig{{
+			Issuer:        externalIssuer,
+			Audience:      externalAudience,
+			JWKSURL:       server.URL,
+			MediaIDsClaim: "media",
+		}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Valid token")
+		token := signExternalToken(jwt.SigningMethodRS256, rsaKey, "rsa-1", jwt.MapClaims{
+			"media": []string{"1", "2"},
+		})
+		userToken, err := verifier.ParseUserToken(ctx, token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userToken.MediaIDs).To(Equal([]string{"1", "2"}))
+		Expect(userToken.Subject).To(Equal("user-1"))
+		Expect(userToken.AllMedia).To(BeFalse())
+		Expect(userToken.CanAccessMedia("1", nil)).To(BeTrue())
+		Expect(userToken.CanAccessMedia("3", nil)).To(BeFalse())
+
+		By("JWKS is cached")
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests.Load()).To(Equal(int32(1)))
+
+		By("Wrong audience")
+		token = signExternalToken(jwt.SigningMethodRS256, rsaKey, "rsa-1", jwt.MapClaims{
+			"aud":   "other",
+			"media": []string{"1"},
+		})
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).To(HaveOccurred())
+
+		By("Missing audience")
+		token = signExternalToken(jwt.SigningMethodRS256, rsaKey, "rsa-1", jwt.MapClaims{
+			"aud":   nil,
+			"media": []string{"1"},
+		})
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).To(HaveOccurred())
+
+		By("Unknown issuer")
+		token = signExternalToken(jwt.SigningMethodRS256, rsaKey, "rsa-1", jwt.MapClaims{
+			"iss": "https://evil.example.com",
+		})
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).To(MatchError(ErrUntrustedIssuer))
+	})
+
+	It("Key Rotation", func() {
+		verifier, err := NewExternalVerifier(writeTrustedIssuersFile(GinkgoT().TempDir(), []trustedIssuerConfig{{
+			Issuer:   externalIssuer,
+			Audience: externalAudience,
+			JWKSURL:  server.URL,
+		}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		token := signExternalToken(jwt.SigningMethodRS256, rsaKey, "rsa-1", jwt.MapClaims{"media_ids": []string{"1"}})
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Token signed by a key which is not yet published")
+		ecJWK, err := publicJWK("ec-1", "ES256", ecKey.Public())
+		Expect(err).NotTo(HaveOccurred())
+		jwks.Store(entity.JSONWebKeySet{Keys: []entity.JSONWebKey{ecJWK}})
+
+		token = signExternalToken(jwt.SigningMethodES256, ecKey, "ec-1", jwt.MapClaims{"media_ids": []string{"1"}})
+		_, err = verifier.ParseUserToken(ctx, token)
+		// 直前に取得したばかりなので再取得しない
+		Expect(err).To(HaveOccurred())
+		Expect(requests.Load()).To(Equal(int32(1)))
+	})
+
+	It("Concurrent Fetch", func() {
+		release := make(chan struct{})
+		slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			server.Config.Handler.ServeHTTP(w, r)
+		}))
+		DeferCleanup(slowServer.Close)
+
+		verifier, err := NewExternalVerifier(writeTrustedIssuersFile(GinkgoT().TempDir(), []trustedIssuerConfig{{
+			Issuer:   externalIssuer,
+			Audience: externalAudience,
+			JWKSURL:  slowServer.URL,
+		}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		token := signExternalToken(jwt.SigningMethodRS256, rsaKey, "rsa-1", jwt.MapClaims{"media_ids": []string{"1"}})
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := verifier.ParseUserToken(ctx, token)
+				errs <- err
+			}()
+		}
+		// 全員が取得を待っている間に1回だけ取得する
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(requests.Load()).To(Equal(int32(1)))
+	})
+
+	It("Static Keys and Scope", func() {
+		dir := GinkgoT().TempDir()
+		der, err := x509.MarshalPKIXPublicKey(ecKey.Public())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "app.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)).To(Succeed())
+
+		verifier, err := NewExternalVerifier(writeTrustedIssuersFile(dir, []trustedIssuerConfig{{
+			Issuer:         externalIssuer,
+			Audience:       externalAudience,
+			PublicKeyFiles: []string{"app.pem"},
+			AllMediaScope:  "media:read:all",
+		}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Scope grants all media")
+		token := signExternalToken(jwt.SigningMethodES256, ecKey, "", jwt.MapClaims{"scope": "openid media:read:all"})
+		userToken, err := verifier.ParseUserToken(ctx, token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userToken.AllMedia).To(BeTrue())
+		Expect(userToken.CanAccessMedia("anything", nil)).To(BeTrue())
+
+		By("Without scope")
+		token = signExternalToken(jwt.SigningMethodES256, ecKey, "", jwt.MapClaims{"scope": "openid"})
+		userToken, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userToken.AllMedia).To(BeFalse())
+
+		By("Symmetric algorithms are rejected")
+		token = signExternalToken(jwt.SigningMethodHS256, der, "", jwt.MapClaims{"scope": "media:read:all"})
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).To(HaveOccurred())
+
+		By("Expired token")
+		token = signExternalToken(jwt.SigningMethodES256, ecKey, "", jwt.MapClaims{"exp": jwt.NewNumericDate(time.Now().Add(-time.Hour))})
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Max TTL", func() {
+		verifier, err := NewExternalVerifier(writeTrustedIssuersFile(GinkgoT().TempDir(), []trustedIssuerConfig{
+			{
+				Issuer:   externalIssuer,
+				Audience: externalAudience,
+				JWKSURL:  server.URL,
+				MaxTTL:   "2h",
+			},
+			{
+				Issuer:   "https://other.example.com",
+				Audience: externalAudience,
+				JWKSURL:  server.URL,
+				MaxTTL:   "720h",
+			},
+		}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifier.MaxTTL()).To(Equal(720 * time.Hour))
+
+		By("Token within max_ttl")
+		token := signExternalToken(jwt.SigningMethodRS256, rsaKey, "rsa-1", jwt.MapClaims{
+			"exp": jwt.NewNumericDate(time.Now().Add(2 * time.Hour)),
+		})
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Token which expires later than max_ttl")
+		token = signExternalToken(jwt.SigningMethodRS256, rsaKey, "rsa-1", jwt.MapClaims{
+			"exp": jwt.NewNumericDate(time.Now().Add(3 * time.Hour)),
+		})
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).To(HaveOccurred())
+
+		By("Default max_ttl")
+		verifier, err = NewExternalVerifier(writeTrustedIssuersFile(GinkgoT().TempDir(), []trustedIssuerConfig{{
+			Issuer:   externalIssuer,
+			Audience: externalAudience,
+			JWKSURL:  server.URL,
+		}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifier.MaxTTL()).To(Equal(userTokenConfig.MaxTTL))
+	})
+
+	It("Audience Required", func() {
+		_, err := NewExternalVerifier(writeTrustedIssuersFile(GinkgoT().TempDir(), []trustedIssuerConfig{{
+			Issuer:  externalIssuer,
+			JWKSURL: server.URL,
+		}}), userTokenConfig)
+		Expect(err).To(MatchError(ErrTrustedIssuerAudienceRequired))
+	})
+
+	It("No Issuers", func() {
+		verifier, err := NewExternalVerifier("", userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		token := signExternalToken(jwt.SigningMethodRS256, rsaKey, "rsa-1", jwt.MapClaims{})
+		_, err = verifier.ParseUserToken(ctx, token)
+		Expect(err).To(MatchError(ErrUntrustedIssuer))
+	})
+})
@@ -0,0 +1,174 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+func publicJWK(kid string, alg string, publicKey crypto.PublicKey) (entity.JSONWebKey, error) {
+	jwk := entity.JSONWebKey{
+		KeyID:     kid,
+		Use:       "sig",
+		Algorithm: alg,
+	}
+
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		ecdhKey, err := k.ECDH()
+		if err != nil {
+			return entity.JSONWebKey{}, fmt.Errorf("failed to convert ecdsa key: %w", err)
+		}
+		// 0x04 || X || Y
+		point := ecdhKey.Bytes()[1:]
+		size := len(point) / 2
+		jwk.KeyType = "EC"
+		jwk.Curve = k.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(point[:size])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(point[size:])
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(k)
+	default:
+		return entity.JSONWebKey{}, fmt.Errorf("unsupported key type: %T", publicKey)
+	}
+	return jwk, nil
+}
+
+// verifyingKey is a public key with the signing methods it may be used with.
+type verifyingKey struct {
+	id        string
+	methods   []jwt.SigningMethod
+	publicKey crypto.PublicKey
+}
+
+func (k verifyingKey) allows(method jwt.SigningMethod) bool {
+	for _, m := range k.methods {
+		if m.Alg() == method.Alg() {
+			return true
+		}
+	}
+	return false
+}
+
+func parseJWK(jwk entity.JSONWebKey) (verifyingKey, error) {
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return verifyingKey{}, fmt.Errorf("unsupported key use: %s", jwk.Use)
+	}
+
+	var publicKey crypto.PublicKey
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return verifyingKey{}, fmt.Errorf("failed to decode n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return verifyingKey{}, fmt.Errorf("failed to decode e: %w", err)
+		}
+		publicKey = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return verifyingKey{}, fmt.Errorf("unsupported curve: %s", jwk.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return verifyingKey{}, fmt.Errorf("failed to decode x: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return verifyingKey{}, fmt.Errorf("failed to decode y: %w", err)
+		}
+		k := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(k.X, k.Y) {
+			return verifyingKey{}, errors.New("invalid ec key: point is not on curve")
+		}
+		publicKey = k
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return verifyingKey{}, fmt.Errorf("unsupported curve: %s", jwk.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return verifyingKey{}, fmt.Errorf("failed to decode x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return verifyingKey{}, errors.New("invalid ed25519 key size")
+		}
+		publicKey = ed25519.PublicKey(x)
+	default:
+		return verifyingKey{}, fmt.Errorf("unsupported key type: %s", jwk.KeyType)
+	}
+
+	methods, err := signingMethodsFor(publicKey)
+	if err != nil {
+		return verifyingKey{}, err
+	}
+	if jwk.Algorithm != "" {
+		method := jwt.GetSigningMethod(jwk.Algorithm)
+		if method == nil {
+			return verifyingKey{}, fmt.Errorf("unsupported alg: %s", jwk.Algorithm)
+		}
+		if !(verifyingKey{methods: methods}).allows(method) {
+			return verifyingKey{}, fmt.Errorf("alg %s does not match key type %s", jwk.Algorithm, jwk.KeyType)
+		}
+		methods = []jwt.SigningMethod{method}
+	}
+
+	return verifyingKey{
+		id:        jwk.KeyID,
+		methods:   methods,
+		publicKey: publicKey,
+	}, nil
+}
+
+// signingMethodsFor returns the asymmetric signing methods which can be verified by publicKey.
+func signingMethodsFor(publicKey crypto.PublicKey) ([]jwt.SigningMethod, error) {
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		return []jwt.SigningMethod{
+			jwt.SigningMethodRS256, jwt.SigningMethodRS384, jwt.SigningMethodRS512,
+			jwt.SigningMethodPS256, jwt.SigningMethodPS384, jwt.SigningMethodPS512,
+		}, nil
+	case *ecdsa.PublicKey:
+		method, err := ecdsaSigningMethod(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		return []jwt.SigningMethod{method}, nil
+	case ed25519.PublicKey:
+		return []jwt.SigningMethod{jwt.SigningMethodEdDSA}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", publicKey)
+	}
+}
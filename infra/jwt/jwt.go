@@ -1,74 +1,339 @@
 package jwt
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
 	"github.com/walnuts1018/mpeg-dash-encoder/consts"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
 	"github.com/walnuts1018/mpeg-dash-encoder/util/anyslice"
 )
 
 type Manager struct {
+	// HS256用の共有鍵。非対称鍵が設定されている場合は署名には使われない
 	JwtSigningKey []byte
+	keys          []signingKey
+	leeway        time.Duration
+	audience      string
+	tenantID      string
+	issuer        string
 }
 
-const media_ids = "media_ids"
+const (
+	media_ids       = "media_ids"
+	renditions      = "renditions"
+	manifest_sha256 = "manifest_sha256"
+)
+
+func NewManager(jwtSigningKey config.JWTSigningKey, jwtKeysFile config.JWTKeysFile, cfg config.UserTokenConfig) (*Manager, error) {
+	return NewTenantManager(entity.DefaultTenantID, jwtSigningKey, jwtKeysFile, cfg)
+}
+
+// NewTenantManager creates a manager which issues tokens of the tenant.
+// Tokens of the default tenant keep the issuer without the tenant ID for compatibility.
+func NewTenantManager(tenantID string, jwtSigningKey config.JWTSigningKey, jwtKeysFile config.JWTKeysFile, cfg config.UserTokenConfig) (*Manager, error) {
+	issuer := consts.ApplicationName
+	if tenantID != entity.DefaultTenantID {
+		issuer = consts.ApplicationName + "/" + tenantID
+	}
 
-func NewManager(jwtSigningKey config.JWTSigningKey) *Manager {
-	return &Manager{
+	m := &Manager{
 		JwtSigningKey: []byte(jwtSigningKey),
+		leeway:        cfg.Leeway,
+		audience:      cfg.Audience,
+		tenantID:      tenantID,
+		issuer:        issuer,
+	}
+
+	if jwtKeysFile != "" {
+		keys, err := loadKeySetFile(string(jwtKeysFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load jwt keys: %w", err)
+		}
+		m.keys = keys
+	}
+
+	if len(m.JwtSigningKey) == 0 && len(m.keys) == 0 {
+		return nil, errors.New("either jwt signing secret or jwt keys file is required")
+	}
+	return m, nil
+}
+
+func (m *Manager) currentSigningKey(now time.Time) (signingKey, bool) {
+	var current signingKey
+	found := false
+	for _, k := range m.keys {
+		if !k.canSign(now) {
+			continue
+		}
+		if !found || k.signFrom.After(current.signFrom) {
+			current = k
+			found = true
+		}
+	}
+	return current, found
+}
+
+func (m *Manager) verificationKey(t *jwt.Token) (any, error) {
+	return m.lookupVerificationKey(t, false)
+}
+
+// lookupVerificationKey returns the public key of the kid. Retired keys are used only if allowRetired is true.
+func (m *Manager) lookupVerificationKey(t *jwt.Token, allowRetired bool) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		if len(m.JwtSigningKey) == 0 || t.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("kid is missing")
+		}
+		return m.JwtSigningKey, nil
+	}
+
+	now := time.Now()
+	for _, k := range m.keys {
+		if k.id != kid {
+			continue
+		}
+		if !allowRetired && !k.canVerify(now) {
+			return nil, fmt.Errorf("key %s is retired", kid)
+		}
+		// algの差し替えによる攻撃を防ぐため、鍵に紐づいたアルゴリズム以外は受け付けない
+		if t.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method for key %s: %s", kid, t.Method.Alg())
+		}
+		return k.privateKey.Public(), nil
 	}
+	return nil, fmt.Errorf("unknown kid: %s", kid)
+}
+
+func (m *Manager) validMethods() []string {
+	methods := make([]string, 0, len(m.keys)+1)
+	if len(m.JwtSigningKey) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Name)
+	}
+	for _, k := range m.keys {
+		methods = append(methods, k.method.Alg())
+	}
+	return methods
+}
+
+// JWKS returns the public keys which are currently valid for verification.
+func (m *Manager) JWKS() (entity.JSONWebKeySet, error) {
+	now := time.Now()
+	jwks := entity.JSONWebKeySet{
+		Keys: make([]entity.JSONWebKey, 0, len(m.keys)),
+	}
+	for _, k := range m.keys {
+		if !k.canVerify(now) {
+			continue
+		}
+		jwk, err := publicJWK(k.id, k.method.Alg(), k.privateKey.Public())
+		if err != nil {
+			return entity.JSONWebKeySet{}, fmt.Errorf("failed to create jwk %s: %w", k.id, err)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
 }
 
 func (m *Manager) CreateUserToken(
-	mediaIDs []string,
+	userToken entity.UserToken,
 ) (string, error) {
 	claims := jwt.MapClaims{
-		"iss":     consts.ApplicationName,
-		media_ids: mediaIDs,
+		"iss":     m.issuer,
+		"jti":     userToken.ID,
+		"iat":     jwt.NewNumericDate(userToken.IssuedAt),
+		"nbf":     jwt.NewNumericDate(userToken.NotBefore),
+		"exp":     jwt.NewNumericDate(userToken.ExpiresAt),
+		media_ids: userToken.MediaIDs,
+	}
+	if userToken.Subject != "" {
+		claims["sub"] = userToken.Subject
+	}
+	if len(userToken.Audience) > 0 {
+		claims["aud"] = jwt.ClaimStrings(userToken.Audience)
+	}
+	if !userToken.Renditions.IsZero() {
+		claims[renditions] = userToken.Renditions
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return m.sign(claims)
+}
+
+// sign signs the claims with the current signing key, or the shared key if no asymmetric key is available.
+func (m *Manager) sign(claims jwt.MapClaims) (string, error) {
+	signed, _, err := m.signWithKeyID(claims)
+	return signed, err
+}
+
+// signWithKeyID is sign which also returns the kid of the key. The kid is empty for the shared key.
+func (m *Manager) signWithKeyID(claims jwt.MapClaims) (string, string, error) {
+	var token *jwt.Token
+	var key any
+	var kid string
+	if k, ok := m.currentSigningKey(time.Now()); ok {
+		token = jwt.NewWithClaims(k.method, claims)
+		token.Header["kid"] = k.id
+		key = k.privateKey
+		kid = k.id
+	} else if len(m.JwtSigningKey) > 0 {
+		token = jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		key = m.JwtSigningKey
+	} else {
+		return "", "", errors.New("no signing key is available")
+	}
 	slog.Debug("token created", slog.Any("token", token))
 
-	signed, err := token.SignedString(m.JwtSigningKey)
+	signed, err := token.SignedString(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", "", fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	return signed, nil
+	return signed, kid, nil
 }
 
-func (m *Manager) GetMediaIDsFromToken(token string) ([]string, error) {
-	t, err := jwt.Parse(
-		token,
-		func(t *jwt.Token) (interface{}, error) {
-			return m.JwtSigningKey, nil
-		},
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
-		jwt.WithIssuer(consts.ApplicationName),
-	)
+func (m *Manager) ParseUserToken(ctx context.Context, token string) (entity.UserToken, error) {
+	// 他のテナントのトークンは署名の検証をせずに弾く
+	unverified, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("failed to parse token: %w", err)
+	}
+	if iss, _ := unverified.Claims.GetIssuer(); iss != m.issuer {
+		return entity.UserToken{}, fmt.Errorf("%w: %s", ErrUntrustedIssuer, iss)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(m.validMethods()),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithLeeway(m.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if m.audience != "" {
+		options = append(options, jwt.WithAudience(m.audience))
+	}
+
+	t, err := jwt.Parse(token, m.verificationKey, options...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+		return entity.UserToken{}, fmt.Errorf("failed to parse token: %w", err)
 	}
 
 	claims, ok := t.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, errors.New("failed to parse claims")
+		return entity.UserToken{}, errors.New("failed to parse claims")
 	}
 	slog.Debug("claims parsed", slog.Any("claims", fmt.Sprintf("%#v", claims)))
 
 	IDsAny, ok := claims[media_ids].([]any)
 	if !ok {
-		return nil, fmt.Errorf("failed to parse media_ids: %#v", claims[media_ids])
+		return entity.UserToken{}, fmt.Errorf("failed to parse media_ids: %#v", claims[media_ids])
 	}
 
 	parsedIDs, err := anyslice.FromAny[string](IDsAny)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse media_ids: %w", err)
+		return entity.UserToken{}, fmt.Errorf("failed to parse media_ids: %w", err)
+	}
+
+	userToken, err := userTokenFromClaims(claims, parsedIDs)
+	if err != nil {
+		return entity.UserToken{}, err
+	}
+	userToken.TenantID = m.tenantID
+
+	if v, ok := claims[renditions]; ok {
+		// 一度JSONに戻して構造体に詰め直す
+		b, err := json.Marshal(v)
+		if err != nil {
+			return entity.UserToken{}, fmt.Errorf("failed to parse renditions: %w", err)
+		}
+		if err := json.Unmarshal(b, &userToken.Renditions); err != nil {
+			return entity.UserToken{}, fmt.Errorf("failed to parse renditions: %w", err)
+		}
+	}
+	return userToken, nil
+}
+
+func userTokenFromClaims(claims jwt.MapClaims, mediaIDs []string) (entity.UserToken, error) {
+	userToken := entity.UserToken{
+		MediaIDs: mediaIDs,
+	}
+
+	if jti, ok := claims["jti"].(string); ok {
+		userToken.ID = jti
+	}
+
+	sub, err := claims.GetSubject()
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("failed to parse sub: %w", err)
+	}
+	userToken.Subject = sub
+
+	aud, err := claims.GetAudience()
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("failed to parse aud: %w", err)
+	}
+	userToken.Audience = aud
+
+	for _, v := range []struct {
+		get func() (*jwt.NumericDate, error)
+		dst *time.Time
+	}{
+		{claims.GetIssuedAt, &userToken.IssuedAt},
+		{claims.GetNotBefore, &userToken.NotBefore},
+		{claims.GetExpirationTime, &userToken.ExpiresAt},
+	} {
+		date, err := v.get()
+		if err != nil {
+			return entity.UserToken{}, fmt.Errorf("failed to parse time claim: %w", err)
+		}
+		if date != nil {
+			*v.dst = date.Time
+		}
+	}
+
+	return userToken, nil
+}
+
+// SignIntegrityManifest returns a JWT which has the digest of an integrity manifest, and the kid of the key.
+// It can be verified with the JWKS, like user tokens.
+func (m *Manager) SignIntegrityManifest(digest string) (string, string, error) {
+	return m.signWithKeyID(jwt.MapClaims{
+		"iss":           m.issuer,
+		"iat":           jwt.NewNumericDate(time.Now()),
+		manifest_sha256: digest,
+	})
+}
+
+// VerifyIntegrityManifest verifies the signature with the key of kid.
+func (m *Manager) VerifyIntegrityManifest(signature string, kid string, digest string) error {
+	t, err := jwt.Parse(signature, func(t *jwt.Token) (any, error) {
+		// マニフェストに記録されたkidと異なる鍵での署名は受け付けない
+		if headerKID, _ := t.Header["kid"].(string); headerKID != kid {
+			return nil, fmt.Errorf("kid mismatch: manifest %q, signature %q", kid, headerKID)
+		}
+		// マニフェストは再署名しないので、鍵のローテーション後も鍵ファイルに残っている限り検証できるようにする
+		return m.lookupVerificationKey(t, true)
+	},
+		jwt.WithValidMethods(m.validMethods()),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithLeeway(m.leeway),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to parse signature: %w", err)
+	}
+
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return errors.New("failed to parse claims")
+	}
+	if signed, _ := claims[manifest_sha256].(string); signed != digest {
+		return fmt.Errorf("digest mismatch: signed %s, got %s", signed, digest)
 	}
-	return parsedIDs, nil
+	return nil
 }
@@ -1,12 +1,16 @@
 package jwt
 
 import (
+	"context"
 	"log/slog"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/testutil"
 )
 
 func TestJWT(t *testing.T) {
@@ -15,10 +19,25 @@ func TestJWT(t *testing.T) {
 	RunSpecs(t, "JWT Suite")
 }
 
+func newUserToken(mediaIDs []string, ttl time.Duration) entity.UserToken {
+	now := time.Now()
+	return entity.UserToken{
+		ID:        "token-id",
+		Subject:   "user-1",
+		MediaIDs:  mediaIDs,
+		IssuedAt:  now,
+		NotBefore: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
 var _ = Describe("JWT", func() {
 	JwtSigningKey := config.JWTSigningKey("signingKey")
-	manager := NewManager(JwtSigningKey)
-	fakeManager := NewManager(config.JWTSigningKey("fakeSigningKey"))
+	userTokenConfig := config.UserTokenConfig{
+		Leeway: 30 * time.Second,
+	}
+	manager := testutil.IgnoreError(NewManager(JwtSigningKey, "", userTokenConfig))
+	fakeManager := testutil.IgnoreError(NewManager(config.JWTSigningKey("fakeSigningKey"), "", userTokenConfig))
 
 	entityIDsA := []string{
 		"1",
@@ -30,39 +49,156 @@ var _ = Describe("JWT", func() {
 
 	It("Normal", func() {
 		By("Create User Token")
-		token, err := manager.CreateUserToken(entityIDsA)
+		token, err := manager.CreateUserToken(newUserToken(entityIDsA, time.Hour))
 		Expect(err).NotTo(HaveOccurred())
 
-		By("Get Media IDs From Token")
-		mediaIDs, err := manager.GetMediaIDsFromToken(token)
+		By("Parse User Token")
+		userToken, err := manager.ParseUserToken(context.Background(), token)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(mediaIDs).To(Equal(entityIDsA))
+		Expect(userToken.MediaIDs).To(Equal(entityIDsA))
+		Expect(userToken.ID).To(Equal("token-id"))
+		Expect(userToken.Subject).To(Equal("user-1"))
+		Expect(userToken.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
 	})
 
 	It("Empty Media IDs", func() {
 		By("Create User Token")
-		token, err := manager.CreateUserToken(entityIDsEmpty)
+		token, err := manager.CreateUserToken(newUserToken(entityIDsEmpty, time.Hour))
 		Expect(err).NotTo(HaveOccurred())
 
-		By("Get Media IDs From Token")
-		mediaIDs, err := manager.GetMediaIDsFromToken(token)
+		By("Parse User Token")
+		userToken, err := manager.ParseUserToken(context.Background(), token)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(mediaIDs).To(Equal([]string{}))
+		Expect(userToken.MediaIDs).To(Equal([]string{}))
 	})
 
 	It("Invalid Token", func() {
 		By("Create User Token with Fake Manager")
-		token, err := fakeManager.CreateUserToken(entityIDsA)
+		token, err := fakeManager.CreateUserToken(newUserToken(entityIDsA, time.Hour))
 		Expect(err).NotTo(HaveOccurred())
 
 		By("Check Fake Token can be parsed by Fake Manager")
-		mediaIDs, err := fakeManager.GetMediaIDsFromToken(token)
+		userToken, err := fakeManager.ParseUserToken(context.Background(), token)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(mediaIDs).To(Equal(entityIDsA))
+		Expect(userToken.MediaIDs).To(Equal(entityIDsA))
 
 		By("Check Fake Token will be rejected by Real Manager")
-		_, err = manager.GetMediaIDsFromToken(token)
+		_, err = manager.ParseUserToken(context.Background(), token)
 		Expect(err).To(HaveOccurred())
 		slog.Debug("error", slog.Any("error", err))
 	})
+
+	It("Expired Token", func() {
+		By("Create Expired User Token")
+		token, err := manager.CreateUserToken(newUserToken(entityIDsA, -time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Check Expired Token will be rejected")
+		_, err = manager.ParseUserToken(context.Background(), token)
+		Expect(err).To(HaveOccurred())
+
+		By("Check Token expired within leeway is accepted")
+		token, err = manager.CreateUserToken(newUserToken(entityIDsA, -10*time.Second))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = manager.ParseUserToken(context.Background(), token)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Not Yet Valid Token", func() {
+		userToken := newUserToken(entityIDsA, time.Hour)
+		userToken.NotBefore = time.Now().Add(time.Minute)
+
+		token, err := manager.CreateUserToken(userToken)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = manager.ParseUserToken(context.Background(), token)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Audience", func() {
+		audienceManager, err := NewManager(JwtSigningKey, "", config.UserTokenConfig{
+			Leeway:   30 * time.Second,
+			Audience: "edge",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Token without audience will be rejected")
+		token, err := manager.CreateUserToken(newUserToken(entityIDsA, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = audienceManager.ParseUserToken(context.Background(), token)
+		Expect(err).To(HaveOccurred())
+
+		By("Token with audience will be accepted")
+		userToken := newUserToken(entityIDsA, time.Hour)
+		userToken.Audience = []string{"edge"}
+		token, err = manager.CreateUserToken(userToken)
+		Expect(err).NotTo(HaveOccurred())
+		parsed, err := audienceManager.ParseUserToken(context.Background(), token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.Audience).To(Equal([]string{"edge"}))
+	})
+
+	It("Renditions", func() {
+		By("Without renditions")
+		token, err := manager.CreateUserToken(newUserToken(entityIDsA, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		userToken, err := manager.ParseUserToken(context.Background(), token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userToken.Renditions.IsZero()).To(BeTrue())
+
+		By("With renditions")
+		constrained := newUserToken(entityIDsA, time.Hour)
+		constrained.Renditions = entity.RenditionConstraints{
+			MaxHeight:    720,
+			MaxBandwidth: 5000000,
+		}
+		token, err = manager.CreateUserToken(constrained)
+		Expect(err).NotTo(HaveOccurred())
+		userToken, err = manager.ParseUserToken(context.Background(), token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userToken.Renditions).To(Equal(constrained.Renditions))
+	})
+	It("Tenant", func() {
+		tenantManager, err := NewTenantManager("team-a", JwtSigningKey, "", userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Default tenant")
+		token, err := manager.CreateUserToken(newUserToken(entityIDsA, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		userToken, err := manager.ParseUserToken(context.Background(), token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userToken.TenantID).To(Equal(entity.DefaultTenantID))
+
+		By("Token of the default tenant is rejected by another tenant, even with the same key")
+		_, err = tenantManager.ParseUserToken(context.Background(), token)
+		Expect(err).To(MatchError(ErrUntrustedIssuer))
+
+		By("Token of another tenant")
+		token, err = tenantManager.CreateUserToken(newUserToken(entityIDsA, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		userToken, err = tenantManager.ParseUserToken(context.Background(), token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userToken.TenantID).To(Equal("team-a"))
+		_, err = manager.ParseUserToken(context.Background(), token)
+		Expect(err).To(MatchError(ErrUntrustedIssuer))
+	})
+	It("Integrity Manifest", func() {
+		signature, kid, err := manager.SignIntegrityManifest("digest")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kid).To(BeEmpty())
+		Expect(manager.VerifyIntegrityManifest(signature, kid, "digest")).To(Succeed())
+
+		By("Digest mismatch")
+		Expect(manager.VerifyIntegrityManifest(signature, kid, "other")).NotTo(Succeed())
+
+		By("Kid mismatch")
+		Expect(manager.VerifyIntegrityManifest(signature, "key-1", "digest")).NotTo(Succeed())
+
+		By("Signed by another key")
+		Expect(fakeManager.VerifyIntegrityManifest(signature, kid, "digest")).NotTo(Succeed())
+
+		By("Not a user token")
+		_, err = manager.ParseUserToken(context.Background(), signature)
+		Expect(err).To(HaveOccurred())
+	})
 })
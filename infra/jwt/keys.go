@@ -0,0 +1,151 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keySetFile is the format of JWT_KEYS_FILE.
+//
+//	{
+//	  "keys": [
+//	    {"kid": "2025-01", "private_key_file": "2025-01.pem", "sign_from": "2025-01-01T00:00:00Z", "retire_at": "2025-07-01T00:00:00Z"}
+//	  ]
+//	}
+//
+// 鍵は retire_at まで検証とJWKSでの公開に使われ、sign_from を過ぎた鍵のうち最も新しいものが署名に使われる。
+// retire_at を過ぎた鍵も、ファイルに残しておけばintegrity manifestの検証には使われる。
+// 新しい鍵を sign_from より前に追加しておくことで、検証側がJWKSを取得してから署名が切り替わる。
+type keySetFile struct {
+	Keys []struct {
+		KeyID          string    `json:"kid"`
+		PrivateKeyFile string    `json:"private_key_file"`
+		SignFrom       time.Time `json:"sign_from"`
+		RetireAt       time.Time `json:"retire_at"`
+	} `json:"keys"`
+}
+
+type signingKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey crypto.Signer
+	signFrom   time.Time
+	retireAt   time.Time
+}
+
+func (k signingKey) canSign(now time.Time) bool {
+	return !now.Before(k.signFrom) && k.canVerify(now)
+}
+
+func (k signingKey) canVerify(now time.Time) bool {
+	return k.retireAt.IsZero() || now.Before(k.retireAt)
+}
+
+func loadKeySetFile(path string) ([]signingKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key set file: %w", err)
+	}
+
+	var f keySetFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse key set file: %w", err)
+	}
+
+	keys := make([]signingKey, 0, len(f.Keys))
+	seen := make(map[string]struct{}, len(f.Keys))
+	for _, k := range f.Keys {
+		if k.KeyID == "" {
+			return nil, errors.New("kid is required")
+		}
+		if _, ok := seen[k.KeyID]; ok {
+			return nil, fmt.Errorf("duplicate kid: %s", k.KeyID)
+		}
+		seen[k.KeyID] = struct{}{}
+
+		keyPath := k.PrivateKeyFile
+		if !filepath.IsAbs(keyPath) {
+			keyPath = filepath.Join(filepath.Dir(path), keyPath)
+		}
+		pemBytes, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key %s: %w", k.KeyID, err)
+		}
+
+		privateKey, method, err := parsePrivateKeyPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key %s: %w", k.KeyID, err)
+		}
+
+		keys = append(keys, signingKey{
+			id:         k.KeyID,
+			method:     method,
+			privateKey: privateKey,
+			signFrom:   k.SignFrom,
+			retireAt:   k.RetireAt,
+		})
+	}
+	return keys, nil
+}
+
+func parsePrivateKeyPEM(b []byte) (crypto.Signer, jwt.SigningMethod, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, nil, errors.New("invalid pem")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		method, err := ecdsaSigningMethod(k.Curve)
+		if err != nil {
+			return nil, nil, err
+		}
+		return k, method, nil
+	case ed25519.PrivateKey:
+		return k, jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type: %T", key)
+	}
+}
+
+func ecdsaSigningMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	default:
+		return nil, fmt.Errorf("unsupported curve: %s", curve.Params().Name)
+	}
+}
@@ -0,0 +1,229 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+)
+
+type testKey struct {
+	kid      string
+	key      crypto.Signer
+	signFrom time.Time
+	retireAt time.Time
+}
+
+func writeKeySetFile(dir string, keys []testKey) config.JWTKeysFile {
+	GinkgoHelper()
+
+	var f keySetFile
+	for _, k := range keys {
+		der, err := x509.MarshalPKCS8PrivateKey(k.key)
+		Expect(err).NotTo(HaveOccurred())
+
+		keyFile := k.kid + ".pem"
+		err = os.WriteFile(filepath.Join(dir, keyFile), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+		Expect(err).NotTo(HaveOccurred())
+
+		f.Keys = append(f.Keys, struct {
+			KeyID          string    `json:"kid"`
+			PrivateKeyFile string    `json:"private_key_file"`
+			SignFrom       time.Time `json:"sign_from"`
+			RetireAt       time.Time `json:"retire_at"`
+		}{k.kid, keyFile, k.signFrom, k.retireAt})
+	}
+
+	b, err := json.Marshal(f)
+	Expect(err).NotTo(HaveOccurred())
+
+	path := filepath.Join(dir, "keys.json")
+	Expect(os.WriteFile(path, b, 0o600)).To(Succeed())
+	return config.JWTKeysFile(path)
+}
+
+func tokenHeader(token string) map[string]any {
+	GinkgoHelper()
+
+	t, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	Expect(err).NotTo(HaveOccurred())
+	return t.Header
+}
+
+var _ = Describe("Asymmetric Keys", func() {
+	userTokenConfig := config.UserTokenConfig{
+		Leeway: 30 * time.Second,
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	entityIDs := []string{"1", "2"}
+
+	DescribeTable("Sign and Verify",
+		func(key crypto.Signer, alg string, kty string) {
+			keysFile := writeKeySetFile(GinkgoT().TempDir(), []testKey{{kid: "key-1", key: key}})
+			manager, err := NewManager("", keysFile, userTokenConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			token, err := manager.CreateUserToken(newUserToken(entityIDs, time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokenHeader(token)).To(HaveKeyWithValue("alg", alg))
+			Expect(tokenHeader(token)).To(HaveKeyWithValue("kid", "key-1"))
+
+			userToken, err := manager.ParseUserToken(context.Background(), token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(userToken.MediaIDs).To(Equal(entityIDs))
+
+			jwks, err := manager.JWKS()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jwks.Keys).To(HaveLen(1))
+			Expect(jwks.Keys[0].KeyID).To(Equal("key-1"))
+			Expect(jwks.Keys[0].Algorithm).To(Equal(alg))
+			Expect(jwks.Keys[0].KeyType).To(Equal(kty))
+		},
+		Entry("RS256", rsaKey, "RS256", "RSA"),
+		Entry("ES256", ecKey, "ES256", "EC"),
+		Entry("EdDSA", edKey, "EdDSA", "OKP"),
+	)
+
+	It("Rotation", func() {
+		now := time.Now()
+		keysFile := writeKeySetFile(GinkgoT().TempDir(), []testKey{
+			{kid: "old", key: rsaKey, signFrom: now.Add(-48 * time.Hour)},
+			{kid: "current", key: ecKey, signFrom: now.Add(-time.Hour)},
+			{kid: "next", key: edKey, signFrom: now.Add(24 * time.Hour)},
+		})
+		manager, err := NewManager("", keysFile, userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Newest key which has already started signing is used")
+		token, err := manager.CreateUserToken(newUserToken(entityIDs, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokenHeader(token)).To(HaveKeyWithValue("kid", "current"))
+
+		By("All keys are published, including the next one")
+		jwks, err := manager.JWKS()
+		Expect(err).NotTo(HaveOccurred())
+		kids := make([]string, 0, len(jwks.Keys))
+		for _, k := range jwks.Keys {
+			kids = append(kids, k.KeyID)
+		}
+		Expect(kids).To(ConsistOf("old", "current", "next"))
+
+		By("Tokens signed by the old key are still accepted")
+		oldManager, err := NewManager("", writeKeySetFile(GinkgoT().TempDir(), []testKey{{kid: "old", key: rsaKey}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+		token, err = oldManager.CreateUserToken(newUserToken(entityIDs, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = manager.ParseUserToken(context.Background(), token)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Integrity manifests carry the kid of the signing key")
+		signature, kid, err := oldManager.SignIntegrityManifest("digest")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kid).To(Equal("old"))
+		Expect(manager.VerifyIntegrityManifest(signature, kid, "digest")).To(Succeed())
+		Expect(manager.VerifyIntegrityManifest(signature, "current", "digest")).NotTo(Succeed())
+	})
+
+	It("Retired Key", func() {
+		now := time.Now()
+		signer, err := NewManager("", writeKeySetFile(GinkgoT().TempDir(), []testKey{{kid: "old", key: rsaKey}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+		token, err := signer.CreateUserToken(newUserToken(entityIDs, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+
+		verifier, err := NewManager("", writeKeySetFile(GinkgoT().TempDir(), []testKey{
+			{kid: "old", key: rsaKey, retireAt: now.Add(-time.Minute)},
+			{kid: "current", key: ecKey},
+		}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = verifier.ParseUserToken(context.Background(), token)
+		Expect(err).To(HaveOccurred())
+
+		jwks, err := verifier.JWKS()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(jwks.Keys).To(HaveLen(1))
+		Expect(jwks.Keys[0].KeyID).To(Equal("current"))
+	})
+
+	It("Integrity Manifest after Rotation", func() {
+		now := time.Now()
+		signer, err := NewManager("", writeKeySetFile(GinkgoT().TempDir(), []testKey{{kid: "old", key: rsaKey}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+		signature, kid, err := signer.SignIntegrityManifest("digest")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Rotate the key and retire the old one")
+		rotated, err := NewManager("", writeKeySetFile(GinkgoT().TempDir(), []testKey{
+			{kid: "old", key: rsaKey, retireAt: now.Add(-time.Minute)},
+			{kid: "current", key: ecKey, signFrom: now.Add(-time.Hour)},
+		}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("The old manifest is still verified")
+		Expect(rotated.VerifyIntegrityManifest(signature, kid, "digest")).To(Succeed())
+		Expect(rotated.VerifyIntegrityManifest(signature, kid, "other")).NotTo(Succeed())
+
+		By("The retired key is not used for signing")
+		_, kid, err = rotated.SignIntegrityManifest("digest")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kid).To(Equal("current"))
+
+		By("The manifest is rejected once the old key is removed")
+		removed, err := NewManager("", writeKeySetFile(GinkgoT().TempDir(), []testKey{{kid: "current", key: ecKey}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed.VerifyIntegrityManifest(signature, "old", "digest")).NotTo(Succeed())
+	})
+
+	It("Algorithm Confusion", func() {
+		manager, err := NewManager("secret", writeKeySetFile(GinkgoT().TempDir(), []testKey{{kid: "key-1", key: rsaKey}}), userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("HS256 token which claims the RSA key id is rejected")
+		publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+		Expect(err).NotTo(HaveOccurred())
+		now := time.Now()
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":     "mpeg-dash-encoder",
+			"iat":     jwt.NewNumericDate(now),
+			"exp":     jwt.NewNumericDate(now.Add(time.Hour)),
+			media_ids: entityIDs,
+		})
+		forged.Header["kid"] = "key-1"
+		signed, err := forged.SignedString(publicDER)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = manager.ParseUserToken(context.Background(), signed)
+		Expect(err).To(HaveOccurred())
+
+		By("Legacy HS256 token without kid is still accepted")
+		legacy, err := NewManager("secret", "", userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+		token, err := legacy.CreateUserToken(newUserToken(entityIDs, time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = manager.ParseUserToken(context.Background(), token)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("No Key", func() {
+		_, err := NewManager("", "", userTokenConfig)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,240 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"golang.org/x/sync/singleflight"
+)
+
+const oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+// OIDC principalの名前の接頭辞。APIキーの名前と区別する
+const oidcPrincipalPrefix = "oidc:"
+
+var ErrOIDCDisabled = errors.New("oidc is not configured")
+
+// OIDCVerifier verifies ID tokens and JWT access tokens of the admin users.
+type OIDCVerifier struct {
+	cfg         config.AdminOIDCConfig
+	groupScopes map[string][]entity.AdminScope
+	leeway      time.Duration
+	client      *http.Client
+
+	// 取得中も他の検証を止めないよう、discoveryはロックの外でまとめて行う
+	group singleflight.Group
+
+	mu                sync.Mutex
+	keys              *remoteKeySet // discovery後に作成する
+	discoveryErr      error
+	discoveryFailedAt time.Time
+}
+
+func NewOIDCVerifier(cfg config.AdminOIDCConfig, userTokenConfig config.UserTokenConfig) (*OIDCVerifier, error) {
+	if cfg.Issuer != "" && cfg.Audience == "" {
+		return nil, config.ErrOIDCAudienceRequired
+	}
+
+	v := &OIDCVerifier{
+		cfg:         cfg,
+		groupScopes: make(map[string][]entity.AdminScope, len(cfg.GroupScopes)),
+		leeway:      userTokenConfig.Leeway,
+		client:      &http.Client{Timeout: jwksFetchTimeout},
+	}
+
+	for group, scopes := range cfg.GroupScopes {
+		for s := range strings.SplitSeq(scopes, "|") {
+			scope, err := entity.ParseAdminScope(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("invalid scope for group %s: %w", group, err)
+			}
+			v.groupScopes[group] = append(v.groupScopes[group], scope)
+		}
+	}
+	return v, nil
+}
+
+type oidcDiscovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+func (v *OIDCVerifier) keySet(ctx context.Context) (*remoteKeySet, error) {
+	v.mu.Lock()
+	keys := v.keys
+	// IdPの障害時に毎リクエスト問い合わせないようにする
+	discoveryErr := v.discoveryErr
+	failedRecently := discoveryErr != nil && time.Since(v.discoveryFailedAt) < minJWKSRefetchInterval
+	v.mu.Unlock()
+	if keys != nil {
+		return keys, nil
+	}
+	if failedRecently {
+		return nil, discoveryErr
+	}
+
+	// 起動時にIdPに依存しないよう、初回の検証時に取得する
+	// 呼び出し元のキャンセルをIdPの障害としてキャッシュしないよう、呼び出し元のコンテキストから切り離す
+	k, err, _ := v.group.Do("discovery", func() (any, error) {
+		return v.discoverKeySet(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return k.(*remoteKeySet), nil
+}
+
+func (v *OIDCVerifier) discoverKeySet(ctx context.Context) (*remoteKeySet, error) {
+	discovery, err := v.discover(ctx)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err != nil {
+		v.discoveryErr = err
+		v.discoveryFailedAt = time.Now()
+		return nil, err
+	}
+	// 直前に他の呼び出しで取得済みの場合は、取得した鍵を捨てないようにそのまま使う
+	if v.keys == nil {
+		v.keys = &remoteKeySet{
+			url:             discovery.JWKSURI,
+			client:          v.client,
+			refreshInterval: v.cfg.JWKSRefreshInterval,
+		}
+	}
+	return v.keys, nil
+}
+
+func (v *OIDCVerifier) discover(ctx context.Context) (oidcDiscovery, error) {
+	url := strings.TrimSuffix(v.cfg.Issuer, "/") + oidcDiscoveryPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return oidcDiscovery{}, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return oidcDiscovery{}, fmt.Errorf("failed to fetch openid configuration: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return oidcDiscovery{}, fmt.Errorf("failed to fetch openid configuration: unexpected status %d", resp.StatusCode)
+	}
+
+	var discovery oidcDiscovery
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&discovery); err != nil {
+		return oidcDiscovery{}, fmt.Errorf("failed to parse openid configuration: %w", err)
+	}
+	if discovery.Issuer != v.cfg.Issuer {
+		return oidcDiscovery{}, fmt.Errorf("issuer mismatch: %s", discovery.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return oidcDiscovery{}, errors.New("jwks_uri is missing")
+	}
+	return discovery, nil
+}
+
+func (v *OIDCVerifier) VerifyAdminToken(ctx context.Context, token string) (entity.AdminPrincipal, error) {
+	if v.cfg.Issuer == "" {
+		return entity.AdminPrincipal{}, ErrOIDCDisabled
+	}
+
+	keys, err := v.keySet(ctx)
+	if err != nil {
+		return entity.AdminPrincipal{}, err
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(asymmetricMethods()),
+		jwt.WithIssuer(v.cfg.Issuer),
+		jwt.WithLeeway(v.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithAudience(v.cfg.Audience),
+	}
+
+	t, err := jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		remoteKeys, err := keys.get(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		i := slices.IndexFunc(remoteKeys, func(k verifyingKey) bool {
+			return k.id == kid || (kid == "" && len(remoteKeys) == 1)
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("unknown kid: %s", kid)
+		}
+		if !remoteKeys[i].allows(t.Method) {
+			return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+		}
+		return remoteKeys[i].publicKey, nil
+	}, options...)
+	if err != nil {
+		return entity.AdminPrincipal{}, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return entity.AdminPrincipal{}, errors.New("failed to parse claims")
+	}
+
+	name, _ := claims[v.cfg.NameClaim].(string)
+	if name == "" {
+		name, err = claims.GetSubject()
+		if err != nil || name == "" {
+			return entity.AdminPrincipal{}, errors.New("failed to parse sub")
+		}
+	}
+
+	groups, err := stringsClaim(claims, v.cfg.GroupsClaim)
+	if err != nil {
+		return entity.AdminPrincipal{}, fmt.Errorf("failed to parse %s: %w", v.cfg.GroupsClaim, err)
+	}
+
+	principal := entity.AdminPrincipal{
+		Name:     oidcPrincipalPrefix + name,
+		TenantID: entity.DefaultTenantID,
+		Scopes:   make([]entity.AdminScope, 0),
+	}
+	tenantIDs := make([]string, 0, 1)
+	for _, group := range groups {
+		if _, ok := v.groupScopes[group]; !ok {
+			continue
+		}
+		tenantID := v.cfg.GroupTenants[group]
+		if tenantID == "" {
+			tenantID = entity.DefaultTenantID
+		}
+		if !slices.Contains(tenantIDs, tenantID) {
+			tenantIDs = append(tenantIDs, tenantID)
+		}
+	}
+	// 複数のテナントのスコープを合算しないよう、どのテナントとして扱うか曖昧な場合は拒否する
+	if len(tenantIDs) > 1 {
+		return entity.AdminPrincipal{}, fmt.Errorf("groups belong to multiple tenants: %s", strings.Join(tenantIDs, ", "))
+	}
+	if len(tenantIDs) == 1 {
+		principal.TenantID = tenantIDs[0]
+	}
+	for _, group := range groups {
+		for _, scope := range v.groupScopes[group] {
+			if !slices.Contains(principal.Scopes, scope) {
+				principal.Scopes = append(principal.Scopes, scope)
+			}
+		}
+	}
+	return principal, nil
+}
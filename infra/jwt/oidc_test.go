@@ -0,0 +1,280 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// fakeOIDCProvider serves the discovery document and JWKS, and issues tokens signed by its key.
+type fakeOIDCProvider struct {
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	discoveries atomic.Int32
+	// nilでない場合、closeされるまでdiscoveryの応答を止める
+	release chan struct{}
+}
+
+func newFakeOIDCProvider() *fakeOIDCProvider {
+	GinkgoHelper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	p := &fakeOIDCProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		p.discoveries.Add(1)
+		if p.release != nil {
+			<-p.release
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   p.server.URL,
+			"jwks_uri": p.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		jwk, err := publicJWK("idp-1", "ES256", p.key.Public())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(entity.JSONWebKeySet{Keys: []entity.JSONWebKey{jwk}})
+	})
+	p.server = httptest.NewServer(mux)
+	DeferCleanup(p.server.Close)
+	return p
+}
+
+func (p *fakeOIDCProvider) issue(claims jwt.MapClaims) string {
+	GinkgoHelper()
+
+	now := time.Now()
+	base := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"sub":   "user-1",
+		"aud":   "mpeg-dash-encoder",
+		"email": "alice@example.com",
+		"iat":   jwt.NewNumericDate(now),
+		"exp":   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	for k, v := range claims {
+		base[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, base)
+	token.Header["kid"] = "idp-1"
+	signed, err := token.SignedString(p.key)
+	Expect(err).NotTo(HaveOccurred())
+	return signed
+}
+
+var _ = Describe("OIDCVerifier", func() {
+	ctx := context.Background()
+	userTokenConfig := config.UserTokenConfig{
+		Leeway: 30 * time.Second,
+	}
+
+	var (
+		provider *fakeOIDCProvider
+		verifier *OIDCVerifier
+	)
+
+	BeforeEach(func() {
+		provider = newFakeOIDCProvider()
+
+		var err error
+		verifier, err = NewOIDCVerifier(config.AdminOIDCConfig{
+			Issuer:   provider.server.URL,
+			Audience: "mpeg-dash-encoder",
+			GroupScopes: map[string]string{
+				"admins":  "issue-tokens|manage-jobs",
+				"viewers": "read-only",
+			},
+			GroupsClaim:         "groups",
+			NameClaim:           "email",
+			JWKSRefreshInterval: time.Hour,
+		}, userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("Groups are mapped to scopes", func() {
+		principal, err := verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{
+			"groups": []string{"admins", "viewers", "unknown"},
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Name).To(Equal("oidc:alice@example.com"))
+		Expect(principal.Scopes).To(ConsistOf(
+			entity.AdminScopeIssueTokens,
+			entity.AdminScopeManageJobs,
+			entity.AdminScopeReadOnly,
+		))
+
+		By("Viewer")
+		principal, err = verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{
+			"groups": []string{"viewers"},
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.HasScope(entity.AdminScopeReadOnly)).To(BeTrue())
+		Expect(principal.HasScope(entity.AdminScopeIssueTokens)).To(BeFalse())
+
+		By("No groups")
+		principal, err = verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Scopes).To(BeEmpty())
+	})
+
+	It("Groups are mapped to a tenant", func() {
+		verifier, err := NewOIDCVerifier(config.AdminOIDCConfig{
+			Issuer:   provider.server.URL,
+			Audience: "mpeg-dash-encoder",
+			GroupScopes: map[string]string{
+				"admins":        "issue-tokens|manage-jobs",
+				"team-a-admins": "issue-tokens",
+				"team-b-admins": "issue-tokens",
+			},
+			GroupTenants: map[string]string{
+				"team-a-admins": "team-a",
+				"team-b-admins": "team-b",
+			},
+			GroupsClaim:         "groups",
+			NameClaim:           "email",
+			JWKSRefreshInterval: time.Hour,
+		}, userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Mapped group")
+		principal, err := verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{
+			"groups": []string{"team-a-admins", "unknown"},
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.TenantID).To(Equal("team-a"))
+		Expect(principal.Scopes).To(ConsistOf(entity.AdminScopeIssueTokens))
+
+		By("Unmapped group belongs to the default tenant")
+		principal, err = verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{
+			"groups": []string{"admins"},
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.TenantID).To(Equal(entity.DefaultTenantID))
+
+		By("Groups of multiple tenants")
+		_, err = verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{
+			"groups": []string{"team-a-admins", "team-b-admins"},
+		}))
+		Expect(err).To(HaveOccurred())
+
+		By("Tenant admin and default tenant admin")
+		_, err = verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{
+			"groups": []string{"admins", "team-a-admins"},
+		}))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Subject is used when the name claim is missing", func() {
+		principal, err := verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{
+			"email": nil,
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Name).To(Equal("oidc:user-1"))
+	})
+
+	It("Invalid tokens are rejected", func() {
+		By("Wrong audience")
+		_, err := verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{"aud": "other"}))
+		Expect(err).To(HaveOccurred())
+
+		By("Missing audience")
+		_, err = verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{"aud": nil}))
+		Expect(err).To(HaveOccurred())
+
+		By("Wrong issuer")
+		_, err = verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{"iss": "https://evil.example.com"}))
+		Expect(err).To(HaveOccurred())
+
+		By("Expired")
+		_, err = verifier.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{
+			"exp": jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		}))
+		Expect(err).To(HaveOccurred())
+
+		By("Signed by another key")
+		other := newFakeOIDCProvider()
+		token := other.issue(jwt.MapClaims{"iss": provider.server.URL})
+		_, err = verifier.VerifyAdminToken(ctx, token)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Concurrent Discovery", func() {
+		provider.release = make(chan struct{})
+		token := provider.issue(jwt.MapClaims{})
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := verifier.VerifyAdminToken(ctx, token)
+				errs <- err
+			}()
+		}
+		// 全員がdiscoveryを待っている間に1回だけ取得する
+		time.Sleep(100 * time.Millisecond)
+		close(provider.release)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(provider.discoveries.Load()).To(Equal(int32(1)))
+	})
+
+	It("Canceled request does not fail the discovery", func() {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		token := provider.issue(jwt.MapClaims{})
+		_, _ = verifier.VerifyAdminToken(canceled, token)
+
+		_, err := verifier.VerifyAdminToken(ctx, token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(provider.discoveries.Load()).To(Equal(int32(1)))
+	})
+
+	It("Disabled", func() {
+		disabled, err := NewOIDCVerifier(config.AdminOIDCConfig{}, userTokenConfig)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = disabled.VerifyAdminToken(ctx, provider.issue(jwt.MapClaims{}))
+		Expect(err).To(MatchError(ErrOIDCDisabled))
+	})
+
+	It("Unknown scope in config", func() {
+		_, err := NewOIDCVerifier(config.AdminOIDCConfig{
+			Issuer:      provider.server.URL,
+			Audience:    "mpeg-dash-encoder",
+			GroupScopes: map[string]string{"admins": "root"},
+		}, userTokenConfig)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Audience is required", func() {
+		_, err := NewOIDCVerifier(config.AdminOIDCConfig{
+			Issuer: provider.server.URL,
+		}, userTokenConfig)
+		Expect(err).To(MatchError(config.ErrOIDCAudienceRequired))
+	})
+})
@@ -0,0 +1,223 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/fileutil"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/mpd"
+)
+
+// EncodedObjectDir stores the encoded media as <dir>/<media ID>/<file>.
+type EncodedObjectDir struct {
+	dir string
+}
+
+func NewEncodedObjectDir(root string, bucketName config.EncodedObjectBucketName, prefix string) (*EncodedObjectDir, error) {
+	dir, err := bucketDir(root, string(bucketName), prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &EncodedObjectDir{dir: dir}, nil
+}
+
+func (e *EncodedObjectDir) path(mediaID string, fileName string) string {
+	return filepath.Join(e.dir, filepath.FromSlash(path.Join(mediaID, fileName)))
+}
+
+func (e *EncodedObjectDir) Ping(ctx context.Context) error {
+	return dirExists(e.dir)
+}
+
+type localFile struct {
+	src          string
+	relativePath string
+}
+
+// Upload copies the files in localDir. Each file is renamed into place after it is written,
+// and the manifests are replaced last, so that a manifest never refers to missing files.
+// The files which already exist with the same content are skipped.
+// On failure, the files created by this call are removed. The files which existed before are never removed.
+// After the manifests are replaced, the files of the previous encodes are removed.
+func (e *EncodedObjectDir) Upload(ctx context.Context, mediaID string, localDir string) ([]entity.IntegrityFile, error) {
+	var files, manifests []localFile
+	if err := filepath.WalkDir(localDir, func(localFilePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(localDir, localFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+
+		f := localFile{src: localFilePath, relativePath: filepath.ToSlash(rel)}
+		if mpd.IsManifest(d.Name()) {
+			manifests = append(manifests, f)
+		} else {
+			files = append(files, f)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	var created []string // このアップロードで作成したファイル
+	keep := map[string]struct{}{
+		e.path(mediaID, entity.IntegrityManifestFileName): {},
+	}
+	integrity := make([]entity.IntegrityFile, 0, len(files)+len(manifests))
+	for i, f := range slices.Concat(files, manifests) {
+		dst := e.path(mediaID, f.relativePath)
+		file, existed, err := copyFile(ctx, f.src, dst)
+		if err != nil {
+			// 置き換えたマニフェストが参照するので、1つ目を置き換えた後はロールバックしない
+			if i > len(files) {
+				return nil, fmt.Errorf("failed to upload directory: %w", err)
+			}
+			errs := []error{err}
+			for _, c := range created {
+				if err := os.Remove(c); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return nil, fmt.Errorf("failed to upload directory: %w", errors.Join(errs...))
+		}
+		if !existed {
+			created = append(created, dst)
+		}
+		keep[dst] = struct{}{}
+		file.Path = f.relativePath
+		integrity = append(integrity, file)
+	}
+
+	if err := removeStaleFiles(e.path(mediaID, ""), keep); err != nil {
+		slog.Warn("failed to remove stale files", slog.String("mediaID", mediaID), slog.Any("error", err))
+		// returnしない
+	}
+
+	slices.SortFunc(integrity, func(a, b entity.IntegrityFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return integrity, nil
+}
+
+// removeStaleFiles removes the files under dir which are not in keep, i.e. the ones of the previous encodes.
+func removeStaleFiles(dir string, keep map[string]struct{}) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || isTempFile(d.Name()) {
+			return nil
+		}
+		if _, ok := keep[p]; ok {
+			return nil
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", p, err)
+		}
+		return nil
+	})
+}
+
+// copyFile copies src to dst unless dst has the same content. It reports whether dst existed before.
+func copyFile(ctx context.Context, src string, dst string) (entity.IntegrityFile, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.IntegrityFile{}, false, err
+	}
+
+	srcFile, err := fileSHA256(src)
+	if err != nil {
+		return entity.IntegrityFile{}, false, err
+	}
+	// 存在を確認できない場合は、ロールバックで消さないよう存在したものとして扱う
+	_, statErr := os.Stat(dst)
+	existed := !errors.Is(statErr, fs.ErrNotExist)
+	if dstFile, err := fileSHA256(dst); err == nil && dstFile == srcFile {
+		return srcFile, true, nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return entity.IntegrityFile{}, false, fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer f.Close()
+	if err := writeFileAtomic(dst, f); err != nil {
+		return entity.IntegrityFile{}, false, err
+	}
+	return srcFile, existed, nil
+}
+
+func fileSHA256(path string) (entity.IntegrityFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return entity.IntegrityFile{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return entity.IntegrityFile{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return entity.IntegrityFile{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func (e *EncodedObjectDir) PutFile(ctx context.Context, mediaID string, fileName string, content []byte, contentType string) error {
+	return writeFileAtomic(e.path(mediaID, fileName), bytes.NewReader(content))
+}
+
+// Stat returns the size of the file. It returns domain.ErrObjectNotFound if the file does not exist.
+func (e *EncodedObjectDir) Stat(ctx context.Context, mediaID string, fileName string) (int64, error) {
+	p := e.path(mediaID, fileName)
+	info, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, fmt.Errorf("%w: %s", domain.ErrObjectNotFound, p)
+		}
+		return 0, fmt.Errorf("failed to stat %s: %w", p, err)
+	}
+	return info.Size(), nil
+}
+
+// TotalSize returns the total size of the files under the dir.
+func (e *EncodedObjectDir) TotalSize(ctx context.Context) (int64, error) {
+	return fileutil.DirSize(e.dir)
+}
+
+func (e *EncodedObjectDir) GetObject(ctx context.Context, mediaID string, fileName string) (io.ReadSeekCloser, error) {
+	p := e.path(mediaID, fileName)
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrObjectNotFound, p)
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", p, err)
+	}
+	return f, nil
+}
+
+// PresignedGetObject is not supported. config.Load rejects the redirect delivery mode with the local storage.
+func (e *EncodedObjectDir) PresignedGetObject(ctx context.Context, mediaID string, fileName string, expiry time.Duration) (*url.URL, error) {
+	return nil, ErrPresignNotSupported
+}
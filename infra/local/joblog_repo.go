@@ -0,0 +1,55 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+)
+
+const jobLogDirName = "logs"
+
+// JobLogDir stores the ffmpeg logs as <dir>/<tenantID>/<mediaID>.log.gz.
+type JobLogDir struct {
+	dir string
+}
+
+func NewJobLogDir(root string, bucketName config.SystemBucketName) (*JobLogDir, error) {
+	dir, err := bucketDir(root, string(bucketName), jobLogDirName)
+	if err != nil {
+		return nil, err
+	}
+	return &JobLogDir{dir: dir}, nil
+}
+
+func (j *JobLogDir) path(tenantID string, mediaID string) string {
+	return filepath.Join(j.dir, tenantID, mediaID+".log.gz")
+}
+
+// PutJobLog stores the gzip compressed ffmpeg log of the job.
+func (j *JobLogDir) PutJobLog(ctx context.Context, tenantID string, mediaID string, content []byte) error {
+	if err := writeFileAtomic(j.path(tenantID, mediaID), bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("failed to put job log: %w", err)
+	}
+	return nil
+}
+
+// GetJobLog returns the gzip compressed ffmpeg log of the job.
+func (j *JobLogDir) GetJobLog(ctx context.Context, tenantID string, mediaID string) (io.ReadCloser, error) {
+	p := j.path(tenantID, mediaID)
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrObjectNotFound, p)
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", p, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,232 @@
+package local
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+func writeFile(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestSourceDir(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s, err := NewSourceDir(root, "source", "team-a/")
+	require.NoError(t, err)
+
+	old := time.Now().Add(-time.Minute)
+	writeFile(t, filepath.Join(root, "source", "team-a", "movie-1"), "source1", old)
+	writeFile(t, filepath.Join(root, "source", "team-a", "copying"), "partial", time.Now())
+	writeFile(t, filepath.Join(root, "source", "team-a", ".hidden"), "hidden", old)
+	writeFile(t, filepath.Join(root, "source", "team-a", "sub", "movie-2"), "source2", old)
+
+	list := func() []entity.SourceFile {
+		var files []entity.SourceFile
+		for f, err := range s.ListUploadedFiles(ctx) {
+			require.NoError(t, err)
+			files = append(files, f)
+		}
+		return files
+	}
+
+	files := list()
+	require.Len(t, files, 1)
+	assert.Equal(t, "movie-1", files[0].ID)
+	assert.Nil(t, files[0].Tags)
+
+	require.NoError(t, s.SetObjectTags(ctx, "movie-1", map[string]string{"hostname": "host-a"}))
+	assert.Equal(t, map[string]string{"hostname": "host-a"}, list()[0].Tags)
+	tags, err := s.GetObjectTags(ctx, "movie-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"hostname": "host-a"}, tags)
+
+	require.NoError(t, s.RemoveObjectTags(ctx, "movie-1"))
+	assert.Nil(t, list()[0].Tags)
+	tags, err = s.GetObjectTags(ctx, "movie-1")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	_, err = s.GetObjectTags(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrObjectNotFound)
+
+	content, err := s.GetSourceContent(ctx, "movie-1")
+	require.NoError(t, err)
+	b, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.NoError(t, content.Close())
+	assert.Equal(t, "source1", string(b))
+
+	_, err = s.GetSourceContent(ctx, "../movie-1")
+	assert.Error(t, err)
+
+	require.NoError(t, s.DeleteSourceContent(ctx, "movie-1"))
+	assert.Empty(t, list())
+}
+
+func TestEncodedObjectDir(t *testing.T) {
+	ctx := context.Background()
+	e, err := NewEncodedObjectDir(t.TempDir(), "output", "")
+	require.NoError(t, err)
+	require.NoError(t, e.Ping(ctx))
+
+	localDir := t.TempDir()
+	writeFile(t, filepath.Join(localDir, "dash.mpd"), "manifest", time.Now())
+	writeFile(t, filepath.Join(localDir, "init0.m4s"), "init", time.Now())
+
+	files, err := e.Upload(ctx, "series/movie-1", localDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dash.mpd", "init0.m4s"}, paths(files))
+	assert.Equal(t, int64(4), files[1].Size)
+
+	size, err := e.Stat(ctx, "series/movie-1", "init0.m4s")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), size)
+
+	_, err = e.Stat(ctx, "series/movie-1", "not-exist.m4s")
+	assert.ErrorIs(t, err, domain.ErrObjectNotFound)
+
+	// 2回目は同じ内容のファイルを書き換えない
+	before, err := os.Stat(e.path("series/movie-1", "init0.m4s"))
+	require.NoError(t, err)
+	_, err = e.Upload(ctx, "series/movie-1", localDir)
+	require.NoError(t, err)
+	after, err := os.Stat(e.path("series/movie-1", "init0.m4s"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(before, after))
+
+	require.NoError(t, e.PutFile(ctx, "series/movie-1", entity.IntegrityManifestFileName, []byte("{}"), "application/json"))
+	f, err := e.GetObject(ctx, "series/movie-1", entity.IntegrityManifestFileName)
+	require.NoError(t, err)
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "{}", string(b))
+
+	total, err := e.TotalSize(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("manifest")+len("init")+len("{}")), total)
+
+	_, err = e.PresignedGetObject(ctx, "series/movie-1", "dash.mpd", time.Minute)
+	assert.ErrorIs(t, err, ErrPresignNotSupported)
+
+	// 失敗した再エンコードの出力は、公開中のファイルを消さない
+	failedDir := t.TempDir()
+	writeFile(t, filepath.Join(failedDir, "dash.mpd"), "manifest2", time.Now())
+	writeFile(t, filepath.Join(failedDir, "init0.m4s"), "init", time.Now())
+	require.NoError(t, os.Symlink(filepath.Join(failedDir, "not-exist"), filepath.Join(failedDir, "z.m4s")))
+	_, err = e.Upload(ctx, "series/movie-1", failedDir)
+	require.Error(t, err)
+	for _, name := range []string{"dash.mpd", "init0.m4s"} {
+		_, err = e.Stat(ctx, "series/movie-1", name)
+		assert.NoError(t, err, name)
+	}
+
+	// 再エンコードの出力で置き換えると、前のエンコードのファイルを消す
+	newDir := t.TempDir()
+	writeFile(t, filepath.Join(newDir, "dash.mpd"), "manifest2", time.Now())
+	writeFile(t, filepath.Join(newDir, "init0-v2.m4s"), "init2", time.Now())
+	files, err = e.Upload(ctx, "series/movie-1", newDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dash.mpd", "init0-v2.m4s"}, paths(files))
+	_, err = e.Stat(ctx, "series/movie-1", "init0.m4s")
+	assert.ErrorIs(t, err, domain.ErrObjectNotFound)
+	_, err = e.Stat(ctx, "series/movie-1", entity.IntegrityManifestFileName)
+	assert.NoError(t, err)
+}
+
+func paths(files []entity.IntegrityFile) []string {
+	p := make([]string, 0, len(files))
+	for _, f := range files {
+		p = append(p, f.Path)
+	}
+	return p
+}
+
+func TestRevocationDir(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewRevocationDir(t.TempDir(), "system")
+	require.NoError(t, err)
+
+	revocations := []entity.TokenRevocation{
+		{TokenID: "token/1"},
+		{Subject: "user-1"},
+		{Subject: "user-1", TenantID: "team-a"},
+	}
+	for _, revocation := range revocations {
+		require.NoError(t, r.AddRevocation(ctx, revocation))
+	}
+
+	list := func() []entity.TokenRevocation {
+		var got []entity.TokenRevocation
+		for revocation, err := range r.ListRevocations(ctx) {
+			require.NoError(t, err)
+			got = append(got, revocation)
+		}
+		return got
+	}
+	assert.ElementsMatch(t, revocations, list())
+
+	require.NoError(t, r.RemoveRevocation(ctx, revocations[2]))
+	assert.ElementsMatch(t, revocations[:2], list())
+
+	_, err = r.path(entity.TokenRevocation{})
+	assert.Error(t, err)
+}
+
+func TestUsageDir(t *testing.T) {
+	ctx := context.Background()
+	u, err := NewUsageDir(t.TempDir(), "system")
+	require.NoError(t, err)
+
+	got, err := u.GetUsage(ctx, "2025-01-01", "host-a")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	for _, date := range []string{"2025-01-01", "2025-01-02", "2025-01-03"} {
+		require.NoError(t, u.PutUsage(ctx, date, "host-a", []entity.UsageRecord{
+			{Date: date, TenantID: "default", MediaID: "movie-1", ServedBytes: 100},
+		}))
+	}
+
+	var dates []string
+	for record, err := range u.ListUsage(ctx, "2025-01-02", "2025-01-03") {
+		require.NoError(t, err)
+		dates = append(dates, record.Date)
+	}
+	slices.Sort(dates)
+	assert.Equal(t, []string{"2025-01-02", "2025-01-03"}, dates)
+}
+
+func TestJobLogDir(t *testing.T) {
+	ctx := context.Background()
+	j, err := NewJobLogDir(t.TempDir(), "system")
+	require.NoError(t, err)
+
+	_, err = j.GetJobLog(ctx, "default", "movie-1")
+	assert.ErrorIs(t, err, domain.ErrObjectNotFound)
+
+	require.NoError(t, j.PutJobLog(ctx, "default", "movie-1", []byte("compressed")))
+	r, err := j.GetJobLog(ctx, "default", "movie-1")
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "compressed", string(b))
+
+	_, err = j.GetJobLog(ctx, "team-a", "movie-1")
+	assert.ErrorIs(t, err, domain.ErrObjectNotFound)
+}
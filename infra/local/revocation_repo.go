@@ -0,0 +1,113 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+const revocationDirName = "revocations"
+
+// RevocationDir stores a revocation per file, in the same layout as the S3 backend.
+type RevocationDir struct {
+	dir string
+}
+
+func NewRevocationDir(root string, bucketName config.SystemBucketName) (*RevocationDir, error) {
+	dir, err := bucketDir(root, string(bucketName), revocationDirName)
+	if err != nil {
+		return nil, err
+	}
+	return &RevocationDir{dir: dir}, nil
+}
+
+func (r *RevocationDir) path(revocation entity.TokenRevocation) (string, error) {
+	var kind, name string
+	switch {
+	case revocation.TokenID != "":
+		kind, name = "token", revocation.TokenID
+	case revocation.Subject != "":
+		kind, name = "subject", revocation.Subject
+	default:
+		return "", errors.New("token id or subject is required")
+	}
+
+	if revocation.TenantID != "" && revocation.TenantID != entity.DefaultTenantID {
+		return filepath.Join(r.dir, "tenant", url.PathEscape(revocation.TenantID), kind, url.PathEscape(name)), nil
+	}
+	return filepath.Join(r.dir, kind, url.PathEscape(name)), nil
+}
+
+func (r *RevocationDir) Ping(ctx context.Context) error {
+	return dirExists(r.dir)
+}
+
+func (r *RevocationDir) AddRevocation(ctx context.Context, revocation entity.TokenRevocation) error {
+	p, err := r.path(revocation)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(revocation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revocation: %w", err)
+	}
+	if err := writeFileAtomic(p, bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("failed to put revocation: %w", err)
+	}
+	return nil
+}
+
+func (r *RevocationDir) RemoveRevocation(ctx context.Context, revocation entity.TokenRevocation) error {
+	p, err := r.path(revocation)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove revocation: %w", err)
+	}
+	return nil
+}
+
+func (r *RevocationDir) ListRevocations(ctx context.Context) iter.Seq2[entity.TokenRevocation, error] {
+	return func(yield func(entity.TokenRevocation, error) bool) {
+		_ = filepath.WalkDir(r.dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if !yield(entity.TokenRevocation{}, fmt.Errorf("failed to list revocations: %w", err)) {
+					return fs.SkipAll
+				}
+				return nil
+			}
+			if d.IsDir() || !d.Type().IsRegular() || isTempFile(d.Name()) {
+				return nil
+			}
+
+			revocation, err := readRevocation(p)
+			if !yield(revocation, err) {
+				return fs.SkipAll
+			}
+			return nil
+		})
+	}
+}
+
+func readRevocation(p string) (entity.TokenRevocation, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return entity.TokenRevocation{}, fmt.Errorf("failed to get revocation: %w", err)
+	}
+	var revocation entity.TokenRevocation
+	if err := json.Unmarshal(b, &revocation); err != nil {
+		return entity.TokenRevocation{}, fmt.Errorf("failed to decode revocation %s: %w", p, err)
+	}
+	return revocation, nil
+}
@@ -0,0 +1,189 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+const (
+	// タグは隠しディレクトリに <id>.json として保存する
+	tagsDirName = ".tags"
+	// コピー中のファイルを拾わないよう、更新されてからこの時間が経ったファイルだけを対象にする
+	sourceSettleTime = 10 * time.Second
+)
+
+// SourceDir watches a directory for uploaded sources. Subdirectories and hidden files are ignored.
+type SourceDir struct {
+	dir string
+}
+
+func NewSourceDir(root string, bucketName config.SourceClientBucketName, prefix string) (*SourceDir, error) {
+	dir, err := bucketDir(root, string(bucketName), prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &SourceDir{dir: dir}, nil
+}
+
+func (s *SourceDir) path(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsRune(id, '/') || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid source id: %s", id)
+	}
+	return filepath.Join(s.dir, id), nil
+}
+
+func (s *SourceDir) tagsPath(id string) string {
+	return filepath.Join(s.dir, tagsDirName, id+".json")
+}
+
+func (s *SourceDir) Ping(ctx context.Context) error {
+	return dirExists(s.dir)
+}
+
+func (s *SourceDir) ListUploadedFiles(ctx context.Context) iter.Seq2[entity.SourceFile, error] {
+	return func(yield func(entity.SourceFile, error) bool) {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil {
+			yield(entity.SourceFile{}, fmt.Errorf("failed to read %s: %w", s.dir, err))
+			return
+		}
+
+		for _, e := range entries {
+			if e.IsDir() || !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				if !yield(entity.SourceFile{}, fmt.Errorf("failed to stat %s: %w", e.Name(), err)) {
+					return
+				}
+				continue
+			}
+			if time.Since(info.ModTime()) < sourceSettleTime {
+				continue
+			}
+
+			tags, err := s.getTags(e.Name())
+			if !yield(entity.SourceFile{
+				ID:       e.Name(),
+				Size:     info.Size(),
+				Version:  fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()),
+				Tags:     tags,
+				Metadata: map[string]string{},
+			}, err) {
+				return
+			}
+		}
+	}
+}
+
+func (s *SourceDir) getTags(id string) (map[string]string, error) {
+	b, err := os.ReadFile(s.tagsPath(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read tags of %s: %w", id, err)
+	}
+	var tags map[string]string
+	if err := json.Unmarshal(b, &tags); err != nil {
+		return nil, fmt.Errorf("failed to decode tags of %s: %w", id, err)
+	}
+	return tags, nil
+}
+
+// GetObjectTags returns the tags of the source. It returns domain.ErrObjectNotFound if the source does not exist.
+func (s *SourceDir) GetObjectTags(ctx context.Context, id string) (map[string]string, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", domain.ErrObjectNotFound, path)
+		}
+		return nil, fmt.Errorf("failed to stat source: %w", err)
+	}
+	tags, err := s.getTags(id)
+	if err != nil {
+		return nil, err
+	}
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return tags, nil
+}
+
+func (s *SourceDir) SetObjectTags(ctx context.Context, id string, tags map[string]string) error {
+	if _, err := s.path(id); err != nil {
+		return err
+	}
+	b, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	if err := writeFileAtomic(s.tagsPath(id), bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("failed to set tags: %w", err)
+	}
+	return nil
+}
+
+func (s *SourceDir) RemoveObjectTags(ctx context.Context, id string) error {
+	if _, err := s.path(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.tagsPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove tags: %w", err)
+	}
+	return nil
+}
+
+func (s *SourceDir) GetSourceContent(ctx context.Context, id string) (io.ReadSeekCloser, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source: %w", err)
+	}
+	return f, nil
+}
+
+// SourceURL returns the file URL of the source, which ffmpeg reads directly.
+func (s *SourceDir) SourceURL(ctx context.Context, id string, expiry time.Duration) (*url.URL, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(path)}, nil
+}
+
+func (s *SourceDir) DeleteSourceContent(ctx context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete source: %w", err)
+	}
+	return s.RemoveObjectTags(ctx, id)
+}
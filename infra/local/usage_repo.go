@@ -0,0 +1,117 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+const usageDirName = "usage"
+
+// UsageDir stores the usage as <dir>/<date>/<hostname>.json.
+type UsageDir struct {
+	dir string
+}
+
+func NewUsageDir(root string, bucketName config.SystemBucketName) (*UsageDir, error) {
+	dir, err := bucketDir(root, string(bucketName), usageDirName)
+	if err != nil {
+		return nil, err
+	}
+	return &UsageDir{dir: dir}, nil
+}
+
+func (u *UsageDir) path(date string, hostname string) string {
+	return filepath.Join(u.dir, date, url.PathEscape(hostname)+".json")
+}
+
+// GetUsage returns the records of the host in the day. It returns no records if the host has not recorded anything.
+func (u *UsageDir) GetUsage(ctx context.Context, date string, hostname string) ([]entity.UsageRecord, error) {
+	records, err := readUsage(u.path(date, hostname))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []entity.UsageRecord{}, nil
+		}
+		return nil, err
+	}
+	return records, nil
+}
+
+// PutUsage replaces the records of the host in the day.
+func (u *UsageDir) PutUsage(ctx context.Context, date string, hostname string, records []entity.UsageRecord) error {
+	b, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+	if err := writeFileAtomic(u.path(date, hostname), bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("failed to put usage: %w", err)
+	}
+	return nil
+}
+
+// ListUsage lists the records of all hosts from the day `from` to the day `to`. Empty means unbounded.
+func (u *UsageDir) ListUsage(ctx context.Context, from string, to string) iter.Seq2[entity.UsageRecord, error] {
+	return func(yield func(entity.UsageRecord, error) bool) {
+		// os.ReadDirは名前順に返す
+		dates, err := os.ReadDir(u.dir)
+		if err != nil {
+			yield(entity.UsageRecord{}, fmt.Errorf("failed to list usage: %w", err))
+			return
+		}
+		for _, date := range dates {
+			if !date.IsDir() || (from != "" && date.Name() < from) {
+				continue
+			}
+			if to != "" && date.Name() > to {
+				return
+			}
+
+			hosts, err := os.ReadDir(filepath.Join(u.dir, date.Name()))
+			if err != nil {
+				if !yield(entity.UsageRecord{}, fmt.Errorf("failed to list usage: %w", err)) {
+					return
+				}
+				continue
+			}
+			for _, host := range hosts {
+				if host.IsDir() || isTempFile(host.Name()) {
+					continue
+				}
+				records, err := readUsage(filepath.Join(u.dir, date.Name(), host.Name()))
+				if err != nil {
+					if !yield(entity.UsageRecord{}, err) {
+						return
+					}
+					continue
+				}
+				for _, record := range records {
+					if !yield(record, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+func readUsage(p string) ([]entity.UsageRecord, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	var records []entity.UsageRecord
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode usage %s: %w", p, err)
+	}
+	return records, nil
+}
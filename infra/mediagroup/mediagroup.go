@@ -0,0 +1,56 @@
+package mediagroup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// mediaGroupsFileFormat is the format of MEDIA_GROUPS_FILE.
+//
+//	{
+//	  "groups": {
+//	    "anime": ["series-42-*", "movie-1"]
+//	  }
+//	}
+type mediaGroupsFileFormat struct {
+	Groups map[string][]string `json:"groups"`
+}
+
+func NewMediaGroups(mediaGroupsFile config.MediaGroupsFile) (entity.MediaGroups, error) {
+	groups := make(entity.MediaGroups)
+	if mediaGroupsFile == "" {
+		return groups, nil
+	}
+
+	b, err := os.ReadFile(string(mediaGroupsFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media groups file: %w", err)
+	}
+
+	var f mediaGroupsFileFormat
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse media groups file: %w", err)
+	}
+
+	for name, grants := range f.Groups {
+		if name == "" {
+			return nil, fmt.Errorf("%w: empty group name", entity.ErrInvalidMediaGrant)
+		}
+		for _, grant := range grants {
+			// グループのネストは許可しない
+			if strings.HasPrefix(grant, entity.MediaGroupPrefix) {
+				return nil, fmt.Errorf("%w: nested group %q in group %s", entity.ErrInvalidMediaGrant, grant, name)
+			}
+			if err := entity.ValidateMediaGrant(grant); err != nil {
+				return nil, fmt.Errorf("invalid grant in group %s: %w", name, err)
+			}
+		}
+		groups[name] = grants
+	}
+	return groups, nil
+}
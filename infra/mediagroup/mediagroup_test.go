@@ -0,0 +1,69 @@
+package mediagroup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+func TestNewMediaGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    entity.MediaGroups
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			content: `{"groups": {"anime": ["series-42-*", "movie-1"]}}`,
+			want:    entity.MediaGroups{"anime": {"series-42-*", "movie-1"}},
+			wantErr: false,
+		},
+		{
+			name:    "nested group",
+			content: `{"groups": {"all": ["group:anime"]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid grant",
+			content: `{"groups": {"anime": ["../*"]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			content: `{"groups": `,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "groups.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := NewMediaGroups(config.MediaGroupsFile(path))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewMediaGroups() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewMediaGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		got, err := NewMediaGroups("")
+		if err != nil {
+			t.Errorf("NewMediaGroups() error = %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("NewMediaGroups() = %v, want empty", got)
+		}
+	})
+}
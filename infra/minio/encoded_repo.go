@@ -1,30 +1,81 @@
 package minio
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
+	"net/url"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/tracer"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/fileutil"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/mpd"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
 )
 
+// checksumMetadataKey is the user metadata holding the MD5 of the uploaded file, used to skip the files already uploaded.
+// ETag is not used because it is not the MD5 for multipart uploads.
+const checksumMetadataKey = "content-md5sum"
+
 type EncodedObjectClient struct {
-	bucketName string
-	client     *minio.Client
+	bucketName   string
+	prefix       string
+	uploadConfig config.MinIOUploadConfig
+	client       *minio.Client
+	publicClient *PublicClient
 }
 
-func NewEncodedObjectClient(bucketName config.EncodedObjectBucketName, client *minio.Client) *EncodedObjectClient {
+// NewEncodedObjectClient creates a client for the media under prefix, which is either empty or ends with a slash.
+func NewEncodedObjectClient(bucketName config.EncodedObjectBucketName, prefix string, uploadConfig config.MinIOUploadConfig, client *minio.Client, publicClient *PublicClient) *EncodedObjectClient {
 	return &EncodedObjectClient{
-		bucketName: string(bucketName),
-		client:     client,
+		bucketName:   string(bucketName),
+		prefix:       prefix,
+		uploadConfig: uploadConfig,
+		client:       client,
+		publicClient: publicClient,
 	}
 }
 
-func (m *EncodedObjectClient) Upload(ctx context.Context, mediaID string, localDir string) error {
+func (m *EncodedObjectClient) objectName(mediaID string, fileName string) string {
+	return m.prefix + path.Join(mediaID, fileName)
+}
+
+func (m *EncodedObjectClient) Ping(ctx context.Context) error {
+	return bucketExists(ctx, m.client, m.bucketName)
+}
+
+type localObject struct {
+	path         string
+	relativePath string
+	objectName   string
+	size         int64
+}
+
+// Upload publishes the files in localDir. The segments and init files are uploaded concurrently and verified first,
+// and the manifests are replaced last, so that a manifest never refers to missing files.
+// The files already uploaded with the same size and checksum (e.g. by a run interrupted by a crash) are skipped.
+// On failure, the objects created by this call are removed. The objects which existed before are never removed,
+// since the published manifest may refer to them.
+// After the manifests are replaced, the objects of the previous encodes are removed.
+// It returns the SHA-256 of the files for the integrity manifest.
+func (m *EncodedObjectClient) Upload(ctx context.Context, mediaID string, localDir string) ([]entity.IntegrityFile, error) {
+	var files, manifests []localObject
 	if err := filepath.WalkDir(localDir, func(localFilePath string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -37,19 +88,262 @@ func (m *EncodedObjectClient) Upload(ctx context.Context, mediaID string, localD
 		if err != nil {
 			return fmt.Errorf("failed to get relative path: %w", err)
 		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to get file info: %w", err)
+		}
 
-		objectPath := path.Join(mediaID, filepath.ToSlash(localRelativeFilePath))
-		if _, err := m.client.FPutObject(ctx, m.bucketName, objectPath, localFilePath, minio.PutObjectOptions{}); err != nil {
-			return fmt.Errorf("failed to put object: %w", err)
+		object := localObject{
+			path:         localFilePath,
+			relativePath: filepath.ToSlash(localRelativeFilePath),
+			objectName:   m.objectName(mediaID, filepath.ToSlash(localRelativeFilePath)),
+			size:         info.Size(),
+		}
+		if mpd.IsManifest(d.Name()) {
+			manifests = append(manifests, object)
+		} else {
+			files = append(files, object)
 		}
 		return nil
 	}); err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	var (
+		mu        sync.Mutex
+		created   []string // このアップロードで作成したオブジェクト
+		integrity = make([]entity.IntegrityFile, 0, len(files)+len(manifests))
+	)
+	put := func(ctx context.Context, object localObject) error {
+		sha256sum, existed, err := m.putObjectWithRetry(ctx, object)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !existed {
+			created = append(created, object.objectName)
+		}
+		integrity = append(integrity, entity.IntegrityFile{Path: object.relativePath, Size: object.size, SHA256: sha256sum})
+		return nil
+	}
+	rollback := func(err error) error {
+		if rollbackErr := m.removeObjects(context.WithoutCancel(ctx), created); rollbackErr != nil {
+			return fmt.Errorf("failed to upload directory: %w", errors.Join(err, rollbackErr))
+		}
 		return fmt.Errorf("failed to upload directory: %w", err)
 	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(m.uploadConfig.Concurrency, 1))
+	for _, object := range files {
+		eg.Go(func() error {
+			return put(egCtx, object)
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, rollback(err)
+	}
+
+	// 置き換えたマニフェストが参照するので、1つ目を置き換えた後はロールバックしない
+	for i, object := range manifests {
+		if err := put(ctx, object); err != nil {
+			if i == 0 {
+				return nil, rollback(err)
+			}
+			return nil, fmt.Errorf("failed to upload directory: %w", err)
+		}
+	}
+
+	keep := make(map[string]struct{}, len(files)+len(manifests)+1)
+	for _, object := range slices.Concat(files, manifests) {
+		keep[object.objectName] = struct{}{}
+	}
+	keep[m.objectName(mediaID, entity.IntegrityManifestFileName)] = struct{}{}
+	if err := m.removeStaleObjects(ctx, mediaID, keep); err != nil {
+		slog.Warn("failed to remove stale objects", slog.String("mediaID", mediaID), slog.Any("error", err))
+		// returnしない
+	}
+
+	slices.SortFunc(integrity, func(a, b entity.IntegrityFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return integrity, nil
+}
+
+// removeStaleObjects removes the objects of the media which are not in keep, i.e. the ones of the previous encodes.
+func (m *EncodedObjectClient) removeStaleObjects(ctx context.Context, mediaID string, keep map[string]struct{}) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stale []string
+	for info := range m.client.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{
+		Prefix:    m.objectName(mediaID, "") + "/",
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return fmt.Errorf("failed to list objects: %w", info.Err)
+		}
+		if _, ok := keep[info.Key]; !ok {
+			stale = append(stale, info.Key)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	return m.removeObjects(ctx, stale)
+}
+
+// putObjectWithRetry uploads the file unless it is already uploaded.
+// It returns the SHA-256 of the file, and whether the object existed before.
+func (m *EncodedObjectClient) putObjectWithRetry(ctx context.Context, object localObject) (string, bool, error) {
+	checksum, sha256sum, err := fileutil.Checksums(object.path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to calculate checksum of %s: %w", object.path, err)
+	}
+
+	stat, err := m.client.StatObject(ctx, m.bucketName, object.objectName, minio.StatObjectOptions{})
+	// 存在を確認できない場合は、ロールバックで消さないよう存在したものとして扱う
+	existed := err == nil || minio.ToErrorResponse(err).Code != "NoSuchKey"
+	if err == nil && stat.Size == object.size && objectChecksum(stat) == checksum {
+		slog.Debug("object is already uploaded, skip", slog.String("object", object.objectName))
+		return sha256sum, true, nil
+	}
+
+	delay := m.uploadConfig.RetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err = m.putObject(ctx, object, checksum)
+		if err == nil {
+			return sha256sum, existed, nil
+		}
+		if attempt >= m.uploadConfig.MaxRetries {
+			return "", false, err
+		}
+		slog.Warn("failed to upload object, retrying",
+			slog.String("object", object.objectName),
+			slog.Int("attempt", attempt+1),
+			slog.Any("error", err),
+		)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", false, errors.Join(err, ctx.Err())
+		}
+		delay *= 2
+	}
+}
+
+// putObject uploads the file and checks that the stored object has the same size.
+func (m *EncodedObjectClient) putObject(ctx context.Context, object localObject, checksum string) error {
+	ctx, span := tracer.Tracer.Start(ctx, "upload object", trace.WithAttributes(
+		attribute.String("object.bucket", m.bucketName),
+		attribute.String("object.key", object.objectName),
+	))
+	defer span.End()
+
+	if _, err := m.client.FPutObject(ctx, m.bucketName, object.objectName, object.path, minio.PutObjectOptions{
+		UserMetadata: map[string]string{checksumMetadataKey: checksum},
+	}); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to put object")
+		return fmt.Errorf("failed to put object %s: %w", object.objectName, err)
+	}
+
+	stat, err := m.client.StatObject(ctx, m.bucketName, object.objectName, minio.StatObjectOptions{})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to stat object")
+		return fmt.Errorf("failed to stat object %s: %w", object.objectName, err)
+	}
+	if stat.Size != object.size {
+		err := fmt.Errorf("size mismatch of object %s: expected %d, got %d", object.objectName, object.size, stat.Size)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "size mismatch")
+		return err
+	}
+	span.SetAttributes(attribute.Int64("object.size", stat.Size))
 	return nil
 }
 
+func objectChecksum(info minio.ObjectInfo) string {
+	for k, v := range info.UserMetadata {
+		if key, ok := cutPrefixFold(k, userMetadataPrefix); ok {
+			k = key
+		}
+		if strings.EqualFold(k, checksumMetadataKey) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (m *EncodedObjectClient) removeObjects(ctx context.Context, objectNames []string) error {
+	objectsCh := make(chan minio.ObjectInfo, len(objectNames))
+	for _, name := range objectNames {
+		objectsCh <- minio.ObjectInfo{Key: name}
+	}
+	close(objectsCh)
+
+	var errs []error
+	for result := range m.client.RemoveObjects(ctx, m.bucketName, objectsCh, minio.RemoveObjectsOptions{}) {
+		errs = append(errs, fmt.Errorf("failed to remove object %s: %w", result.ObjectName, result.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// PutFile writes a small file, such as the integrity manifest, under the media prefix.
+func (m *EncodedObjectClient) PutFile(ctx context.Context, mediaID string, fileName string, content []byte, contentType string) error {
+	objectPath := m.objectName(mediaID, fileName)
+	if _, err := m.client.PutObject(ctx, m.bucketName, objectPath, bytes.NewReader(content), int64(len(content)), minio.PutObjectOptions{
+		ContentType: contentType,
+	}); err != nil {
+		return fmt.Errorf("failed to put object %s: %w", objectPath, err)
+	}
+	return nil
+}
+
+// Stat returns the size of the object. It returns domain.ErrObjectNotFound if the object does not exist.
+func (m *EncodedObjectClient) Stat(ctx context.Context, mediaID string, fileName string) (int64, error) {
+	objectPath := m.objectName(mediaID, fileName)
+	info, err := m.client.StatObject(ctx, m.bucketName, objectPath, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return 0, fmt.Errorf("%w: %s", domain.ErrObjectNotFound, objectPath)
+		}
+		return 0, fmt.Errorf("failed to stat object %s: %w", objectPath, err)
+	}
+	return info.Size, nil
+}
+
+// TotalSize returns the total size of the objects under the prefix.
+func (m *EncodedObjectClient) TotalSize(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var total int64
+	for info := range m.client.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{
+		Prefix:    m.prefix,
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return 0, fmt.Errorf("failed to list objects: %w", info.Err)
+		}
+		total += info.Size
+	}
+	return total, nil
+}
+
 func (m *EncodedObjectClient) GetObject(ctx context.Context, mediaID string, fileName string) (io.ReadSeekCloser, error) {
-	objectPath := path.Join(mediaID, fileName)
+	objectPath := m.objectName(mediaID, fileName)
 	return m.client.GetObject(ctx, m.bucketName, objectPath, minio.GetObjectOptions{})
 }
+
+func (m *EncodedObjectClient) PresignedGetObject(ctx context.Context, mediaID string, fileName string, expiry time.Duration) (*url.URL, error) {
+	objectPath := m.objectName(mediaID, fileName)
+	u, err := m.publicClient.PresignedGetObject(ctx, m.bucketName, objectPath, expiry, url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign object: %w", err)
+	}
+	return u, nil
+}
@@ -0,0 +1,139 @@
+package minio
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+var _ = Describe("EncodedObjectClient", func() {
+	client := NewEncodedObjectClient(outputBucketName, "", config.MinIOUploadConfig{
+		Concurrency:    4,
+		MaxRetries:     1,
+		RetryBaseDelay: 10 * time.Millisecond,
+	}, minioClient, nil)
+
+	ctx := context.Background()
+
+	createLocalDir := func(files map[string]string) string {
+		dir := GinkgoT().TempDir()
+		for name, content := range files {
+			Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)).To(Succeed())
+		}
+		return dir
+	}
+
+	It("Normal", func() {
+		files := map[string]string{
+			"dash.mpd":         "manifest",
+			"init0.m4s":        "init",
+			"chunk0-00001.m4s": "chunk",
+			"chunk0-00002.m4s": "chunk2",
+		}
+		integrity, err := client.Upload(ctx, "upload-normal", createLocalDir(files))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(integrity).To(HaveLen(len(files)))
+		Expect(integrity[0]).To(Equal(entity.IntegrityFile{
+			Path:   "chunk0-00001.m4s",
+			Size:   5,
+			SHA256: "6c87f68371b28954707ebb92afee7ccffb74c6f71ec8fea8a98cf6104289585b",
+		}))
+
+		for name, content := range files {
+			object, err := client.GetObject(ctx, "upload-normal", name)
+			Expect(err).NotTo(HaveOccurred())
+			b, err := io.ReadAll(object)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(b)).To(Equal(content))
+		}
+	})
+
+	It("Resume", func() {
+		dir := createLocalDir(map[string]string{
+			"dash.mpd":  "manifest",
+			"init0.m4s": "init",
+		})
+		_, err := client.Upload(ctx, "upload-resume", dir)
+		Expect(err).NotTo(HaveOccurred())
+		before, err := minioClient.StatObject(ctx, outputBucketName, "upload-resume/init0.m4s", minio.StatObjectOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.Upload(ctx, "upload-resume", dir)
+		Expect(err).NotTo(HaveOccurred())
+		after, err := minioClient.StatObject(ctx, outputBucketName, "upload-resume/init0.m4s", minio.StatObjectOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(after.LastModified).To(Equal(before.LastModified))
+	})
+
+	It("Rollback", func() {
+		dir := createLocalDir(map[string]string{
+			"dash.mpd":  "manifest",
+			"init0.m4s": "init",
+		})
+		// 読み込めないセグメント
+		Expect(os.Symlink(filepath.Join(dir, "not-exist"), filepath.Join(dir, "z.m4s"))).To(Succeed())
+
+		_, err := client.Upload(ctx, "upload-rollback", dir)
+		Expect(err).To(HaveOccurred())
+
+		for _, name := range []string{"dash.mpd", "init0.m4s"} {
+			_, err := minioClient.StatObject(ctx, outputBucketName, "upload-rollback/"+name, minio.StatObjectOptions{})
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("Replace", func() {
+		_, err := client.Upload(ctx, "upload-replace", createLocalDir(map[string]string{
+			"dash.mpd":  "manifest",
+			"init0.m4s": "init",
+		}))
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Failed upload keeps the published objects")
+		dir := createLocalDir(map[string]string{
+			"dash.mpd":  "manifest2",
+			"init0.m4s": "init",
+		})
+		Expect(os.Symlink(filepath.Join(dir, "not-exist"), filepath.Join(dir, "z.m4s"))).To(Succeed())
+		_, err = client.Upload(ctx, "upload-replace", dir)
+		Expect(err).To(HaveOccurred())
+		for _, name := range []string{"dash.mpd", "init0.m4s"} {
+			_, err := minioClient.StatObject(ctx, outputBucketName, "upload-replace/"+name, minio.StatObjectOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		By("New encode removes the objects of the previous one")
+		_, err = client.Upload(ctx, "upload-replace", createLocalDir(map[string]string{
+			"dash.mpd":     "manifest2",
+			"init0-v2.m4s": "init2",
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = minioClient.StatObject(ctx, outputBucketName, "upload-replace/init0.m4s", minio.StatObjectOptions{})
+		Expect(err).To(HaveOccurred())
+		_, err = minioClient.StatObject(ctx, outputBucketName, "upload-replace/init0-v2.m4s", minio.StatObjectOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("TotalSize", func() {
+		prefixed := NewEncodedObjectClient(outputBucketName, "total-size/", config.MinIOUploadConfig{
+			Concurrency: 1,
+		}, minioClient, nil)
+		_, err := prefixed.Upload(ctx, "movie-1", createLocalDir(map[string]string{
+			"dash.mpd":  "manifest",
+			"init0.m4s": "init",
+		}))
+		Expect(err).NotTo(HaveOccurred())
+
+		total, err := prefixed.TotalSize(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total).To(Equal(int64(len("manifest") + len("init"))))
+	})
+})
@@ -0,0 +1,61 @@
+package minio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+)
+
+// logs/<tenantID>/<mediaID>.log.gz
+const jobLogPrefix = "logs/"
+
+type JobLogClient struct {
+	bucketName string
+	client     *minio.Client
+}
+
+func NewJobLogClient(bucketName config.SystemBucketName, client *minio.Client) *JobLogClient {
+	return &JobLogClient{
+		bucketName: string(bucketName),
+		client:     client,
+	}
+}
+
+func jobLogObjectPath(tenantID string, mediaID string) string {
+	return path.Join(jobLogPrefix, tenantID, mediaID+".log.gz")
+}
+
+// PutJobLog stores the gzip compressed ffmpeg log of the job.
+func (m *JobLogClient) PutJobLog(ctx context.Context, tenantID string, mediaID string, content []byte) error {
+	if _, err := m.client.PutObject(ctx, m.bucketName, jobLogObjectPath(tenantID, mediaID), bytes.NewReader(content), int64(len(content)), minio.PutObjectOptions{
+		// Content-Encodingを付けると、取得時にHTTPクライアントが展開してしまうことがある
+		ContentType: "application/gzip",
+	}); err != nil {
+		return fmt.Errorf("failed to put job log: %w", err)
+	}
+	return nil
+}
+
+// GetJobLog returns the gzip compressed ffmpeg log of the job.
+func (m *JobLogClient) GetJobLog(ctx context.Context, tenantID string, mediaID string) (io.ReadCloser, error) {
+	objectPath := jobLogObjectPath(tenantID, mediaID)
+	object, err := m.client.GetObject(ctx, m.bucketName, objectPath, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job log: %w", err)
+	}
+	// GetObjectは読むまでエラーを返さないので、ここで存在を確認する
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%w: %s", domain.ErrObjectNotFound, objectPath)
+		}
+		return nil, fmt.Errorf("failed to stat job log: %w", err)
+	}
+	return object, nil
+}
@@ -0,0 +1,32 @@
+package minio
+
+import (
+	"context"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+)
+
+var _ = Describe("JobLogClient", Ordered, func() {
+	client := NewJobLogClient(systemBucketName, minioClient)
+
+	ctx := context.Background()
+
+	It("Not Found", func() {
+		_, err := client.GetJobLog(ctx, "default", "media-1")
+		Expect(err).To(MatchError(domain.ErrObjectNotFound))
+	})
+
+	It("Normal", func() {
+		Expect(client.PutJobLog(ctx, "default", "media-1", []byte("compressed"))).To(Succeed())
+
+		r, err := client.GetJobLog(ctx, "default", "media-1")
+		Expect(err).NotTo(HaveOccurred())
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("compressed"))
+	})
+})
@@ -1,6 +1,7 @@
 package minio
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/minio/minio-go/v7"
@@ -8,13 +9,76 @@ import (
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
 )
 
+// PublicClient is used only for signing URLs that are handed out to end users.
+type PublicClient struct {
+	*minio.Client
+}
+
 func NewMinIOClient(cfg config.Config) (*minio.Client, error) {
 	minioClient, err := minio.New(cfg.MinIOEndpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.MinIOAccessKey, cfg.MinIOSecretKey, ""),
-		Secure: cfg.MinIOUseSSL,
+		Creds:        newCredentials(cfg),
+		Secure:       cfg.MinIOUseSSL,
+		Region:       cfg.MinIORegion,
+		BucketLookup: bucketLookup(cfg.MinIOBucketLookup),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create minio client: %w", err)
 	}
 	return minioClient, nil
 }
+
+// newCredentials uses the static keys if set. Otherwise, the keys are taken from the environment
+// (AWS_ACCESS_KEY_ID, MINIO_ROOT_USER, ...) or the IAM role, as AWS SDKs do.
+func newCredentials(cfg config.Config) *credentials.Credentials {
+	if cfg.MinIOAccessKey != "" {
+		return credentials.NewStaticV4(cfg.MinIOAccessKey, cfg.MinIOSecretKey, "")
+	}
+	return credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+}
+
+func bucketLookup(lookup config.MinIOBucketLookup) minio.BucketLookupType {
+	switch lookup {
+	case config.MinIOBucketLookupPath:
+		return minio.BucketLookupPath
+	case config.MinIOBucketLookupVirtualHost:
+		return minio.BucketLookupDNS
+	default:
+		return minio.BucketLookupAuto
+	}
+}
+
+// bucketExists fails if the bucket does not exist or MinIO is unreachable.
+func bucketExists(ctx context.Context, client *minio.Client, bucketName string) error {
+	ok, err := client.BucketExists(ctx, bucketName)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket %s: %w", bucketName, err)
+	}
+	if !ok {
+		return fmt.Errorf("bucket %s does not exist", bucketName)
+	}
+	return nil
+}
+
+func NewMinIOPublicClient(cfg config.Config) (*PublicClient, error) {
+	endpoint := cfg.MinIOPublicEndpoint
+	if endpoint == "" {
+		endpoint = cfg.MinIOEndpoint
+	}
+
+	// presignはローカルで計算されるが、Regionが空だとbucket locationを取得しに行ってしまう
+	minioClient, err := minio.New(endpoint, &minio.Options{
+		Creds:        newCredentials(cfg),
+		Secure:       cfg.MinIOPublicSecure(),
+		Region:       cfg.MinIORegion,
+		BucketLookup: bucketLookup(cfg.MinIOBucketLookup),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create minio public client: %w", err)
+	}
+	return &PublicClient{minioClient}, nil
+}
@@ -20,6 +20,7 @@ const (
 	secretKey              = "mocksecretkey"
 	sourceClientBucketName = "mpeg-dash-encoder-source-upload"
 	outputBucketName       = "mpeg-dash-encoder-output"
+	systemBucketName       = "mpeg-dash-encoder-system"
 )
 
 var (
@@ -95,7 +96,7 @@ func TestMain(m *testing.M) {
 	}
 
 	ctx := context.Background()
-	for _, bucketName := range []string{sourceClientBucketName, outputBucketName} {
+	for _, bucketName := range []string{sourceClientBucketName, outputBucketName, systemBucketName} {
 		bucketExist, err := minioClient.BucketExists(ctx, bucketName)
 		if err != nil {
 			slog.Error("failed to check bucket", slog.Any("error", err))
@@ -0,0 +1,122 @@
+package minio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/url"
+	"path"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+const (
+	revocationPrefix       = "revocations/"
+	tenantRevocationPrefix = revocationPrefix + "tenant/"
+)
+
+type RevocationClient struct {
+	bucketName string
+	client     *minio.Client
+}
+
+func NewRevocationClient(bucketName config.SystemBucketName, client *minio.Client) *RevocationClient {
+	return &RevocationClient{
+		bucketName: string(bucketName),
+		client:     client,
+	}
+}
+
+func revocationObjectPath(revocation entity.TokenRevocation) (string, error) {
+	var kind, name string
+	switch {
+	case revocation.TokenID != "":
+		kind, name = "token", revocation.TokenID
+	case revocation.Subject != "":
+		kind, name = "subject", revocation.Subject
+	default:
+		return "", errors.New("token id or subject is required")
+	}
+
+	// トークンIDとsubjectはテナントごとに独立している
+	if revocation.TenantID != "" && revocation.TenantID != entity.DefaultTenantID {
+		return path.Join(tenantRevocationPrefix, url.PathEscape(revocation.TenantID), kind, url.PathEscape(name)), nil
+	}
+	return path.Join(revocationPrefix, kind, url.PathEscape(name)), nil
+}
+
+func (m *RevocationClient) Ping(ctx context.Context) error {
+	return bucketExists(ctx, m.client, m.bucketName)
+}
+
+func (m *RevocationClient) AddRevocation(ctx context.Context, revocation entity.TokenRevocation) error {
+	objectPath, err := revocationObjectPath(revocation)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(revocation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revocation: %w", err)
+	}
+
+	if _, err := m.client.PutObject(ctx, m.bucketName, objectPath, bytes.NewReader(b), int64(len(b)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	}); err != nil {
+		return fmt.Errorf("failed to put revocation: %w", err)
+	}
+	return nil
+}
+
+func (m *RevocationClient) RemoveRevocation(ctx context.Context, revocation entity.TokenRevocation) error {
+	objectPath, err := revocationObjectPath(revocation)
+	if err != nil {
+		return err
+	}
+
+	if err := m.client.RemoveObject(ctx, m.bucketName, objectPath, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove revocation: %w", err)
+	}
+	return nil
+}
+
+func (m *RevocationClient) ListRevocations(ctx context.Context) iter.Seq2[entity.TokenRevocation, error] {
+	infos := m.client.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{
+		Prefix:    revocationPrefix,
+		Recursive: true,
+	})
+	return func(yield func(entity.TokenRevocation, error) bool) {
+		for info := range infos {
+			if info.Err != nil {
+				if !yield(entity.TokenRevocation{}, fmt.Errorf("failed to list revocations: %w", info.Err)) {
+					return
+				}
+				continue
+			}
+
+			revocation, err := m.getRevocation(ctx, info.Key)
+			if !yield(revocation, err) {
+				return
+			}
+		}
+	}
+}
+
+func (m *RevocationClient) getRevocation(ctx context.Context, objectPath string) (entity.TokenRevocation, error) {
+	obj, err := m.client.GetObject(ctx, m.bucketName, objectPath, minio.GetObjectOptions{})
+	if err != nil {
+		return entity.TokenRevocation{}, fmt.Errorf("failed to get revocation: %w", err)
+	}
+	defer obj.Close()
+
+	var revocation entity.TokenRevocation
+	if err := json.NewDecoder(obj).Decode(&revocation); err != nil {
+		return entity.TokenRevocation{}, fmt.Errorf("failed to decode revocation %s: %w", objectPath, err)
+	}
+	return revocation, nil
+}
@@ -0,0 +1,52 @@
+package minio
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+var _ = Describe("RevocationClient", Ordered, func() {
+	client := NewRevocationClient(systemBucketName, minioClient)
+
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	revocations := []entity.TokenRevocation{
+		{TokenID: "token-1", RevokedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{Subject: "user/1", RevokedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{TenantID: "tenant-a", TokenID: "token-1", RevokedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{TenantID: "tenant-a", Subject: "user/1", RevokedAt: now, ExpiresAt: now.Add(time.Hour)},
+	}
+
+	It("Normal", func() {
+		for _, revocation := range revocations {
+			err := client.AddRevocation(ctx, revocation)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		got := make([]entity.TokenRevocation, 0)
+		for revocation, err := range client.ListRevocations(ctx) {
+			Expect(err).NotTo(HaveOccurred())
+			got = append(got, revocation)
+		}
+		Expect(got).To(ConsistOf(revocations))
+
+		for _, revocation := range revocations {
+			err := client.RemoveRevocation(ctx, revocation)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		for range client.ListRevocations(ctx) {
+			Fail("revocations should be removed")
+		}
+	})
+
+	It("Invalid Revocation", func() {
+		err := client.AddRevocation(ctx, entity.TokenRevocation{RevokedAt: now})
+		Expect(err).To(HaveOccurred())
+	})
+})
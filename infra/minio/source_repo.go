@@ -6,27 +6,45 @@ import (
 	"fmt"
 	"io"
 	"iter"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	miniotags "github.com/minio/minio-go/v7/pkg/tags"
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
 	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
 )
 
+const userMetadataPrefix = "x-amz-meta-"
+
 type SourceClient struct {
 	bucketName string
+	prefix     string
 	client     *minio.Client
 }
 
-func NewSourceClient(bucketName config.SourceClientBucketName, client *minio.Client) *SourceClient {
+// NewSourceClient creates a client for the sources under prefix, which is either empty or ends with a slash.
+func NewSourceClient(bucketName config.SourceClientBucketName, prefix string, client *minio.Client) *SourceClient {
 	return &SourceClient{
 		bucketName: string(bucketName),
+		prefix:     prefix,
 		client:     client,
 	}
 }
 
+func (m *SourceClient) objectName(id string) string {
+	return m.prefix + id
+}
+
+func (m *SourceClient) Ping(ctx context.Context) error {
+	return bucketExists(ctx, m.client, m.bucketName)
+}
+
 func (m *SourceClient) ListUploadedFiles(ctx context.Context) iter.Seq2[entity.SourceFile, error] {
 	infos := m.client.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{
+		Prefix:       m.prefix,
 		WithMetadata: true,
 	})
 	return func(yield func(entity.SourceFile, error) bool) {
@@ -41,42 +59,91 @@ func (m *SourceClient) ListUploadedFiles(ctx context.Context) iter.Seq2[entity.S
 					return
 				}
 			}
-			if !yield(entity.SourceFile{ID: info.Key, Tags: info.UserTags}, nil) {
+			// サブディレクトリは対象外
+			if strings.HasSuffix(info.Key, "/") {
+				continue
+			}
+			if !yield(entity.SourceFile{
+				ID:       strings.TrimPrefix(info.Key, m.prefix),
+				Size:     info.Size,
+				Version:  info.ETag,
+				Tags:     info.UserTags,
+				Metadata: userMetadata(info.UserMetadata),
+			}, nil) {
 				return
 			}
 		}
 	}
 }
 
+func userMetadata(metadata map[string]string) map[string]string {
+	m := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if key, ok := cutPrefixFold(k, userMetadataPrefix); ok {
+			m[strings.ToLower(key)] = v
+		}
+	}
+	return m
+}
+
+func cutPrefixFold(s string, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// GetObjectTags returns the tags of the source. It returns domain.ErrObjectNotFound if the source does not exist.
+func (m *SourceClient) GetObjectTags(ctx context.Context, id string) (map[string]string, error) {
+	t, err := m.client.GetObjectTagging(ctx, m.bucketName, m.objectName(id), minio.GetObjectTaggingOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%w: %s", domain.ErrObjectNotFound, m.objectName(id))
+		}
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	return t.ToMap(), nil
+}
+
 func (m *SourceClient) SetObjectTags(ctx context.Context, id string, tags map[string]string) error {
 	newtag, err := miniotags.MapToObjectTags(tags)
 	if err != nil {
 		return fmt.Errorf("failed to create tags: %w", err)
 	}
 
-	if err := m.client.PutObjectTagging(ctx, m.bucketName, id, newtag, minio.PutObjectTaggingOptions{}); err != nil {
+	if err := m.client.PutObjectTagging(ctx, m.bucketName, m.objectName(id), newtag, minio.PutObjectTaggingOptions{}); err != nil {
 		return fmt.Errorf("failed to set tags: %w", err)
 	}
 	return nil
 }
 
 func (m *SourceClient) RemoveObjectTags(ctx context.Context, id string) error {
-	if err := m.client.RemoveObjectTagging(ctx, m.bucketName, id, minio.RemoveObjectTaggingOptions{}); err != nil {
+	if err := m.client.RemoveObjectTagging(ctx, m.bucketName, m.objectName(id), minio.RemoveObjectTaggingOptions{}); err != nil {
 		return fmt.Errorf("failed to remove tags: %w", err)
 	}
 	return nil
 }
 
 func (m *SourceClient) GetSourceContent(ctx context.Context, id string) (io.ReadSeekCloser, error) {
-	obj, err := m.client.GetObject(ctx, m.bucketName, id, minio.GetObjectOptions{})
+	obj, err := m.client.GetObject(ctx, m.bucketName, m.objectName(id), minio.GetObjectOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get object: %w", err)
 	}
 	return obj, nil
 }
 
+// SourceURL returns a presigned URL for ffmpeg to read the source without downloading it.
+// It is signed for the internal endpoint, since it is not handed out to end users.
+func (m *SourceClient) SourceURL(ctx context.Context, id string, expiry time.Duration) (*url.URL, error) {
+	u, err := m.client.PresignedGetObject(ctx, m.bucketName, m.objectName(id), expiry, url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign source: %w", err)
+	}
+	return u, nil
+}
+
 func (m *SourceClient) DeleteSourceContent(ctx context.Context, id string) error {
-	if err := m.client.RemoveObject(ctx, m.bucketName, id, minio.RemoveObjectOptions{}); err != nil {
+	if err := m.client.RemoveObject(ctx, m.bucketName, m.objectName(id), minio.RemoveObjectOptions{}); err != nil {
 		return fmt.Errorf("failed to delete object: %w", err)
 	}
 	return nil
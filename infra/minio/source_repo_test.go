@@ -8,10 +8,11 @@ import (
 	"github.com/minio/minio-go/v7"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
 )
 
 var _ = Describe("SourceClient", Ordered, func() {
-	client := NewSourceClient(sourceClientBucketName, minioClient)
+	client := NewSourceClient(sourceClientBucketName, "", minioClient)
 
 	ctx := context.Background()
 
@@ -36,6 +37,13 @@ var _ = Describe("SourceClient", Ordered, func() {
 		err := client.SetObjectTags(ctx, "test1", map[string]string{"tag1": "value1"})
 		Expect(err).NotTo(HaveOccurred())
 
+		tags, err := client.GetObjectTags(ctx, "test1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(Equal(map[string]string{"tag1": "value1"}))
+
+		_, err = client.GetObjectTags(ctx, "missing")
+		Expect(err).To(MatchError(domain.ErrObjectNotFound))
+
 		uploadedFiles = client.ListUploadedFiles(ctx)
 		for file, err := range uploadedFiles {
 			Expect(err).NotTo(HaveOccurred())
@@ -83,6 +91,50 @@ var _ = Describe("SourceClient", Ordered, func() {
 		err = client.DeleteSourceContent(ctx, "test2")
 		Expect(err).NotTo(HaveOccurred())
 	})
+
+	It("Prefix", func() {
+		tenantClient := NewSourceClient(sourceClientBucketName, "team-a/", minioClient)
+		_, err := minioClient.PutObject(ctx, sourceClientBucketName, "team-a/test3", strings.NewReader("source"), 6, minio.PutObjectOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		ids := make([]string, 0)
+		for file, err := range tenantClient.ListUploadedFiles(ctx) {
+			Expect(err).NotTo(HaveOccurred())
+			ids = append(ids, file.ID)
+		}
+		Expect(ids).To(Equal([]string{"test3"}))
+
+		By("Client without prefix does not list the sub directory")
+		for file, err := range client.ListUploadedFiles(ctx) {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.ID).NotTo(HavePrefix("team-a"))
+		}
+
+		Expect(tenantClient.SetObjectTags(ctx, "test3", map[string]string{"tag1": "value1"})).To(Succeed())
+		Expect(tenantClient.DeleteSourceContent(ctx, "test3")).To(Succeed())
+	})
+
+	It("Metadata", func() {
+		traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		_, err := minioClient.PutObject(ctx, sourceClientBucketName, "test4", strings.NewReader("source"), 6, minio.PutObjectOptions{
+			UserMetadata: map[string]string{"Traceparent": traceparent},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		for file, err := range client.ListUploadedFiles(ctx) {
+			Expect(err).NotTo(HaveOccurred())
+			if file.ID == "test4" {
+				Expect(file.Metadata).To(HaveKeyWithValue("traceparent", traceparent))
+			}
+		}
+		Expect(client.DeleteSourceContent(ctx, "test4")).To(Succeed())
+	})
+
+	It("Ping", func() {
+		Expect(client.Ping(ctx)).To(Succeed())
+		Expect(NewSourceClient("not-exist-bucket", "", minioClient).Ping(ctx)).NotTo(Succeed())
+	})
+
 })
 
 func TestSourceRepo(t *testing.T) {
@@ -0,0 +1,122 @@
+package minio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// usage/<date>/<hostname>.json
+const usagePrefix = "usage/"
+
+type UsageClient struct {
+	bucketName string
+	client     *minio.Client
+}
+
+func NewUsageClient(bucketName config.SystemBucketName, client *minio.Client) *UsageClient {
+	return &UsageClient{
+		bucketName: string(bucketName),
+		client:     client,
+	}
+}
+
+func usageObjectPath(date string, hostname string) string {
+	return path.Join(usagePrefix, date, url.PathEscape(hostname)+".json")
+}
+
+// GetUsage returns the records of the host in the day. It returns no records if the host has not recorded anything.
+func (m *UsageClient) GetUsage(ctx context.Context, date string, hostname string) ([]entity.UsageRecord, error) {
+	records, err := m.getUsage(ctx, usageObjectPath(date, hostname))
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return []entity.UsageRecord{}, nil
+		}
+		return nil, err
+	}
+	return records, nil
+}
+
+// PutUsage replaces the records of the host in the day.
+func (m *UsageClient) PutUsage(ctx context.Context, date string, hostname string, records []entity.UsageRecord) error {
+	b, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+
+	if _, err := m.client.PutObject(ctx, m.bucketName, usageObjectPath(date, hostname), bytes.NewReader(b), int64(len(b)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	}); err != nil {
+		return fmt.Errorf("failed to put usage: %w", err)
+	}
+	return nil
+}
+
+// ListUsage lists the records of all hosts from the day `from` to the day `to`. Empty means unbounded.
+func (m *UsageClient) ListUsage(ctx context.Context, from string, to string) iter.Seq2[entity.UsageRecord, error] {
+	opts := minio.ListObjectsOptions{
+		Prefix:    usagePrefix,
+		Recursive: true,
+	}
+	if from != "" {
+		opts.StartAfter = usagePrefix + from
+	}
+	infos := m.client.ListObjects(ctx, m.bucketName, opts)
+
+	return func(yield func(entity.UsageRecord, error) bool) {
+		for info := range infos {
+			if info.Err != nil {
+				if !yield(entity.UsageRecord{}, fmt.Errorf("failed to list usage: %w", info.Err)) {
+					return
+				}
+				continue
+			}
+
+			date, _, ok := strings.Cut(strings.TrimPrefix(info.Key, usagePrefix), "/")
+			if !ok || (from != "" && date < from) {
+				continue
+			}
+			if to != "" && date > to {
+				// キーは日付順に並んでいる
+				return
+			}
+
+			records, err := m.getUsage(ctx, info.Key)
+			if err != nil {
+				if !yield(entity.UsageRecord{}, err) {
+					return
+				}
+				continue
+			}
+			for _, record := range records {
+				if !yield(record, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (m *UsageClient) getUsage(ctx context.Context, objectPath string) ([]entity.UsageRecord, error) {
+	obj, err := m.client.GetObject(ctx, m.bucketName, objectPath, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	defer obj.Close()
+
+	var records []entity.UsageRecord
+	if err := json.NewDecoder(obj).Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to decode usage %s: %w", objectPath, err)
+	}
+	return records, nil
+}
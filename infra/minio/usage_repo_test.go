@@ -0,0 +1,60 @@
+package minio
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+var _ = Describe("UsageClient", Ordered, func() {
+	client := NewUsageClient(systemBucketName, minioClient)
+
+	ctx := context.Background()
+
+	day1 := []entity.UsageRecord{
+		{Date: "2025-01-30", TenantID: "default", MediaID: "media-1", EncodeMinutes: 3, StoredBytes: 100},
+	}
+	day2 := []entity.UsageRecord{
+		{Date: "2025-01-31", TenantID: "default", MediaID: "media-1", ServedBytes: 50},
+		{Date: "2025-01-31", TenantID: "team-a", MediaID: "media-2", EncodeMinutes: 1.5, StoredBytes: 10},
+	}
+	day3 := []entity.UsageRecord{
+		{Date: "2025-02-01", TenantID: "default", MediaID: "media-1", ServedBytes: 70},
+	}
+
+	It("Not Found", func() {
+		records, err := client.GetUsage(ctx, "2025-01-30", "host-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(BeEmpty())
+	})
+
+	It("Normal", func() {
+		Expect(client.PutUsage(ctx, "2025-01-30", "host-1", day1)).To(Succeed())
+		Expect(client.PutUsage(ctx, "2025-01-31", "host-1", day2[:1])).To(Succeed())
+		Expect(client.PutUsage(ctx, "2025-01-31", "host-2", day2[1:])).To(Succeed())
+		Expect(client.PutUsage(ctx, "2025-02-01", "host-1", day3)).To(Succeed())
+
+		records, err := client.GetUsage(ctx, "2025-01-31", "host-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(Equal(day2[:1]))
+
+		list := func(from, to string) []entity.UsageRecord {
+			got := make([]entity.UsageRecord, 0)
+			for record, err := range client.ListUsage(ctx, from, to) {
+				Expect(err).NotTo(HaveOccurred())
+				got = append(got, record)
+			}
+			return got
+		}
+
+		By("All")
+		Expect(list("", "")).To(ConsistOf(append(append(day1, day2...), day3...)))
+
+		By("Range")
+		Expect(list("2025-01-31", "2025-01-31")).To(ConsistOf(day2))
+		Expect(list("2025-01-31", "")).To(ConsistOf(append(day2, day3...)))
+		Expect(list("", "2025-01-30")).To(ConsistOf(day1))
+	})
+})
@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
 	"github.com/walnuts1018/mpeg-dash-encoder/domain/logger"
@@ -46,14 +49,61 @@ func main() {
 		os.Exit(1)
 	}
 
-	slog.Info("Server is running", slog.String("port", cfg.ServerPort))
+	metricsRouter, err := wire.CreateMetricsRouter(ctx, cfg, usecase)
+	if err != nil {
+		slog.Error("Failed to create metrics router", slog.Any("error", err))
+		os.Exit(1)
+	}
 
+	srv := &http.Server{
+		Addr:              ":" + cfg.ServerPort,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	metricsSrv := &http.Server{
+		Addr:              ":" + cfg.MetricsPort,
+		Handler:           metricsRouter,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	usecaseDone := make(chan struct{})
 	go func() {
+		defer close(usecaseDone)
 		usecase.Run(ctx)
 	}()
 
-	if err := router.Run(":" + cfg.ServerPort); err != nil {
+	serverErr := make(chan error, 2)
+	go func() {
+		slog.Info("Server is running", slog.String("port", cfg.ServerPort))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+	go func() {
+		slog.Info("Metrics server is running", slog.String("port", cfg.MetricsPort))
+		if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- fmt.Errorf("metrics server: %w", err)
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
 		slog.Error("Failed to run server", slog.Any("error", err))
 		os.Exit(1)
+	case <-ctx.Done():
+	}
+	slog.Info("Shutting down")
+
+	// 実行中のリクエストが終わるのを待つ。エンコードはusecase.Runの中で待つ
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shutdown server", slog.Any("error", err))
+	}
+	<-usecaseDone
+	// エンコードの終了までメトリクスを取得できるよう最後に止める
+	if err := metricsSrv.Close(); err != nil {
+		slog.Error("Failed to close metrics server", slog.Any("error", err))
 	}
+	slog.Info("Shutdown completed")
 }
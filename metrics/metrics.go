@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "mpeg_dash_encoder"
+
+// JobState is the state of an encode job.
+type JobState string
+
+const (
+	JobStateDownloading JobState = "downloading"
+	JobStateQueued      JobState = "queued"
+	JobStateEncoding    JobState = "encoding"
+	JobStateUploading   JobState = "uploading"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	EncodeQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "encode_queue_depth",
+		Help:      "Number of downloaded source files waiting for the encoder.",
+	})
+
+	Jobs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "jobs",
+		Help:      "Number of encode jobs by state.",
+	}, []string{"tenant", "state"})
+
+	JobsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_finished_total",
+		Help:      "Number of finished encode jobs by result.",
+	}, []string{"tenant", "result"})
+
+	EncodeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "encode_duration_seconds",
+		Help:      "Duration of ffmpeg encodes.",
+		Buckets:   prometheus.ExponentialBuckets(10, 2, 10), // 10s ~ 85m
+	}, []string{"profile", "renditions"})
+
+	FFmpegFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ffmpeg_failures_total",
+		Help:      "Number of failed ffmpeg runs by failure reason.",
+	}, []string{"profile", "reason"})
+
+	StorageTransferBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_transfer_bytes_total",
+		Help:      "Bytes of source downloads and output uploads against the object storage.",
+	}, []string{"operation"})
+
+	StorageTransferDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_transfer_duration_seconds",
+		Help:      "Duration of source downloads and output uploads against the object storage.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 14), // 0.1s ~ 27m
+	}, []string{"operation"})
+
+	MediaRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "media_requests_total",
+		Help:      "Number of requests to the media route by status code.",
+	}, []string{"status"})
+
+	MediaServedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "media_served_bytes_total",
+		Help:      "Bytes of response bodies of the media route.",
+	})
+
+	TokenValidationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_validation_failures_total",
+		Help:      "Number of rejected user tokens by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		EncodeQueueDepth,
+		Jobs,
+		JobsFinished,
+		EncodeDuration,
+		FFmpegFailures,
+		StorageTransferBytes,
+		StorageTransferDuration,
+		MediaRequests,
+		MediaServedBytes,
+		TokenValidationFailures,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// SetJobState moves a job from one state to another. An empty state means the job is not counted.
+func SetJobState(tenantID string, from JobState, to JobState) {
+	if from != "" {
+		Jobs.WithLabelValues(tenantID, string(from)).Dec()
+	}
+	if to != "" {
+		Jobs.WithLabelValues(tenantID, string(to)).Inc()
+	}
+}
@@ -6,9 +6,21 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/router/middleware"
 	"github.com/walnuts1018/mpeg-dash-encoder/usecase"
 )
 
+const userTokenQueryKey = middleware.TokenQueryKey
+
+type userTokenSource int
+
+const (
+	userTokenSourceHeader userTokenSource = iota
+	userTokenSourceQuery
+	userTokenSourceCookie
+)
+
 type Handler struct {
 	config  config.Config
 	usecase *usecase.Usecase
@@ -21,10 +33,21 @@ func NewHandler(config config.Config, usecase *usecase.Usecase) (Handler, error)
 	}, nil
 }
 
-func (h *Handler) getAuthorizedMediaIDs(c *gin.Context) ([]string, error) {
-	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
-	if !ok {
-		return nil, errors.New("authorization header is missing")
+// getUserToken は Authorization ヘッダー、クエリパラメータ、Cookieの順にトークンを探す
+func (h *Handler) getUserToken(c *gin.Context) (string, userTokenSource, error) {
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return token, userTokenSourceHeader, nil
 	}
-	return h.usecase.GetMediaIDsFromToken(token)
+	// ログに残らないよう、クエリのトークンはmiddlewareでURLから取り除いている
+	if token := middleware.QueryToken(c); token != "" {
+		return token, userTokenSourceQuery, nil
+	}
+	if token, err := c.Cookie(h.config.UserSessionConfig.CookieName); err == nil && token != "" {
+		return token, userTokenSourceCookie, nil
+	}
+	return "", 0, errors.New("authorization header is missing")
+}
+
+func (h *Handler) getUserTokenClaims(c *gin.Context, token string) (entity.UserToken, error) {
+	return h.usecase.GetUserToken(c.Request.Context(), token)
 }
@@ -1,9 +1,48 @@
 package handler
 
-import "github.com/gin-gonic/gin"
+import (
+	"log/slog"
+	"net/http"
+	"slices"
 
-func (h *Handler) Health(c *gin.Context) {
+	"github.com/gin-gonic/gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// Liveness only reports that the process is serving. It does not check the dependencies.
+func (h *Handler) Liveness(c *gin.Context) {
 	c.JSON(200, gin.H{
 		"status": "ok",
 	})
 }
+
+// Readiness returns 503 if any of the dependency checks fails.
+// The endpoint is public, so the details of the checks are only logged. They are served by ReadinessDetails.
+func (h *Handler) Readiness(c *gin.Context) {
+	ready, checks := h.usecase.Readiness(c.Request.Context())
+	if !ready {
+		failed := slices.DeleteFunc(checks, func(check entity.HealthCheck) bool {
+			return check.Status == entity.HealthStatusOK
+		})
+		slog.Warn("not ready", slog.Any("checks", failed))
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// ReadinessDetails returns the status of every dependency check, and 503 if any of them fails.
+// It is served only on the metrics port, since the messages may contain the bucket names and the paths.
+func (h *Handler) ReadinessDetails(c *gin.Context) {
+	ready, checks := h.usecase.Readiness(c.Request.Context())
+	status := http.StatusOK
+	overall := entity.HealthStatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+		overall = entity.HealthStatusFail
+	}
+	c.JSON(status, gin.H{
+		"status": overall,
+		"checks": checks,
+	})
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/usecase"
+)
+
+type pingSourceRepository struct {
+	usecase.SourceRepository
+	err error
+}
+
+func (r pingSourceRepository) Ping(context.Context) error {
+	return r.err
+}
+
+type pingEncodedObjectRepository struct {
+	usecase.EncodedObjectRepository
+}
+
+func (pingEncodedObjectRepository) Ping(context.Context) error {
+	return nil
+}
+
+type pingRevocationRepository struct {
+	usecase.RevocationRepository
+}
+
+func (pingRevocationRepository) Ping(context.Context) error {
+	return nil
+}
+
+type checkEncoder struct {
+	usecase.Encoder
+}
+
+func (checkEncoder) Check(context.Context) []entity.HealthCheck {
+	return []entity.HealthCheck{entity.NewHealthCheck("ffmpeg", nil, "7.1")}
+}
+
+func newHealthHandler(t *testing.T, sourceErr error) Handler {
+	t.Helper()
+	//nolint:exhaustruct
+	cfg := config.Config{
+		ReadinessConfig: config.ReadinessConfig{Timeout: time.Second},
+	}
+	u, err := usecase.NewUsecase(cfg, []usecase.Tenant{{
+		Tenant:      entity.Tenant{ID: entity.DefaultTenantID},
+		SourceRepo:  pingSourceRepository{err: sourceErr},
+		EncodedRepo: pingEncodedObjectRepository{},
+	}}, nil, nil, checkEncoder{}, pingRevocationRepository{}, nil, nil, nil)
+	require.NoError(t, err)
+	h, err := NewHandler(cfg, u)
+	require.NoError(t, err)
+	return h
+}
+
+func TestHandler_Readiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		sourceErr  error
+		wantStatus int
+	}{
+		{name: "ready", sourceErr: nil, wantStatus: http.StatusOK},
+		{name: "not ready", sourceErr: errors.New("bucket not found"), wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHealthHandler(t, tt.sourceErr)
+			r := gin.New()
+			r.GET("/readyz", h.Readiness)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.wantStatus, w.Code)
+			// 公開されているので、チェックの詳細は返さない
+			assert.Empty(t, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_ReadinessDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		sourceErr  error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "ready",
+			sourceErr:  nil,
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "not ready",
+			sourceErr:  errors.New("bucket not found"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "fail",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHealthHandler(t, tt.sourceErr)
+			r := gin.New()
+			r.GET("/readyz", h.ReadinessDetails)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			require.Equal(t, tt.wantStatus, w.Code)
+
+			var body struct {
+				Status string `json:"status"`
+				Checks []struct {
+					Name    string `json:"name"`
+					Status  string `json:"status"`
+					Message string `json:"message"`
+				} `json:"checks"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantBody, body.Status)
+
+			checks := make(map[string]string, len(body.Checks))
+			for _, check := range body.Checks {
+				checks[check.Name] = check.Status
+				if check.Name == "source_bucket:default" && tt.sourceErr != nil {
+					assert.Equal(t, tt.sourceErr.Error(), check.Message)
+				}
+			}
+			assert.Equal(t, tt.wantBody, checks["source_bucket:default"])
+			assert.Equal(t, "ok", checks["output_bucket:default"])
+			assert.Equal(t, "ok", checks["system_bucket"])
+			assert.Equal(t, "ok", checks["ffmpeg"])
+			assert.Contains(t, checks, "temp_dir")
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// VerifyMedia re-checks the published files of a media against its integrity manifest.
+// Query: media_id, tenant. Only admins of the default tenant can verify the media of other tenants.
+func (h *Handler) VerifyMedia(c *gin.Context) {
+	mediaID := c.Query("media_id")
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID, ok := resolveTenant(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.usecase.VerifyMedia(c.Request.Context(), tenantID, mediaID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrObjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "integrity manifest not found"})
+		case errors.Is(err, domain.ErrUnknownTenant):
+			c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify media"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
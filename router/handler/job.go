@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// GetJob returns the state of the encode of a source, including the reason of the failure.
+// Query: tenant. Only admins of the default tenant can read the jobs of other tenants.
+func (h *Handler) GetJob(c *gin.Context) {
+	mediaID := c.Param("media_id")
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID, ok := resolveTenant(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.usecase.GetEncodeJob(c.Request.Context(), tenantID, mediaID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrObjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "source not found"})
+		case errors.Is(err, domain.ErrUnknownTenant):
+			c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// ClearJobFailure clears the permanent failure of a source, so that it is encoded again.
+// Query: tenant. Only admins of the default tenant can manage the jobs of other tenants.
+func (h *Handler) ClearJobFailure(c *gin.Context) {
+	mediaID := c.Param("media_id")
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID, ok := resolveTenant(c)
+	if !ok {
+		return
+	}
+
+	if err := h.usecase.ClearEncodeFailure(c.Request.Context(), tenantID, mediaID); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrObjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "source not found"})
+		case errors.Is(err, domain.ErrUnknownTenant):
+			c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		case errors.Is(err, domain.ErrEncodeNotFailed):
+			c.JSON(http.StatusConflict, gin.H{"error": "job has not failed"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear job failure"})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
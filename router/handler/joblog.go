@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// GetJobLog returns the ffmpeg log of a job.
+// Query: tenant, follow. If follow is true, the log is streamed until the job finishes.
+// The log of a running job is only on the host which runs it, so it responds 409 with the hostname on the other hosts.
+// Only admins of the default tenant can read the logs of other tenants.
+func (h *Handler) GetJobLog(c *gin.Context) {
+	mediaID := c.Param("media_id")
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	follow := false
+	if v := c.Query("follow"); v != "" {
+		var err error
+		follow, err = strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "follow must be a boolean"})
+			return
+		}
+	}
+
+	tenantID, ok := resolveTenant(c)
+	if !ok {
+		return
+	}
+
+	jobLog, err := h.usecase.GetJobLog(c.Request.Context(), tenantID, mediaID, follow)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrObjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "job log not found"})
+		case errors.Is(err, domain.ErrUnknownTenant):
+			c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		case errors.Is(err, domain.ErrJobRunningOnOtherHost):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get job log"})
+		}
+		return
+	}
+	defer jobLog.Close()
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	buf := make([]byte, 32*1024)
+	c.Stream(func(w io.Writer) bool {
+		n, err := jobLog.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return false
+			}
+		}
+		// ヘッダーを送った後なので、エラーはレスポンスにできない
+		return err == nil
+	})
+}
@@ -2,9 +2,13 @@ package handler
 
 import (
 	"net/http"
-	"slices"
+	"net/url"
+	"path"
 
 	"github.com/gin-gonic/gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/mpd"
 )
 
 func (h *Handler) GetMediaFile(c *gin.Context) {
@@ -13,25 +17,78 @@ func (h *Handler) GetMediaFile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "media_id is required"})
 		return
 	}
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid media_id"})
+		return
+	}
 
 	filename := c.Param("filename")
 	if filename == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
 		return
 	}
+	if err := entity.ValidateMediaFileName(filename); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
+		return
+	}
 
-	authorizedMediaIDs, err := h.getAuthorizedMediaIDs(c)
+	token, tokenSource, err := h.getUserToken(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	if !slices.Contains(authorizedMediaIDs, mediaID) {
+	userToken, err := h.getUserTokenClaims(c, token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.usecase.CanAccessMedia(userToken, mediaID) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "you are not authorized to access this media"})
 		return
 	}
 
-	file, err := h.usecase.GetMediaFile(c.Request.Context(), mediaID, filename)
+	ok, err := h.usecase.CanAccessMediaFile(c.Request.Context(), userToken, mediaID, filename)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get media file"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you are not entitled to this rendition"})
+		return
+	}
+
+	// クエリでトークンを受け取った場合、各セグメントのURLにもトークンを引き継ぐ
+	// Renditionの制限がある場合は、許可されていないRepresentationをマニフェストから取り除く
+	if path.Ext(filename) == mpd.Ext && (tokenSource == userTokenSourceQuery || !userToken.Renditions.IsZero()) {
+		query := url.Values{}
+		if tokenSource == userTokenSourceQuery {
+			query.Set(userTokenQueryKey, token)
+		}
+		manifest, err := h.usecase.GetMediaManifest(c.Request.Context(), userToken.TenantID, mediaID, filename, query, userToken.Renditions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get media file"})
+			return
+		}
+		c.Header("Cache-Control", "private, no-store")
+		c.Data(http.StatusOK, "application/dash+xml", manifest)
+		return
+	}
+
+	if h.config.MediaDeliveryConfig.ModeFor(filename) == config.MediaDeliveryModeRedirect {
+		u, err := h.usecase.GetMediaFileURL(c.Request.Context(), userToken.TenantID, mediaID, filename)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get media file url"})
+			return
+		}
+		// presigned URLは期限付きなのでリダイレクト自体はキャッシュさせない
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, u.String())
+		return
+	}
+
+	file, err := h.usecase.GetMediaFile(c.Request.Context(), userToken.TenantID, mediaID, filename)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get media file"})
 		return
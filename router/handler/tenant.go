@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/router/middleware"
+)
+
+// resolveTenant returns the tenant of the admin, or the tenant in the query.
+// Only admins of the default tenant can access other tenants. Otherwise it responds 403 and returns false.
+func resolveTenant(c *gin.Context) (string, bool) {
+	principal, _ := middleware.AdminPrincipal(c)
+	tenantID := principal.TenantID
+	if t := c.Query("tenant"); t != "" && t != tenantID {
+		if principal.TenantID != entity.DefaultTenantID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you are not authorized to access this tenant"})
+			return "", false
+		}
+		tenantID = t
+	}
+	return tenantID, true
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/router/middleware"
+)
+
+func TestResolveTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		principal string
+		query     string
+		want      string
+		wantOK    bool
+	}{
+		{name: "own tenant", principal: "team-a", query: "", want: "team-a", wantOK: true},
+		{name: "own tenant in query", principal: "team-a", query: "team-a", want: "team-a", wantOK: true},
+		{name: "other tenant", principal: "team-a", query: "team-b", want: "", wantOK: false},
+		{name: "default tenant admin", principal: entity.DefaultTenantID, query: "", want: entity.DefaultTenantID, wantOK: true},
+		{name: "default tenant admin accesses other tenant", principal: entity.DefaultTenantID, query: "team-b", want: "team-b", wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/v1/admin/jobs/movie-1?tenant="+tt.query, nil)
+			middleware.SetAdminPrincipal(c, entity.AdminPrincipal{Name: "key", TenantID: tt.principal})
+
+			got, ok := resolveTenant(c)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOK, ok)
+			if !tt.wantOK {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
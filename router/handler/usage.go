@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/usecase"
+)
+
+var usageCSVHeader = []string{"date", "tenant_id", "media_id", "encode_minutes", "stored_bytes", "served_bytes"}
+
+// GetUsageReport returns the daily usage.
+// Query: from, to (e.g. "2025-01-31"), group_by (media or tenant), format (json or csv), tenant.
+// Only admins of the default tenant can see the usage of other tenants.
+func (h *Handler) GetUsageReport(c *gin.Context) {
+	from := c.Query("from")
+	to := c.Query("to")
+	for _, date := range []string{from, to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(entity.UsageDateLayout, date); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be YYYY-MM-DD"})
+			return
+		}
+	}
+
+	groupBy := usecase.UsageGroupBy(c.DefaultQuery("group_by", string(usecase.UsageGroupByMedia)))
+	if groupBy != usecase.UsageGroupByMedia && groupBy != usecase.UsageGroupByTenant {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be media or tenant"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	tenantID, ok := resolveTenant(c)
+	if !ok {
+		return
+	}
+	// defaultテナントの管理者がテナントを指定しない場合は全テナントを集計する
+	if tenantID == entity.DefaultTenantID && c.Query("tenant") == "" {
+		tenantID = ""
+	}
+
+	records, err := h.usecase.GetUsageReport(c.Request.Context(), tenantID, from, to, groupBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get usage"})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"usage": records})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="usage.csv"`)
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(usageCSVHeader)
+	for _, r := range records {
+		_ = w.Write([]string{
+			r.Date,
+			r.TenantID,
+			r.MediaID,
+			strconv.FormatFloat(r.EncodeMinutes, 'f', -1, 64),
+			strconv.FormatInt(r.StoredBytes, 10),
+			strconv.FormatInt(r.ServedBytes, 10),
+		})
+	}
+	w.Flush()
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const userSessionCookiePath = "/v1/user"
+
+// CreateUserSession はヘッダーを付与できないプレイヤー向けに、トークンをHttpOnly Cookieとして設定する
+func (h *Handler) CreateUserSession(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+			return
+		}
+		token = req.Token
+	}
+
+	userToken, err := h.getUserTokenClaims(c, token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.setUserSessionCookie(c, token, int(time.Until(userToken.ExpiresAt).Seconds()))
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+func (h *Handler) DeleteUserSession(c *gin.Context) {
+	h.setUserSessionCookie(c, "", -1)
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+func (h *Handler) setUserSessionCookie(c *gin.Context, token string, maxAge int) {
+	cfg := h.config.UserSessionConfig
+	c.SetSameSite(cfg.SameSite())
+	c.SetCookie(cfg.CookieName, token, maxAge, userSessionCookiePath, cfg.CookieDomain, cfg.CookieSecure, true)
+}
@@ -1,12 +1,23 @@
 package handler
 
 import (
+	"errors"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/router/middleware"
 )
 
 func (h *Handler) CreateUserToken(c *gin.Context) {
 	var req struct {
+		Subject  string   `json:"subject"`
 		MediaIDs []string `json:"media_ids"`
+		TTL      string   `json:"ttl"` // e.g. "1h30m"
+		Audience []string `json:"audience"`
+		// e.g. {"max_height": 720, "max_bandwidth": 5000000, "audio_only": false}
+		Renditions entity.RenditionConstraints `json:"renditions"`
 	}
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(400, gin.H{
@@ -15,8 +26,31 @@ func (h *Handler) CreateUserToken(c *gin.Context) {
 		return
 	}
 
-	token, err := h.usecase.CreateUserToken(req.MediaIDs)
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": "invalid ttl",
+			})
+			return
+		}
+	}
+
+	// トークンは管理キーのテナントで発行する
+	principal, _ := middleware.AdminPrincipal(c)
+	token, userToken, err := h.usecase.CreateUserToken(principal.TenantID, req.Subject, req.MediaIDs, ttl, req.Audience, req.Renditions)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidTokenTTL) ||
+			errors.Is(err, domain.ErrInvalidTokenAudience) ||
+			errors.Is(err, domain.ErrInvalidRenditionConstraints) ||
+			errors.Is(err, entity.ErrInvalidMediaGrant) {
+			c.JSON(400, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
 		c.JSON(500, gin.H{
 			"error": "failed to create token",
 		})
@@ -24,6 +58,41 @@ func (h *Handler) CreateUserToken(c *gin.Context) {
 	}
 
 	c.JSON(200, gin.H{
-		"token": token,
+		"token":      token,
+		"token_id":   userToken.ID,
+		"tenant_id":  userToken.TenantID,
+		"expires_at": userToken.ExpiresAt,
+	})
+}
+
+func (h *Handler) RevokeUserToken(c *gin.Context) {
+	var req struct {
+		TokenID string `json:"token_id"`
+		Subject string `json:"subject"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "invalid request",
+		})
+		return
+	}
+
+	if req.TokenID == "" && req.Subject == "" {
+		c.JSON(400, gin.H{
+			"error": "token_id or subject is required",
+		})
+		return
+	}
+
+	principal, _ := middleware.AdminPrincipal(c)
+	if err := h.usecase.RevokeUserToken(c.Request.Context(), principal.TenantID, req.TokenID, req.Subject); err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to revoke token",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status": "ok",
 	})
 }
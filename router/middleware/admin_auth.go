@@ -1,22 +1,64 @@
 package middleware
 
 import (
+	"log/slog"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	sloggin "github.com/samber/slog-gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const adminPrincipalKey = "admin_principal"
+
 func (m *Middleware) AdminAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && got == m.adminToken {
-			c.Next()
-			return
-		} else {
-			c.JSON(401, gin.H{
+		key, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		principal, err := m.usecase.AuthenticateAdmin(c.Request.Context(), key)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "authorization failed",
 			})
 			c.Abort()
 			return
 		}
+
+		SetAdminPrincipal(c, principal)
+		sloggin.AddCustomAttributes(c, slog.String("admin_key", principal.Name))
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("admin.key_name", principal.Name))
+		c.Next()
+	}
+}
+
+// RequireAdminScope must be used after AdminAuth.
+func (m *Middleware) RequireAdminScope(scope entity.AdminScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := AdminPrincipal(c)
+		if !ok || !principal.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "insufficient scope",
+				"scope": scope,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// SetAdminPrincipal records the authenticated caller of the admin API.
+func SetAdminPrincipal(c *gin.Context, principal entity.AdminPrincipal) {
+	c.Set(adminPrincipalKey, principal)
+}
+
+func AdminPrincipal(c *gin.Context) (entity.AdminPrincipal, bool) {
+	v, ok := c.Get(adminPrincipalKey)
+	if !ok {
+		return entity.AdminPrincipal{}, false
 	}
+	principal, ok := v.(entity.AdminPrincipal)
+	return principal, ok
 }
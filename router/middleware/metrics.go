@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walnuts1018/mpeg-dash-encoder/metrics"
+)
+
+// MediaMetrics counts the requests and the response bytes of the media route.
+func (m *Middleware) MediaMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		metrics.MediaRequests.WithLabelValues(strconv.Itoa(c.Writer.Status())).Inc()
+		if size := c.Writer.Size(); size > 0 {
+			metrics.MediaServedBytes.Add(float64(size))
+		}
+	}
+}
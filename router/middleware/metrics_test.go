@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/metrics"
+)
+
+func TestMediaMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/v1/user/:media_id/:filename", (&Middleware{}).MediaMetrics(), func(c *gin.Context) {
+		c.String(http.StatusOK, "segment")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/user/movie-1/dash.mpd", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	metricsServer := httptest.NewServer(metrics.Handler())
+	defer metricsServer.Close()
+	resp, err := http.Get(metricsServer.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	lines := strings.Split(string(b), "\n")
+	assert.Contains(t, lines, `mpeg_dash_encoder_media_requests_total{status="200"} 1`)
+	assert.Contains(t, lines, `mpeg_dash_encoder_media_served_bytes_total 7`)
+}
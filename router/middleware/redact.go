@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// TokenQueryKey is the query parameter which carries a user token.
+const TokenQueryKey = "token"
+
+const (
+	queryTokenKey = "query_token"
+	redacted      = "REDACTED"
+)
+
+// RedactQueryToken removes the user token from the request URL so that it is not logged or traced.
+// It must be used before the logger and the tracer. The token can be read by QueryToken.
+func (m *Middleware) RedactQueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if token := query.Get(TokenQueryKey); token != "" {
+			c.Set(queryTokenKey, token)
+			query.Set(TokenQueryKey, redacted)
+			c.Request.URL.RawQuery = query.Encode()
+			c.Request.RequestURI = c.Request.URL.RequestURI()
+		}
+		c.Next()
+	}
+}
+
+// QueryToken returns the user token removed from the URL by RedactQueryToken.
+func QueryToken(c *gin.Context) string {
+	return c.GetString(queryTokenKey)
+}
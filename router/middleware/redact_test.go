@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactQueryToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		target         string
+		wantToken      string
+		wantRequestURI string
+		wantRawQuery   string
+	}{
+		{
+			name:           "redact token",
+			target:         "/v1/user/movie-1/dash.mpd?token=secret-token&foo=bar",
+			wantToken:      "secret-token",
+			wantRequestURI: "/v1/user/movie-1/dash.mpd?foo=bar&token=REDACTED",
+			wantRawQuery:   "foo=bar&token=REDACTED",
+		},
+		{
+			name:           "without token",
+			target:         "/v1/user/movie-1/dash.mpd?foo=bar",
+			wantToken:      "",
+			wantRequestURI: "/v1/user/movie-1/dash.mpd?foo=bar",
+			wantRawQuery:   "foo=bar",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				gotToken      string
+				gotRequestURI string
+				gotRawQuery   string
+				gotFoo        string
+			)
+			r := gin.New()
+			r.GET("/v1/user/:media_id/:filename", (&Middleware{}).RedactQueryToken(), func(c *gin.Context) {
+				gotToken = QueryToken(c)
+				gotRequestURI = c.Request.RequestURI
+				gotRawQuery = c.Request.URL.RawQuery
+				gotFoo = c.Query("foo")
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			assert.Equal(t, tt.wantToken, gotToken)
+			assert.Equal(t, tt.wantRequestURI, gotRequestURI)
+			assert.Equal(t, tt.wantRawQuery, gotRawQuery)
+			assert.NotContains(t, gotRequestURI, "secret-token")
+			assert.Equal(t, "bar", gotFoo)
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	sloggin "github.com/samber/slog-gin"
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
 	"github.com/walnuts1018/mpeg-dash-encoder/consts"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/metrics"
 	"github.com/walnuts1018/mpeg-dash-encoder/router/handler"
 	"github.com/walnuts1018/mpeg-dash-encoder/router/middleware"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
@@ -19,6 +21,7 @@ func NewRouter(config config.Config, handler handler.Handler, m *middleware.Midd
 
 	r := gin.New()
 	r.Use(gin.Recovery())
+	r.Use(m.RedactQueryToken())
 	r.Use(sloggin.NewWithConfig(slog.Default(), sloggin.Config{
 		DefaultLevel:     config.LogLevel,
 		ClientErrorLevel: slog.LevelWarn,
@@ -35,23 +38,50 @@ func NewRouter(config config.Config, handler handler.Handler, m *middleware.Midd
 
 		Filters: []sloggin.Filter{
 			sloggin.IgnorePath("/healthz"),
+			sloggin.IgnorePath("/livez"),
+			sloggin.IgnorePath("/readyz"),
 		},
 	}))
 	r.Use(otelgin.Middleware(consts.ApplicationName))
 
-	r.GET("/healthz", handler.Health)
+	r.GET("/healthz", handler.Liveness)
+	r.GET("/livez", handler.Liveness)
+	r.GET("/readyz", handler.Readiness)
+	r.GET("/.well-known/jwks.json", handler.JWKS)
 	v1 := r.Group("/v1")
 
 	admin := v1.Group("/admin")
 	admin.Use(m.AdminAuth())
 	{
-		admin.POST("/create_user_token", handler.CreateUserToken)
+		admin.POST("/create_user_token", m.RequireAdminScope(entity.AdminScopeIssueTokens), handler.CreateUserToken)
+		admin.POST("/revoke_user_token", m.RequireAdminScope(entity.AdminScopeIssueTokens), handler.RevokeUserToken)
+		admin.GET("/usage", m.RequireAdminScope(entity.AdminScopeReadOnly), handler.GetUsageReport)
+		admin.GET("/media/verify", m.RequireAdminScope(entity.AdminScopeReadOnly), handler.VerifyMedia)
+		admin.GET("/jobs/:media_id", m.RequireAdminScope(entity.AdminScopeReadOnly), handler.GetJob)
+		admin.DELETE("/jobs/:media_id/failure", m.RequireAdminScope(entity.AdminScopeManageJobs), handler.ClearJobFailure)
+		admin.GET("/jobs/:media_id/log", m.RequireAdminScope(entity.AdminScopeReadOnly), handler.GetJobLog)
 	}
 
 	user := v1.Group("/user")
 	{
-		user.GET("/:media_id/:filename", handler.GetMediaFile)
+		user.POST("/session", handler.CreateUserSession)
+		user.DELETE("/session", handler.DeleteUserSession)
+		user.GET("/:media_id/:filename", m.MediaMetrics(), handler.GetMediaFile)
 	}
 
 	return r, nil
 }
+
+// NewMetricsRouter serves the metrics and the details of the readiness checks on the metrics port, which is not exposed publicly.
+func NewMetricsRouter(config config.Config, handler handler.Handler) (*gin.Engine, error) {
+	if config.LogLevel != slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/readyz", handler.ReadinessDetails)
+	// 既存のスクレイプ設定を壊さないよう、他のパスはすべてメトリクスを返す
+	r.NoRoute(gin.WrapH(metrics.Handler()))
+	return r, nil
+}
@@ -6,3 +6,8 @@ resource "aws_s3_bucket" "mpeg-dash-encoder-output" {
   bucket = format("mpeg-dash-encoder-output%s", var.bucket_name_suffix)
 }
 
+
+# トークンの失効、使用量、ffmpegのログを保存する
+resource "aws_s3_bucket" "mpeg-dash-encoder-system" {
+  bucket = format("mpeg-dash-encoder-system%s", var.bucket_name_suffix)
+}
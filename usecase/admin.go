@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// AuthenticateAdmin authenticates an API key, or an OIDC token of a human admin.
+// The tenant is resolved from the key store which has the key, or from the groups of the OIDC admin.
+func (u *Usecase) AuthenticateAdmin(ctx context.Context, credential string) (entity.AdminPrincipal, error) {
+	for _, id := range u.tenantIDs {
+		if principal, ok := u.tenants[id].AdminKeyStore.Authenticate(credential); ok {
+			principal.TenantID = id
+			return principal, nil
+		}
+	}
+
+	// JWTの形をしていない場合はIdPに問い合わせない
+	if strings.Count(credential, ".") != 2 {
+		return entity.AdminPrincipal{}, domain.ErrInvalidAdminKey
+	}
+	principal, err := u.adminTokenVerifier.VerifyAdminToken(ctx, credential)
+	if err != nil {
+		return entity.AdminPrincipal{}, errors.Join(err, domain.ErrInvalidAdminKey)
+	}
+	if _, err := u.tenant(principal.TenantID); err != nil {
+		return entity.AdminPrincipal{}, errors.Join(err, domain.ErrInvalidAdminKey)
+	}
+	return principal, nil
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+type emptyAdminKeyStore struct{}
+
+func (emptyAdminKeyStore) Authenticate(string) (entity.AdminPrincipal, bool) {
+	return entity.AdminPrincipal{}, false
+}
+
+type fixedAdminTokenVerifier struct {
+	principal entity.AdminPrincipal
+}
+
+func (v fixedAdminTokenVerifier) VerifyAdminToken(context.Context, string) (entity.AdminPrincipal, error) {
+	return v.principal, nil
+}
+
+func TestUsecase_AuthenticateAdmin_OIDCTenant(t *testing.T) {
+	tests := []struct {
+		name     string
+		tenantID string
+		wantErr  bool
+	}{
+		{name: "default tenant", tenantID: entity.DefaultTenantID, wantErr: false},
+		{name: "mapped tenant", tenantID: "team-a", wantErr: false},
+		{name: "unknown tenant", tenantID: "team-b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &Usecase{
+				tenants: map[string]Tenant{
+					entity.DefaultTenantID: {Tenant: entity.Tenant{ID: entity.DefaultTenantID}, AdminKeyStore: emptyAdminKeyStore{}},
+					"team-a":               {Tenant: entity.Tenant{ID: "team-a"}, AdminKeyStore: emptyAdminKeyStore{}},
+				},
+				tenantIDs: []string{entity.DefaultTenantID, "team-a"},
+				adminTokenVerifier: fixedAdminTokenVerifier{principal: entity.AdminPrincipal{
+					Name:     "oidc:alice@example.com",
+					TenantID: tt.tenantID,
+					Scopes:   []entity.AdminScope{entity.AdminScopeReadOnly},
+				}},
+			}
+
+			principal, err := u.AuthenticateAdmin(context.Background(), "header.payload.signature")
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrInvalidAdminKey)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.tenantID, principal.TenantID)
+		})
+	}
+}
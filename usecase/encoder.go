@@ -2,35 +2,81 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Code-Hex/synchro"
 	"github.com/Code-Hex/synchro/tz"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/metrics"
+	"github.com/walnuts1018/mpeg-dash-encoder/tracer"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/fileutil"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/mpd"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type encodeRequest struct {
-	mediaID          string
+	tenantID string
+	mediaID  string
+	// ffmpegの入力。ダウンロードした場合はローカルのパス、streamの場合はpresigned URL
 	uploadedFilePath string
+	downloaded       bool
+	// エンコード済みの出力を再利用できるか確認するためのソースのバージョン
+	sourceVersion string
+	// URLを確認したときのffprobeの結果。確認できなかった場合はnil
+	mediaInfo *entity.MediaInfo
+	// ジョブのトレースのルート。アップロードの完了時に終了する
+	span trace.Span
 }
 
+// Run encodes the uploaded files until ctx is canceled, and then drains the running jobs (see shutdown).
 func (u *Usecase) Run(ctx context.Context) {
-	go func(ctx context.Context) {
+	var background sync.WaitGroup
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		u.runRevocationRefresher(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		u.runUsageRecorder(ctx)
+	}()
+	defer background.Wait()
+
+	// シャットダウン中も実行中のジョブを続けるため、ジョブはctxのキャンセルを引き継がない
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
 		for {
 			select {
 			case req := <-u.encodeQueue:
-				if err := u.encode(ctx, req); err != nil {
+				metrics.EncodeQueueDepth.Dec()
+				u.jobs.Add(1)
+				if err := u.encode(jobCtx, req); err != nil {
 					slog.Error("failed to encode", slog.Any("error", err))
+					u.finishJob(req.tenantID, req.mediaID)
 					// returnしない
 				}
+				u.jobs.Done()
 			case <-ctx.Done():
 				return
 			}
 		}
-	}(ctx)
+	}()
 
 	tickerFunc := func() {
 		slog.Debug("start to download uploaded files")
@@ -44,53 +90,182 @@ func (u *Usecase) Run(ctx context.Context) {
 			slog.Debug("no uploaded files")
 			return
 		}
-		slog.Debug("downloaded uploaded files", slog.Any("mediaID", req.mediaID), slog.Any("uploadedFilePath", req.uploadedFilePath))
+		slog.Debug("downloaded uploaded files", slog.String("tenantID", req.tenantID), slog.Any("mediaID", req.mediaID), slog.String("uploadedFilePath", entity.RedactInput(req.uploadedFilePath)))
 
-		u.encodeQueue <- *req
+		metrics.EncodeQueueDepth.Inc()
+		select {
+		case u.encodeQueue <- *req:
+		case <-ctx.Done():
+			// claimはshutdownで解放する
+			metrics.EncodeQueueDepth.Dec()
+			metrics.SetJobState(req.tenantID, metrics.JobStateQueued, "")
+			endSpan(req.span, ctx.Err())
+			if err := req.removeSource(); err != nil {
+				slog.Error("failed to remove uploaded file", slog.Any("error", err))
+			}
+			u.active.remove(req.tenantID, req.mediaID)
+		}
+	}
+	// クラッシュする前にこのホストがclaimしていたファイルを解放し、エンコード済みの出力があればアップロードから再開する
+	for _, id := range u.tenantIDs {
+		u.releaseTenantUploadedFiles(ctx, u.tenants[id])
 	}
+	// クラッシュしたジョブの一時ファイルを消す
+	u.removeOrphans()
 	tickerFunc()
 
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
+	janitorTicker := time.NewTicker(u.janitorConfig.Interval)
+	defer janitorTicker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			tickerFunc()
+		case <-janitorTicker.C:
+			u.removeOrphans()
 		case <-ctx.Done():
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-			if err := u.shutdown(ctx); err != nil {
-				slog.Error("failed to shutdown", slog.Any("error", err))
-			}
+			u.shutdown(workerDone, cancelJobs)
 			return
 		}
 	}
 }
 
 func (u *Usecase) encode(ctx context.Context, req encodeRequest) error {
-	slog.Debug("start to encode", slog.Any("mediaID", req.mediaID), slog.Any("uploadedFilePath", req.uploadedFilePath))
-	encodedDir, err := u.encoder.Encode(req.mediaID, req.uploadedFilePath, false)
+	slog.Debug("start to encode", slog.String("tenantID", req.tenantID), slog.Any("mediaID", req.mediaID), slog.String("uploadedFilePath", entity.RedactInput(req.uploadedFilePath)))
+	ctx = trace.ContextWithSpan(ctx, req.span)
+
+	tenant, err := u.tenant(req.tenantID)
+	if err != nil {
+		metrics.SetJobState(req.tenantID, metrics.JobStateQueued, "")
+		metrics.JobsFinished.WithLabelValues(req.tenantID, "failed").Inc()
+		endSpan(req.span, err)
+		return err
+	}
+	metrics.SetJobState(tenant.ID, metrics.JobStateQueued, metrics.JobStateEncoding)
+
+	var info entity.MediaInfo
+	if req.mediaInfo != nil {
+		info = *req.mediaInfo
+	} else {
+		probeCtx, probeSpan := tracer.Tracer.Start(ctx, "ffprobe")
+		info, err = u.encoder.Probe(probeCtx, req.uploadedFilePath)
+		probeSpan.SetAttributes(attribute.Float64("media.duration_seconds", info.Duration.Seconds()))
+		endSpan(probeSpan, err)
+	}
+	if err == nil {
+		err = u.sourceConstraints.Validate(info)
+	}
 	if err != nil {
+		// 制約を満たさないソースはリトライしても成功しない
+		if reason := entity.EncodeFailureReasonOf(err); reason.Permanent() {
+			metrics.SetJobState(tenant.ID, metrics.JobStateEncoding, "")
+			metrics.JobsFinished.WithLabelValues(tenant.ID, "rejected").Inc()
+			endSpan(req.span, err)
+			u.recordEncodeFailure(ctx, tenant, req.mediaID, reason, err.Error())
+			if err := req.removeSource(); err != nil {
+				slog.Error("failed to remove uploaded file", slog.Any("error", err))
+				// returnしない
+			}
+			return fmt.Errorf("invalid source: %w", err)
+		}
+		slog.Warn("failed to probe uploaded file, encode minutes are not recorded", slog.Any("error", err))
+		// returnしない
+	}
+
+	profile := profileName(tenant.Profile)
+	encodeCtx, encodeSpan := tracer.Tracer.Start(ctx, "ffmpeg", trace.WithAttributes(attribute.String("encode.profile", profile)))
+	start := time.Now()
+	encodedDir, err := u.encoder.Encode(encodeCtx, entity.EncodeSource{
+		TenantID: tenant.ID,
+		MediaID:  req.mediaID,
+		Version:  req.sourceVersion,
+		Input:    req.uploadedFilePath,
+	}, tenant.Profile)
+	if err != nil {
+		reason := entity.EncodeFailureReasonOf(err)
+		metrics.FFmpegFailures.WithLabelValues(profile, string(reason)).Inc()
+		metrics.SetJobState(tenant.ID, metrics.JobStateEncoding, "")
+		metrics.JobsFinished.WithLabelValues(tenant.ID, "failed").Inc()
+		encodeSpan.SetAttributes(attribute.String("encode.failure_reason", string(reason)))
+		endSpan(encodeSpan, err)
+		endSpan(req.span, err)
+		// シャットダウンで止めた場合はshutdownでclaimを解放する
+		if ctx.Err() == nil {
+			u.recordEncodeFailure(ctx, tenant, req.mediaID, reason, err.Error())
+		}
+		if err := req.removeSource(); err != nil {
+			slog.Error("failed to remove uploaded file", slog.Any("error", err))
+			// returnしない
+		}
 		return fmt.Errorf("failed to encode: %w", err)
 	}
+	metrics.SetJobState(tenant.ID, metrics.JobStateEncoding, metrics.JobStateUploading)
 
+	renditions, err := countRenditions(encodedDir)
+	if err != nil {
+		slog.Warn("failed to count renditions", slog.Any("error", err))
+		// returnしない
+	}
+	encodeSpan.SetAttributes(attribute.Int("encode.renditions", renditions))
+	encodeSpan.End()
+	metrics.EncodeDuration.WithLabelValues(profile, strconv.Itoa(renditions)).Observe(time.Since(start).Seconds())
+	u.recordUsage(tenant.ID, req.mediaID, entity.UsageRecord{
+		EncodeMinutes: info.Duration.Minutes() * float64(renditions),
+	})
+
+	u.jobs.Add(1)
 	go func(ctx context.Context) {
-		if err := u.encodedRepo.Upload(ctx, req.mediaID, encodedDir); err != nil {
+		defer u.jobs.Done()
+		defer u.finishJob(req.tenantID, req.mediaID)
+		uploadCtx, uploadSpan := tracer.Tracer.Start(ctx, "upload")
+		start := time.Now()
+		files, err := tenant.EncodedRepo.Upload(uploadCtx, req.mediaID, encodedDir)
+		if err == nil {
+			// 失敗した場合はソースを残し、再実行時にアップロード済みのファイルを飛ばして書き直す
+			err = u.publishIntegrityManifest(uploadCtx, tenant, req.mediaID, files)
+		}
+		if err != nil {
+			metrics.SetJobState(tenant.ID, metrics.JobStateUploading, "")
+			metrics.JobsFinished.WithLabelValues(tenant.ID, "failed").Inc()
+			endSpan(uploadSpan, err)
+			endSpan(req.span, err)
 			slog.Error("failed to upload", slog.Any("error", err))
+			// 再実行時はエンコードからやり直す
+			u.removeJobFiles(req, encodedDir)
 			return
 		}
-		if err := u.sourceRepo.DeleteSourceContent(ctx, req.mediaID); err != nil {
+		uploadSpan.End()
+		u.invalidateRepresentations(tenant.ID, req.mediaID)
+		metrics.StorageTransferDuration.WithLabelValues("upload").Observe(time.Since(start).Seconds())
+		metrics.SetJobState(tenant.ID, metrics.JobStateUploading, "")
+		metrics.JobsFinished.WithLabelValues(tenant.ID, "succeeded").Inc()
+
+		if size, err := fileutil.DirSize(encodedDir); err != nil {
+			slog.Warn("failed to get encoded size", slog.Any("error", err))
+		} else {
+			metrics.StorageTransferBytes.WithLabelValues("upload").Add(float64(size))
+			u.recordUsage(tenant.ID, req.mediaID, entity.UsageRecord{StoredBytes: size})
+		}
+
+		ctx, cleanupSpan := tracer.Tracer.Start(ctx, "cleanup")
+		defer req.span.End()
+		defer cleanupSpan.End()
+		if err := tenant.SourceRepo.DeleteSourceContent(ctx, req.mediaID); err != nil {
 			slog.Error("failed to delete source content", slog.Any("error", err))
+			cleanupSpan.RecordError(err)
 			// returnしない
 		}
-		if err := os.RemoveAll(encodedDir); err != nil {
+		if err := u.encoder.RemoveOutput(encodedDir); err != nil {
 			slog.Error("failed to remove encoded dir", slog.Any("error", err))
+			cleanupSpan.RecordError(err)
 			// returnしない
 		}
 
-		if err := os.Remove(req.uploadedFilePath); err != nil {
+		if err := req.removeSource(); err != nil {
 			slog.Error("failed to remove uploaded file", slog.Any("error", err))
+			cleanupSpan.RecordError(err)
 			// returnしない
 		}
 	}(ctx)
@@ -98,17 +273,45 @@ func (u *Usecase) encode(ctx context.Context, req encodeRequest) error {
 	return nil
 }
 
+// downloadUploadedFiles downloads an uploaded file of any tenant.
+// Tenants are visited in turn so that one tenant's backlog does not block the others.
 func (u *Usecase) downloadUploadedFiles(ctx context.Context) (*encodeRequest, error) {
+	for range u.tenantIDs {
+		tenant := u.tenants[u.tenantIDs[u.nextTenant]]
+		u.nextTenant = (u.nextTenant + 1) % len(u.tenantIDs)
+
+		if u.quotaExceeded(tenant) {
+			slog.Warn("quota exceeded, skip encoding", slog.String("tenantID", tenant.ID))
+			continue
+		}
+
+		req, err := u.downloadTenantUploadedFiles(ctx, tenant)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		if req != nil {
+			return req, nil
+		}
+	}
+	return nil, nil
+}
+
+func (u *Usecase) downloadTenantUploadedFiles(ctx context.Context, tenant Tenant) (*encodeRequest, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	objectInfos := u.sourceRepo.ListUploadedFiles(ctx)
+	listStart := time.Now()
+	objectInfos := tenant.SourceRepo.ListUploadedFiles(ctx)
 	for objectInfo, err := range objectInfos {
 		if err != nil {
 			return nil, fmt.Errorf("failed to list objects: %w", err)
 		}
 
 		if objectInfo.Tags != nil {
+			if reason, ok := objectInfo.Tags[failedReasonTag]; ok {
+				slog.Debug("skip failed source", slog.String("mediaID", objectInfo.ID), slog.String("reason", reason))
+				continue
+			}
 			startAt, ok := objectInfo.Tags["startAt"]
 			if ok {
 				startAtTime, err := synchro.ParseISO[tz.AsiaTokyo](startAt)
@@ -125,38 +328,300 @@ func (u *Usecase) downloadUploadedFiles(ctx context.Context) (*encodeRequest, er
 			slog.Debug("tags not found")
 		}
 
-		if err := u.sourceRepo.SetObjectTags(ctx, objectInfo.ID, map[string]string{"startAt": synchro.Now[tz.AsiaTokyo]().Format(time.RFC3339), "hostname": u.hostname}); err != nil {
-			return nil, fmt.Errorf("failed to set tags: %w", err)
+		// ダウンロードする前にサイズを確認する
+		if err := u.sourceConstraints.ValidateSize(objectInfo.Size); err != nil {
+			metrics.JobsFinished.WithLabelValues(tenant.ID, "rejected").Inc()
+			u.recordEncodeFailure(ctx, tenant, objectInfo.ID, entity.EncodeFailureReasonOf(err), err.Error())
+			continue
 		}
 
-		object, err := u.sourceRepo.GetSourceContent(ctx, objectInfo.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get object: %w", err)
+		streaming := u.shouldStream(objectInfo.ID)
+		if !streaming {
+			// 空き容量が足りなければclaimせずに他のホストに任せる
+			if err := u.checkDiskSpace(objectInfo.Size); err != nil {
+				slog.Warn("skip uploaded file", slog.String("tenantID", tenant.ID), slog.String("mediaID", objectInfo.ID), slog.Any("error", err))
+				continue
+			}
 		}
-		defer object.Close()
 
-		file, err := os.CreateTemp("", "mpeg-dash-encoder-downloaded")
-		if err != nil {
-			return nil, fmt.Errorf("failed to create temp file: %w", err)
+		// ジョブごとにトレースを作り、一覧の取得はジョブのトレースに含める
+		jobCtx, jobSpan := startJobSpan(tenant.ID, objectInfo, listStart)
+		_, listSpan := tracer.Tracer.Start(jobCtx, "list uploaded files", trace.WithTimestamp(listStart))
+		listSpan.End()
+
+		_, claimSpan := tracer.Tracer.Start(jobCtx, "claim")
+		if err := tenant.SourceRepo.SetObjectTags(trace.ContextWithSpan(ctx, claimSpan), objectInfo.ID, map[string]string{"startAt": synchro.Now[tz.AsiaTokyo]().Format(time.RFC3339), "hostname": u.hostname}); err != nil {
+			err = fmt.Errorf("failed to set tags: %w", err)
+			endSpan(claimSpan, err)
+			endSpan(jobSpan, err)
+			return nil, err
+		}
+		claimSpan.End()
+		u.active.add(tenant.ID, objectInfo.ID)
+
+		// ダウンロードする場合も、先にURLから読んで制約を確認する
+		_, probeSpan := tracer.Tracer.Start(jobCtx, "ffprobe source url")
+		sourceURL, info, err := u.probeSourceURL(trace.ContextWithSpan(ctx, probeSpan), tenant.SourceRepo, objectInfo.ID)
+		var mediaInfo *entity.MediaInfo
+		if err == nil {
+			probeSpan.SetAttributes(attribute.Float64("media.duration_seconds", info.Duration.Seconds()))
+			probeSpan.End()
+			mediaInfo = &info
+
+			if err := u.sourceConstraints.Validate(info); err != nil {
+				metrics.JobsFinished.WithLabelValues(tenant.ID, "rejected").Inc()
+				endSpan(jobSpan, err)
+				u.recordEncodeFailure(ctx, tenant, objectInfo.ID, entity.EncodeFailureReasonOf(err), err.Error())
+				u.active.remove(tenant.ID, objectInfo.ID)
+				continue
+			}
+			if streaming {
+				metrics.SetJobState(tenant.ID, "", metrics.JobStateQueued)
+				return &encodeRequest{
+					tenantID:         tenant.ID,
+					mediaID:          objectInfo.ID,
+					uploadedFilePath: sourceURL,
+					sourceVersion:    objectInfo.Version,
+					mediaInfo:        mediaInfo,
+					span:             jobSpan,
+				}, nil
+			}
+		} else {
+			endSpan(probeSpan, err)
+			if streaming {
+				slog.Warn("failed to read source from url, fallback to download", slog.String("tenantID", tenant.ID), slog.String("mediaID", objectInfo.ID), slog.Any("error", err))
+				if err := u.checkDiskSpace(objectInfo.Size); err != nil {
+					slog.Warn("skip uploaded file", slog.String("tenantID", tenant.ID), slog.String("mediaID", objectInfo.ID), slog.Any("error", err))
+					// 他のホストが処理できるようにclaimを解放する
+					if err := tenant.SourceRepo.RemoveObjectTags(context.WithoutCancel(ctx), objectInfo.ID); err != nil {
+						slog.Error("failed to release uploaded file", slog.String("mediaID", objectInfo.ID), slog.Any("error", err))
+					}
+					u.active.remove(tenant.ID, objectInfo.ID)
+					endSpan(jobSpan, err)
+					continue
+				}
+			} else {
+				// ダウンロードしたファイルをencodeで読む
+				slog.Warn("failed to probe source url, probe after download", slog.String("tenantID", tenant.ID), slog.String("mediaID", objectInfo.ID), slog.Any("error", err))
+			}
 		}
-		defer file.Close()
 
-		if _, err := io.Copy(file, object); err != nil {
-			return nil, fmt.Errorf("failed to copy object: %w", err)
+		metrics.SetJobState(tenant.ID, "", metrics.JobStateDownloading)
+		_, downloadSpan := tracer.Tracer.Start(jobCtx, "download source")
+		uploadedFilePath, err := u.downloadSourceContent(trace.ContextWithSpan(ctx, downloadSpan), tenant, objectInfo.ID)
+		if err != nil {
+			u.active.remove(tenant.ID, objectInfo.ID)
+			metrics.SetJobState(tenant.ID, metrics.JobStateDownloading, "")
+			metrics.JobsFinished.WithLabelValues(tenant.ID, "failed").Inc()
+			endSpan(downloadSpan, err)
+			endSpan(jobSpan, err)
+			return nil, err
 		}
+		downloadSpan.End()
+		metrics.SetJobState(tenant.ID, metrics.JobStateDownloading, metrics.JobStateQueued)
 
 		return &encodeRequest{
+			tenantID:         tenant.ID,
 			mediaID:          objectInfo.ID,
-			uploadedFilePath: file.Name(),
+			uploadedFilePath: uploadedFilePath,
+			downloaded:       true,
+			sourceVersion:    objectInfo.Version,
+			mediaInfo:        mediaInfo,
+			span:             jobSpan,
 		}, nil
 	}
 	return nil, nil
 }
 
-func (u *Usecase) shutdown(ctx context.Context) error {
-	close(u.encodeQueue)
+func (r encodeRequest) removeSource() error {
+	if !r.downloaded {
+		return nil
+	}
+	return os.Remove(r.uploadedFilePath)
+}
 
-	objectInfos := u.sourceRepo.ListUploadedFiles(ctx)
+// removeJobFiles removes the local files of a job which failed after the encode.
+func (u *Usecase) removeJobFiles(req encodeRequest, encodedDir string) {
+	if err := u.encoder.RemoveOutput(encodedDir); err != nil {
+		slog.Error("failed to remove encoded dir", slog.Any("error", err))
+	}
+	if err := req.removeSource(); err != nil {
+		slog.Error("failed to remove uploaded file", slog.Any("error", err))
+	}
+}
+
+func (u *Usecase) shouldStream(id string) bool {
+	if u.sourceConfig.ReadMode != config.SourceReadModeStream {
+		return false
+	}
+	ext := filepath.Ext(id)
+	for _, e := range u.sourceConfig.DownloadExtensions {
+		if strings.EqualFold(strings.TrimSpace(e), ext) {
+			return false
+		}
+	}
+	return true
+}
+
+// ダウンロード先の空き容量を使い切らないよう、readinessの閾値を残す
+func (u *Usecase) checkDiskSpace(size int64) error {
+	free, err := fileutil.FreeSpace(os.TempDir())
+	if errors.Is(err, fileutil.ErrFreeSpaceNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get free disk space: %w", err)
+	}
+	required := uint64(max(size, 0)) + u.readinessConfig.MinFreeDiskBytes
+	if free < required {
+		return fmt.Errorf("not enough disk space to download source: %d bytes free, %d bytes required", free, required)
+	}
+	return nil
+}
+
+// ffmpegが直接読めるURLを返す。ffprobeで読めなければエラーを返すので、呼び出し側でダウンロードにフォールバックする
+func (u *Usecase) probeSourceURL(ctx context.Context, sourceRepo SourceRepository, id string) (string, entity.MediaInfo, error) {
+	// エンコードがタイムアウトするまでURLが有効であればよい
+	sourceURL, err := sourceRepo.SourceURL(ctx, id, u.encodeTimeout+time.Hour)
+	if err != nil {
+		return "", entity.MediaInfo{}, fmt.Errorf("failed to get source url: %w", err)
+	}
+	input := sourceURL.String()
+	if sourceURL.Scheme == "file" {
+		input = sourceURL.Path
+	}
+	info, err := u.encoder.Probe(ctx, input)
+	if err != nil {
+		return "", entity.MediaInfo{}, fmt.Errorf("failed to probe source url: %w", err)
+	}
+	return input, info, nil
+}
+
+func (u *Usecase) downloadSourceContent(ctx context.Context, tenant Tenant, id string) (string, error) {
+	start := time.Now()
+	object, err := tenant.SourceRepo.GetSourceContent(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to get object: %w", err)
+	}
+	defer object.Close()
+
+	file, err := u.active.createDownloadFile(tenant.ID, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer file.Close()
+
+	n, err := io.Copy(file, object)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy object: %w", err)
+	}
+	metrics.StorageTransferBytes.WithLabelValues("download").Add(float64(n))
+	metrics.StorageTransferDuration.WithLabelValues("download").Observe(time.Since(start).Seconds())
+
+	return file.Name(), nil
+}
+
+func profileName(profile entity.EncodingProfile) string {
+	if profile.Name == "" {
+		return "default"
+	}
+	return profile.Name
+}
+
+func countRenditions(encodedDir string) (int, error) {
+	manifest, err := os.ReadFile(filepath.Join(encodedDir, mpd.FileName))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	representations, err := mpd.Representations(manifest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return len(representations), nil
+}
+
+// cleanupTimeout is the timeout of each step after the jobs are canceled on shutdown (archiving the job log and releasing the claims).
+// SHUTDOWN_TIMEOUT must leave room for them within the grace period.
+const cleanupTimeout = 5 * time.Second
+
+// shutdown waits for the running encodes and uploads until the shutdown timeout.
+// The jobs still running after that are canceled, and their claims are released so that other hosts can encode them.
+func (u *Usecase) shutdown(workerDone <-chan struct{}, cancelJobs context.CancelFunc) {
+	drained := make(chan struct{})
+	go func() {
+		<-workerDone
+		u.jobs.Wait()
+		close(drained)
+	}()
+
+	slog.Info("waiting for running jobs", slog.Duration("timeout", u.shutdownTimeout))
+	timer := time.NewTimer(u.shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		slog.Info("all running jobs finished")
+	case <-timer.C:
+		slog.Warn("shutdown timeout exceeded, cancel running jobs")
+		cancelJobs()
+		<-drained
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	for _, id := range u.tenantIDs {
+		u.releaseTenantUploadedFiles(ctx, u.tenants[id])
+	}
+}
+
+const (
+	// リトライしても成功しない失敗の原因。このタグがあるソースはエンコードしない
+	failedReasonTag = "failedReason"
+	failedAtTag     = "failedAt"
+	// リトライする失敗の原因。claimと同じくencodeTimeoutの後に再びエンコードする
+	lastFailedReasonTag = "lastFailedReason"
+	failedDetailTag     = "failedDetail"
+)
+
+// S3のタグの値に使える文字は限られている
+var invalidTagValueChars = regexp.MustCompile(`[^\p{L}\p{N} +\-=._:/@]`)
+
+func tagValue(s string) string {
+	r := []rune(invalidTagValueChars.ReplaceAllString(s, " "))
+	if len(r) > 256 {
+		r = r[:256]
+	}
+	return string(r)
+}
+
+// recordEncodeFailure records the reason to the tags of the source, so that it can be seen from the storage.
+// detail is recorded only for permanent failures.
+func (u *Usecase) recordEncodeFailure(ctx context.Context, tenant Tenant, mediaID string, reason entity.EncodeFailureReason, detail string) {
+	now := synchro.Now[tz.AsiaTokyo]().Format(time.RFC3339)
+	tags := map[string]string{
+		"startAt":           now,
+		"hostname":          u.hostname,
+		lastFailedReasonTag: string(reason),
+	}
+	if reason.Permanent() {
+		// hostnameを付けないので、起動時にも解放されない
+		tags = map[string]string{
+			failedAtTag:     now,
+			failedReasonTag: string(reason),
+		}
+		if detail != "" {
+			tags[failedDetailTag] = tagValue(detail)
+		}
+	}
+	if err := tenant.SourceRepo.SetObjectTags(ctx, mediaID, tags); err != nil {
+		slog.Error("failed to record encode failure", slog.String("mediaID", mediaID), slog.Any("error", err))
+		return
+	}
+	slog.Warn("encode failed", slog.String("tenantID", tenant.ID), slog.String("mediaID", mediaID), slog.String("reason", string(reason)), slog.Bool("permanent", reason.Permanent()))
+}
+
+// releaseTenantUploadedFiles removes the tags of the files this host has claimed, so that other hosts can encode them.
+func (u *Usecase) releaseTenantUploadedFiles(ctx context.Context, tenant Tenant) {
+	objectInfos := tenant.SourceRepo.ListUploadedFiles(ctx)
 	for objectInfo, err := range objectInfos {
 		if err != nil {
 			slog.Error("failed to list objects", slog.Any("error", err))
@@ -173,10 +638,9 @@ func (u *Usecase) shutdown(ctx context.Context) error {
 			}
 		}
 
-		if err := u.sourceRepo.RemoveObjectTags(ctx, objectInfo.ID); err != nil {
+		if err := tenant.SourceRepo.RemoveObjectTags(ctx, objectInfo.ID); err != nil {
 			slog.Error("failed to remove tags", slog.Any("error", err))
 			continue
 		}
 	}
-	return nil
 }
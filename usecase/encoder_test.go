@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type probeEncoder struct {
+	Encoder
+	info entity.MediaInfo
+}
+
+func (e probeEncoder) Probe(ctx context.Context, sourceFilePath string) (entity.MediaInfo, error) {
+	return e.info, nil
+}
+
+func TestUsecase_encode_Rejected(t *testing.T) {
+	repo := &tagSourceRepository{tags: map[string]map[string]string{
+		"movie-1": {"startAt": "2025-01-31T12:00:00+09:00", "hostname": "host-a"},
+	}}
+	u := &Usecase{
+		tenants: map[string]Tenant{
+			entity.DefaultTenantID: {Tenant: entity.Tenant{ID: entity.DefaultTenantID}, SourceRepo: repo},
+		},
+		encoder: probeEncoder{info: entity.MediaInfo{
+			Duration: 2 * time.Hour,
+			Streams:  []entity.MediaStream{{Type: entity.MediaStreamTypeVideo, Codec: "h264"}},
+		}},
+		sourceConstraints: entity.SourceConstraints{MaxDuration: time.Hour},
+		hostname:          "host-a",
+	}
+
+	downloaded := filepath.Join(t.TempDir(), "source")
+	require.NoError(t, os.WriteFile(downloaded, []byte("source"), 0o644))
+
+	err := u.encode(context.Background(), encodeRequest{
+		tenantID:         entity.DefaultTenantID,
+		mediaID:          "movie-1",
+		uploadedFilePath: downloaded,
+		downloaded:       true,
+		span:             trace.SpanFromContext(context.Background()),
+	})
+	assert.ErrorIs(t, err, domain.ErrInvalidSource)
+	assert.NoFileExists(t, downloaded, "the downloaded file must be removed on rejection")
+	assert.Equal(t, string(entity.EncodeFailureReasonInvalidSource), repo.tags["movie-1"][failedReasonTag])
+}
+
+type listSourceRepository struct {
+	tagSourceRepository
+	dir        string
+	downloaded bool
+}
+
+func (r *listSourceRepository) ListUploadedFiles(ctx context.Context) iter.Seq2[entity.SourceFile, error] {
+	return func(yield func(entity.SourceFile, error) bool) {
+		for id := range r.tags {
+			if !yield(entity.SourceFile{ID: id, Size: 100, Version: "v1", Tags: r.tags[id]}, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (r *listSourceRepository) SourceURL(ctx context.Context, id string, expiry time.Duration) (*url.URL, error) {
+	return &url.URL{Scheme: "file", Path: filepath.Join(r.dir, id)}, nil
+}
+
+func (r *listSourceRepository) GetSourceContent(ctx context.Context, id string) (io.ReadSeekCloser, error) {
+	r.downloaded = true
+	return os.Open(filepath.Join(r.dir, id))
+}
+
+func TestUsecase_downloadTenantUploadedFiles_Rejected(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	repo := &listSourceRepository{
+		tagSourceRepository: tagSourceRepository{tags: map[string]map[string]string{"movie-1": nil}},
+		dir:                 t.TempDir(),
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(repo.dir, "movie-1"), []byte("source"), 0o644))
+	tenant := Tenant{Tenant: entity.Tenant{ID: entity.DefaultTenantID}, SourceRepo: repo}
+	u := &Usecase{
+		tenants: map[string]Tenant{entity.DefaultTenantID: tenant},
+		encoder: probeEncoder{info: entity.MediaInfo{
+			Duration: 2 * time.Hour,
+			Streams:  []entity.MediaStream{{Type: entity.MediaStreamTypeVideo, Codec: "h264"}},
+		}},
+		sourceConstraints: entity.SourceConstraints{MaxDuration: time.Hour},
+		active:            newActiveJobs(),
+		hostname:          "host-a",
+	}
+
+	// ダウンロードする前にURLから読んで拒否する
+	req, err := u.downloadTenantUploadedFiles(context.Background(), tenant)
+	require.NoError(t, err)
+	assert.Nil(t, req)
+	assert.False(t, repo.downloaded, "the source must not be downloaded")
+	assert.False(t, u.active.has(tenant.ID, "movie-1"))
+	assert.Equal(t, string(entity.EncodeFailureReasonInvalidSource), repo.tags["movie-1"][failedReasonTag])
+}
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/fileutil"
+)
+
+// Readiness runs the dependency checks concurrently. It reports ready only if all checks are ok.
+func (u *Usecase) Readiness(ctx context.Context) (bool, []entity.HealthCheck) {
+	ctx, cancel := context.WithTimeout(ctx, u.readinessConfig.Timeout)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		checks []entity.HealthCheck
+	)
+	run := func(f func() []entity.HealthCheck) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := f()
+			mu.Lock()
+			defer mu.Unlock()
+			checks = append(checks, c...)
+		}()
+	}
+	ping := func(name string, f func(ctx context.Context) error) {
+		run(func() []entity.HealthCheck {
+			return []entity.HealthCheck{entity.NewHealthCheck(name, f(ctx), "")}
+		})
+	}
+
+	for _, id := range u.tenantIDs {
+		tenant := u.tenants[id]
+		name := id
+		if name == "" {
+			name = "default"
+		}
+		ping("source_bucket:"+name, tenant.SourceRepo.Ping)
+		ping("output_bucket:"+name, tenant.EncodedRepo.Ping)
+	}
+	ping("system_bucket", u.revocationRepo.Ping)
+	run(func() []entity.HealthCheck { return u.encoder.Check(ctx) })
+	run(func() []entity.HealthCheck { return u.checkTempDir() })
+
+	wg.Wait()
+
+	slices.SortStableFunc(checks, func(a, b entity.HealthCheck) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	ready := !slices.ContainsFunc(checks, func(c entity.HealthCheck) bool {
+		return c.Status != entity.HealthStatusOK
+	})
+	return ready, checks
+}
+
+func (u *Usecase) checkTempDir() []entity.HealthCheck {
+	dir := os.TempDir()
+	checks := []entity.HealthCheck{
+		entity.NewHealthCheck("temp_dir", fileutil.CheckWritable(dir), dir),
+	}
+
+	free, err := fileutil.FreeSpace(dir)
+	if errors.Is(err, fileutil.ErrFreeSpaceNotSupported) {
+		return checks
+	}
+	if err == nil && free < u.readinessConfig.MinFreeDiskBytes {
+		err = fmt.Errorf("free disk space %d bytes is less than %d bytes", free, u.readinessConfig.MinFreeDiskBytes)
+	}
+	checks = append(checks, entity.NewHealthCheck("free_disk_space", err, fmt.Sprintf("%d bytes", free)))
+	return checks
+}
@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"golang.org/x/sync/errgroup"
+)
+
+// integrityVerifyConcurrency is the number of files hashed at the same time by VerifyMedia.
+const integrityVerifyConcurrency = 8
+
+// publishIntegrityManifest writes the signed integrity manifest of the uploaded files.
+func (u *Usecase) publishIntegrityManifest(ctx context.Context, tenant Tenant, mediaID string, files []entity.IntegrityFile) error {
+	encoderVersion, err := u.encoder.Version(ctx)
+	if err != nil {
+		slog.Warn("failed to get encoder version", slog.Any("error", err))
+		// returnしない
+	}
+
+	manifest := entity.IntegrityManifest{
+		MediaID:        mediaID,
+		TenantID:       tenant.ID,
+		EncoderVersion: encoderVersion,
+		Profile:        tenant.Profile,
+		CreatedAt:      time.Now().UTC(),
+		Files:          files,
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal integrity manifest: %w", err)
+	}
+	signature, kid, err := tenant.TokenIssuer.SignIntegrityManifest(entity.IntegrityDigest(raw))
+	if err != nil {
+		return fmt.Errorf("failed to sign integrity manifest: %w", err)
+	}
+
+	b, err := json.Marshal(entity.SignedIntegrityManifest{Manifest: raw, KeyID: kid, Signature: signature})
+	if err != nil {
+		return fmt.Errorf("failed to marshal integrity manifest: %w", err)
+	}
+	if err := tenant.EncodedRepo.PutFile(ctx, mediaID, entity.IntegrityManifestFileName, b, "application/json"); err != nil {
+		return fmt.Errorf("failed to put integrity manifest: %w", err)
+	}
+	return nil
+}
+
+// VerifyMedia checks the signature of the integrity manifest, and the size and SHA-256 of every file in it.
+// It returns domain.ErrObjectNotFound if the media has no integrity manifest.
+func (u *Usecase) VerifyMedia(ctx context.Context, tenantID string, mediaID string) (entity.IntegrityReport, error) {
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		return entity.IntegrityReport{}, err
+	}
+	tenant, err := u.tenant(tenantID)
+	if err != nil {
+		return entity.IntegrityReport{}, err
+	}
+
+	if _, err := tenant.EncodedRepo.Stat(ctx, mediaID, entity.IntegrityManifestFileName); err != nil {
+		return entity.IntegrityReport{}, err
+	}
+	signed, err := readIntegrityManifest(ctx, tenant.EncodedRepo, mediaID)
+	if err != nil {
+		return entity.IntegrityReport{}, err
+	}
+	manifest, err := signed.Decode()
+	if err != nil {
+		return entity.IntegrityReport{}, err
+	}
+
+	report := entity.IntegrityReport{
+		MediaID: mediaID,
+		Files:   make([]entity.IntegrityFileResult, len(manifest.Files)),
+	}
+	if err := tenant.TokenIssuer.VerifyIntegrityManifest(signed.Signature, signed.KeyID, entity.IntegrityDigest(signed.Manifest)); err != nil {
+		report.SignatureError = err.Error()
+	} else {
+		report.SignatureValid = true
+	}
+
+	var eg errgroup.Group
+	eg.SetLimit(integrityVerifyConcurrency)
+	for i, file := range manifest.Files {
+		eg.Go(func() error {
+			report.Files[i] = verifyIntegrityFile(ctx, tenant.EncodedRepo, mediaID, file)
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	report.Valid = report.SignatureValid
+	for _, f := range report.Files {
+		if f.Status != entity.IntegrityFileStatusOK {
+			report.Valid = false
+		}
+	}
+	return report, nil
+}
+
+func readIntegrityManifest(ctx context.Context, repo EncodedObjectRepository, mediaID string) (entity.SignedIntegrityManifest, error) {
+	file, err := repo.GetObject(ctx, mediaID, entity.IntegrityManifestFileName)
+	if err != nil {
+		return entity.SignedIntegrityManifest{}, fmt.Errorf("failed to get integrity manifest: %w", err)
+	}
+	defer file.Close()
+
+	var signed entity.SignedIntegrityManifest
+	if err := json.NewDecoder(file).Decode(&signed); err != nil {
+		return entity.SignedIntegrityManifest{}, fmt.Errorf("failed to decode integrity manifest: %w", err)
+	}
+	return signed, nil
+}
+
+func verifyIntegrityFile(ctx context.Context, repo EncodedObjectRepository, mediaID string, file entity.IntegrityFile) entity.IntegrityFileResult {
+	result := entity.IntegrityFileResult{Path: file.Path}
+
+	// メディアの外を読まないよう、パスはファイル名と同じ規則で検証する
+	if err := entity.ValidateMediaFileName(file.Path); err != nil {
+		result.Status = entity.IntegrityFileStatusError
+		result.Message = err.Error()
+		return result
+	}
+
+	size, err := repo.Stat(ctx, mediaID, file.Path)
+	if err != nil {
+		result.Status = entity.IntegrityFileStatusError
+		if errors.Is(err, domain.ErrObjectNotFound) {
+			result.Status = entity.IntegrityFileStatusMissing
+		}
+		result.Message = err.Error()
+		return result
+	}
+	if size != file.Size {
+		result.Status = entity.IntegrityFileStatusSizeMismatch
+		result.Message = fmt.Sprintf("expected %d bytes, got %d bytes", file.Size, size)
+		return result
+	}
+
+	object, err := repo.GetObject(ctx, mediaID, file.Path)
+	if err != nil {
+		result.Status = entity.IntegrityFileStatusError
+		result.Message = err.Error()
+		return result
+	}
+	defer object.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, object); err != nil {
+		result.Status = entity.IntegrityFileStatusError
+		result.Message = err.Error()
+		return result
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != file.SHA256 {
+		result.Status = entity.IntegrityFileStatusHashMismatch
+		result.Message = fmt.Sprintf("expected %s, got %s", file.SHA256, sum)
+		return result
+	}
+
+	result.Status = entity.IntegrityFileStatusOK
+	return result
+}
@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const downloadedFilePrefix = "mpeg-dash-encoder-downloaded"
+
+// activeJobs records the jobs running on this host, so that the janitor does not remove their files.
+// Media IDs are unique only within a tenant, so jobs are keyed by both.
+type activeJobs struct {
+	mu   sync.Mutex
+	jobs map[activeJobKey]activeJob
+}
+
+type activeJobKey struct {
+	tenantID string
+	mediaID  string
+}
+
+type activeJob struct {
+	downloadedFilePath string
+}
+
+func newActiveJobs() *activeJobs {
+	return &activeJobs{
+		jobs: make(map[activeJobKey]activeJob),
+	}
+}
+
+func (a *activeJobs) add(tenantID string, mediaID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.jobs[activeJobKey{tenantID: tenantID, mediaID: mediaID}] = activeJob{}
+}
+
+func (a *activeJobs) has(tenantID string, mediaID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.jobs[activeJobKey{tenantID: tenantID, mediaID: mediaID}]
+	return ok
+}
+
+func (a *activeJobs) remove(tenantID string, mediaID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.jobs, activeJobKey{tenantID: tenantID, mediaID: mediaID})
+}
+
+// createDownloadFile creates the temp file for the source under the lock, so that the janitor does not see it before it is recorded.
+func (a *activeJobs) createDownloadFile(tenantID string, mediaID string) (*os.File, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	file, err := os.CreateTemp("", downloadedFilePrefix)
+	if err != nil {
+		return nil, err
+	}
+	key := activeJobKey{tenantID: tenantID, mediaID: mediaID}
+	job := a.jobs[key]
+	job.downloadedFilePath = file.Name()
+	a.jobs[key] = job
+	return file, nil
+}
+
+// removeOrphans removes the temp files and the ffmpeg logs left by crashed or failed jobs.
+func (u *Usecase) removeOrphans() {
+	u.active.mu.Lock()
+	defer u.active.mu.Unlock()
+
+	isActive := func(tenantID string, mediaID string) bool {
+		_, ok := u.active.jobs[activeJobKey{tenantID: tenantID, mediaID: mediaID}]
+		return ok
+	}
+
+	if n, err := u.removeOrphanDownloads(); err != nil {
+		slog.Error("failed to remove orphan downloaded files", slog.Any("error", err))
+		// returnしない
+	} else if n > 0 {
+		slog.Info("removed orphan downloaded files", slog.Int("count", n))
+	}
+
+	if n, err := u.encoder.RemoveOrphanOutputs(isActive, u.janitorConfig.OutputRetention); err != nil {
+		slog.Error("failed to remove orphan outputs", slog.Any("error", err))
+		// returnしない
+	} else if n > 0 {
+		slog.Info("removed orphan outputs", slog.Int("count", n))
+	}
+
+	if n, err := u.encoder.RemoveOldLogs(isActive, u.janitorConfig.LogRetention); err != nil {
+		slog.Error("failed to remove old ffmpeg logs", slog.Any("error", err))
+		// returnしない
+	} else if n > 0 {
+		slog.Info("removed old ffmpeg logs", slog.Int("count", n))
+	}
+}
+
+// removeOrphanDownloads must be called with u.active.mu held.
+func (u *Usecase) removeOrphanDownloads() (int, error) {
+	downloaded := make(map[string]struct{}, len(u.active.jobs))
+	for _, job := range u.active.jobs {
+		if job.downloadedFilePath != "" {
+			downloaded[job.downloadedFilePath] = struct{}{}
+		}
+	}
+
+	entries, err := os.ReadDir(os.TempDir())
+	if err != nil {
+		return 0, fmt.Errorf("failed to read temp dir: %w", err)
+	}
+	removed := 0
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), downloadedFilePrefix) {
+			continue
+		}
+		path := filepath.Join(os.TempDir(), e.Name())
+		if _, ok := downloaded[path]; ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("failed to remove downloaded file: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
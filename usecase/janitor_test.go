@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveJobs(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	u := &Usecase{active: newActiveJobs()}
+
+	u.active.add("team-a", "movie-1")
+	u.active.add("team-b", "movie-1")
+	assert.True(t, u.active.has("team-a", "movie-1"))
+	assert.True(t, u.active.has("team-b", "movie-1"))
+	assert.False(t, u.active.has("team-c", "movie-1"))
+
+	fileA, err := u.active.createDownloadFile("team-a", "movie-1")
+	require.NoError(t, err)
+	fileA.Close()
+	fileB, err := u.active.createDownloadFile("team-b", "movie-1")
+	require.NoError(t, err)
+	fileB.Close()
+
+	// 同じメディアIDでも他のテナントのジョブは残る
+	u.active.remove("team-a", "movie-1")
+	assert.False(t, u.active.has("team-a", "movie-1"))
+	assert.True(t, u.active.has("team-b", "movie-1"))
+
+	removed, err := u.removeOrphanDownloads()
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, fileA.Name())
+	assert.FileExists(t, fileB.Name())
+}
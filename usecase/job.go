@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// GetEncodeJob returns the state of the encode of a source, including the reason of the last failure.
+// It returns domain.ErrObjectNotFound if the source does not exist, e.g. it has been encoded and deleted.
+func (u *Usecase) GetEncodeJob(ctx context.Context, tenantID string, mediaID string) (entity.EncodeJob, error) {
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		return entity.EncodeJob{}, err
+	}
+	tenant, err := u.tenant(tenantID)
+	if err != nil {
+		return entity.EncodeJob{}, err
+	}
+
+	tags, err := tenant.SourceRepo.GetObjectTags(ctx, mediaID)
+	if err != nil {
+		return entity.EncodeJob{}, err
+	}
+	return encodeJobFromTags(tenant.ID, mediaID, tags), nil
+}
+
+func encodeJobFromTags(tenantID string, mediaID string, tags map[string]string) entity.EncodeJob {
+	job := entity.EncodeJob{
+		MediaID:  mediaID,
+		TenantID: tenantID,
+		State:    entity.EncodeJobStatePending,
+	}
+	if reason, ok := tags[failedReasonTag]; ok {
+		job.State = entity.EncodeJobStateFailed
+		job.FailedReason = entity.EncodeFailureReason(reason)
+		job.FailedDetail = tags[failedDetailTag]
+		job.FailedAt = parseTagTime(tags[failedAtTag])
+		return job
+	}
+	if startAt, ok := tags["startAt"]; ok {
+		job.State = entity.EncodeJobStateRunning
+		job.StartedAt = parseTagTime(startAt)
+		job.Hostname = tags["hostname"]
+		if reason, ok := tags[lastFailedReasonTag]; ok {
+			job.State = entity.EncodeJobStateRetrying
+			job.LastFailedReason = entity.EncodeFailureReason(reason)
+		}
+	}
+	return job
+}
+
+// parseTagTime returns the zero time if the tag is not a valid time, since it is only informational.
+func parseTagTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ClearEncodeFailure removes the permanent failure of a source, so that it is encoded again.
+// It returns domain.ErrEncodeNotFailed if the source has not failed permanently.
+func (u *Usecase) ClearEncodeFailure(ctx context.Context, tenantID string, mediaID string) error {
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		return err
+	}
+	tenant, err := u.tenant(tenantID)
+	if err != nil {
+		return err
+	}
+
+	tags, err := tenant.SourceRepo.GetObjectTags(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	reason, ok := tags[failedReasonTag]
+	if !ok {
+		// claimを外すと二重にエンコードされる
+		return fmt.Errorf("%w: %s", domain.ErrEncodeNotFailed, mediaID)
+	}
+	if err := tenant.SourceRepo.RemoveObjectTags(ctx, mediaID); err != nil {
+		return fmt.Errorf("failed to clear encode failure: %w", err)
+	}
+	slog.Info("cleared encode failure", slog.String("tenantID", tenant.ID), slog.String("mediaID", mediaID), slog.String("reason", reason))
+	return nil
+}
@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+type tagSourceRepository struct {
+	SourceRepository
+	tags map[string]map[string]string
+}
+
+func (r *tagSourceRepository) GetObjectTags(ctx context.Context, id string) (map[string]string, error) {
+	tags, ok := r.tags[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrObjectNotFound, id)
+	}
+	return tags, nil
+}
+
+func (r *tagSourceRepository) SetObjectTags(ctx context.Context, id string, tags map[string]string) error {
+	r.tags[id] = tags
+	return nil
+}
+
+func (r *tagSourceRepository) RemoveObjectTags(ctx context.Context, id string) error {
+	r.tags[id] = map[string]string{}
+	return nil
+}
+
+func TestUsecase_EncodeJob(t *testing.T) {
+	repo := &tagSourceRepository{tags: map[string]map[string]string{
+		"pending": {},
+		"running": {"startAt": "2025-01-31T12:00:00+09:00", "hostname": "host-a"},
+		"retrying": {
+			"startAt":           "2025-01-31T12:00:00+09:00",
+			"hostname":          "host-a",
+			lastFailedReasonTag: string(entity.EncodeFailureReasonDiskFull),
+		},
+		"failed": {
+			failedAtTag:     "2025-01-31T12:00:00+09:00",
+			failedReasonTag: string(entity.EncodeFailureReasonUnsupportedCodec),
+			failedDetailTag: "Decoder (codec none) not found for input stream #0:0",
+		},
+	}}
+	u := &Usecase{tenants: map[string]Tenant{
+		entity.DefaultTenantID: {Tenant: entity.Tenant{ID: entity.DefaultTenantID}, SourceRepo: repo},
+	}}
+	ctx := context.Background()
+	startedAt := time.Date(2025, 1, 31, 3, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		mediaID string
+		want    entity.EncodeJob
+	}{
+		{mediaID: "pending", want: entity.EncodeJob{State: entity.EncodeJobStatePending}},
+		{mediaID: "running", want: entity.EncodeJob{State: entity.EncodeJobStateRunning, Hostname: "host-a", StartedAt: startedAt}},
+		{mediaID: "retrying", want: entity.EncodeJob{
+			State:            entity.EncodeJobStateRetrying,
+			Hostname:         "host-a",
+			StartedAt:        startedAt,
+			LastFailedReason: entity.EncodeFailureReasonDiskFull,
+		}},
+		{mediaID: "failed", want: entity.EncodeJob{
+			State:        entity.EncodeJobStateFailed,
+			FailedAt:     startedAt,
+			FailedReason: entity.EncodeFailureReasonUnsupportedCodec,
+			FailedDetail: "Decoder (codec none) not found for input stream #0:0",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mediaID, func(t *testing.T) {
+			job, err := u.GetEncodeJob(ctx, "", tt.mediaID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.mediaID, job.MediaID)
+			assert.Equal(t, entity.DefaultTenantID, job.TenantID)
+			assert.Equal(t, tt.want.State, job.State)
+			assert.Equal(t, tt.want.Hostname, job.Hostname)
+			assert.True(t, tt.want.StartedAt.Equal(job.StartedAt), "StartedAt = %v", job.StartedAt)
+			assert.Equal(t, tt.want.LastFailedReason, job.LastFailedReason)
+			assert.True(t, tt.want.FailedAt.Equal(job.FailedAt), "FailedAt = %v", job.FailedAt)
+			assert.Equal(t, tt.want.FailedReason, job.FailedReason)
+			assert.Equal(t, tt.want.FailedDetail, job.FailedDetail)
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := u.GetEncodeJob(ctx, "", "missing")
+		assert.ErrorIs(t, err, domain.ErrObjectNotFound)
+	})
+
+	t.Run("clear failure", func(t *testing.T) {
+		require.NoError(t, u.ClearEncodeFailure(ctx, "", "failed"))
+		job, err := u.GetEncodeJob(ctx, "", "failed")
+		require.NoError(t, err)
+		assert.Equal(t, entity.EncodeJobStatePending, job.State)
+
+		// claimを外さない
+		assert.ErrorIs(t, u.ClearEncodeFailure(ctx, "", "running"), domain.ErrEncodeNotFailed)
+		job, err = u.GetEncodeJob(ctx, "", "running")
+		require.NoError(t, err)
+		assert.Equal(t, entity.EncodeJobStateRunning, job.State)
+	})
+}
@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+const jobLogPollInterval = time.Second
+
+// finishJob archives the ffmpeg log of the job and releases its local files to the janitor.
+func (u *Usecase) finishJob(tenantID string, mediaID string) {
+	// シャットダウンでジョブのctxがキャンセルされていてもログは残す
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	if err := u.archiveJobLog(ctx, tenantID, mediaID); err != nil {
+		slog.Error("failed to archive ffmpeg log", slog.String("tenantID", tenantID), slog.String("mediaID", mediaID), slog.Any("error", err))
+		// returnしない
+	}
+	u.active.remove(tenantID, mediaID)
+}
+
+func (u *Usecase) archiveJobLog(ctx context.Context, tenantID string, mediaID string) error {
+	logFile, err := os.Open(u.encoder.LogFilePath(tenantID, mediaID))
+	if err != nil {
+		// ffmpegを実行する前に失敗した場合
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer logFile.Close()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := io.Copy(gz, logFile); err != nil {
+		return fmt.Errorf("failed to compress log file: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress log file: %w", err)
+	}
+
+	return u.jobLogRepo.PutJobLog(ctx, tenantID, mediaID, buf.Bytes())
+}
+
+// GetJobLog returns the ffmpeg log of the job.
+// While the job is running on this host, the local log is returned, and if follow is true, it is read until the job finishes.
+// While it is running on another host, it returns domain.ErrJobRunningOnOtherHost with the hostname.
+// Otherwise the archived log is returned.
+func (u *Usecase) GetJobLog(ctx context.Context, tenantID string, mediaID string, follow bool) (io.ReadCloser, error) {
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		return nil, err
+	}
+	tenant, err := u.tenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.active.has(tenantID, mediaID) {
+		return &jobLogTail{
+			ctx:  ctx,
+			path: u.encoder.LogFilePath(tenantID, mediaID),
+			running: func() bool {
+				return follow && u.active.has(tenantID, mediaID)
+			},
+		}, nil
+	}
+
+	job, err := u.GetEncodeJob(ctx, tenant.ID, mediaID)
+	if err != nil && !errors.Is(err, domain.ErrObjectNotFound) {
+		return nil, fmt.Errorf("failed to get encode job: %w", err)
+	}
+	// 再起動前に自分がclaimしたジョブは実行されていないので、アーカイブを返す
+	if err == nil && job.State == entity.EncodeJobStateRunning && job.Hostname != "" && job.Hostname != u.hostname {
+		return nil, fmt.Errorf("%w: %s", domain.ErrJobRunningOnOtherHost, job.Hostname)
+	}
+
+	archived, err := u.jobLogRepo.GetJobLog(ctx, tenantID, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(archived)
+	if err != nil {
+		archived.Close()
+		return nil, fmt.Errorf("failed to decompress job log: %w", err)
+	}
+	return &gzipReadCloser{Reader: gz, archived: archived}, nil
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	archived io.ReadCloser
+}
+
+func (g *gzipReadCloser) Close() error {
+	return errors.Join(g.Reader.Close(), g.archived.Close())
+}
+
+// jobLogTail reads the local log, waiting for ffmpeg to write more while running returns true.
+type jobLogTail struct {
+	ctx     context.Context
+	path    string
+	running func() bool
+	file    *os.File
+}
+
+func (t *jobLogTail) Read(p []byte) (int, error) {
+	for {
+		// 読む前に確認し、終了までに書かれたログを読み切ってからEOFを返す
+		running := t.running()
+
+		if t.file == nil {
+			f, err := os.Open(t.path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return 0, fmt.Errorf("failed to open log file: %w", err)
+			}
+			t.file = f
+		}
+		if t.file != nil {
+			n, err := t.file.Read(p)
+			if n > 0 || !errors.Is(err, io.EOF) {
+				return n, err
+			}
+		}
+		if !running {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-t.ctx.Done():
+			return 0, t.ctx.Err()
+		case <-time.After(jobLogPollInterval):
+		}
+	}
+}
+
+func (t *jobLogTail) Close() error {
+	if t.file == nil {
+		return nil
+	}
+	return t.file.Close()
+}
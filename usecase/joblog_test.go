@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+type logDirEncoder struct {
+	Encoder
+	dir string
+}
+
+func (e logDirEncoder) LogFilePath(tenantID string, mediaID string) string {
+	return filepath.Join(e.dir, tenantID, mediaID+".log")
+}
+
+type emptyJobLogRepository struct{}
+
+func (emptyJobLogRepository) PutJobLog(context.Context, string, string, []byte) error {
+	return nil
+}
+
+func (emptyJobLogRepository) GetJobLog(context.Context, string, string) (io.ReadCloser, error) {
+	return nil, domain.ErrObjectNotFound
+}
+
+func TestUsecase_GetJobLog(t *testing.T) {
+	encoder := logDirEncoder{dir: t.TempDir()}
+	sourceA := &tagSourceRepository{tags: map[string]map[string]string{
+		"movie-1": {"startAt": "2025-01-31T12:00:00+09:00", "hostname": "host-a"},
+		// 再起動前にこのホストがclaimしたジョブ
+		"movie-2": {"startAt": "2025-01-31T12:00:00+09:00", "hostname": "host-a"},
+		"movie-3": {"startAt": "2025-01-31T12:00:00+09:00", "hostname": "host-b"},
+	}}
+	sourceB := &tagSourceRepository{tags: map[string]map[string]string{}}
+	u := &Usecase{
+		tenants: map[string]Tenant{
+			"team-a": {Tenant: entity.Tenant{ID: "team-a"}, SourceRepo: sourceA},
+			"team-b": {Tenant: entity.Tenant{ID: "team-b"}, SourceRepo: sourceB},
+		},
+		encoder:    encoder,
+		jobLogRepo: emptyJobLogRepository{},
+		active:     newActiveJobs(),
+		hostname:   "host-a",
+	}
+	path := encoder.LogFilePath("team-a", "movie-1")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("frame=1\n"), 0o644))
+	u.active.add("team-a", "movie-1")
+
+	jobLog, err := u.GetJobLog(context.Background(), "team-a", "movie-1", false)
+	require.NoError(t, err)
+	b, err := io.ReadAll(jobLog)
+	jobLog.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "frame=1\n", string(b))
+
+	// 同じメディアIDでも他のテナントのログは返さない
+	_, err = u.GetJobLog(context.Background(), "team-b", "movie-1", false)
+	assert.ErrorIs(t, err, domain.ErrObjectNotFound)
+
+	t.Run("running on another host", func(t *testing.T) {
+		_, err := u.GetJobLog(context.Background(), "team-a", "movie-3", true)
+		assert.ErrorIs(t, err, domain.ErrJobRunningOnOtherHost)
+		assert.ErrorContains(t, err, "host-b")
+	})
+
+	t.Run("claimed by this host before restart", func(t *testing.T) {
+		_, err := u.GetJobLog(context.Background(), "team-a", "movie-2", true)
+		assert.ErrorIs(t, err, domain.ErrObjectNotFound)
+	})
+}
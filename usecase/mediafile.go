@@ -2,9 +2,98 @@ package usecase
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net/url"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/mpd"
 )
 
-func (u *Usecase) GetMediaFile(ctx context.Context, mediaID string, fileName string) (io.ReadSeekCloser, error) {
-	return u.encodedRepo.GetObject(ctx, mediaID, fileName)
+func validateMediaFilePath(mediaID string, fileName string) error {
+	if err := entity.ValidateMediaID(mediaID); err != nil {
+		return err
+	}
+	return entity.ValidateMediaFileName(fileName)
+}
+
+// CanAccessMedia reports whether the token grants access to mediaID, resolving groups server-side.
+func (u *Usecase) CanAccessMedia(userToken entity.UserToken, mediaID string) bool {
+	return userToken.CanAccessMedia(mediaID, u.mediaGroups)
+}
+
+func (u *Usecase) GetMediaFile(ctx context.Context, tenantID string, mediaID string, fileName string) (io.ReadSeekCloser, error) {
+	if err := validateMediaFilePath(mediaID, fileName); err != nil {
+		return nil, err
+	}
+	tenant, err := u.tenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := tenant.EncodedRepo.GetObject(ctx, mediaID, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &servedBytesCounter{
+		ReadSeekCloser: file,
+		onClose: func(n int64) {
+			u.recordUsage(tenant.ID, mediaID, entity.UsageRecord{ServedBytes: n})
+		},
+	}, nil
+}
+
+func (u *Usecase) GetMediaFileURL(ctx context.Context, tenantID string, mediaID string, fileName string) (*url.URL, error) {
+	if err := validateMediaFilePath(mediaID, fileName); err != nil {
+		return nil, err
+	}
+	tenant, err := u.tenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return tenant.EncodedRepo.PresignedGetObject(ctx, mediaID, fileName, u.presignExpiry)
+}
+
+// GetMediaManifest returns the manifest filtered by the rendition constraints, with query appended to every segment URL.
+func (u *Usecase) GetMediaManifest(
+	ctx context.Context,
+	tenantID string,
+	mediaID string,
+	fileName string,
+	query url.Values,
+	renditions entity.RenditionConstraints,
+) ([]byte, error) {
+	if err := validateMediaFilePath(mediaID, fileName); err != nil {
+		return nil, err
+	}
+	tenant, err := u.tenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := tenant.EncodedRepo.GetObject(ctx, mediaID, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer file.Close()
+
+	manifest, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if !renditions.IsZero() {
+		manifest, err = mpd.FilterRepresentations(manifest, func(r mpd.Representation) bool {
+			return allowsRepresentation(renditions, r)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter manifest: %w", err)
+		}
+	}
+
+	manifest, err = mpd.AppendQuery(manifest, query)
+	if err != nil {
+		return nil, err
+	}
+	u.recordUsage(tenant.ID, mediaID, entity.UsageRecord{ServedBytes: int64(len(manifest))})
+	return manifest, nil
 }
@@ -0,0 +1,171 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/mpd"
+)
+
+const (
+	representationCacheTTL     = 5 * time.Minute
+	representationCacheMaxSize = 1024
+)
+
+type representationCacheEntry struct {
+	representations []mpd.Representation
+	expiresAt       time.Time
+}
+
+type representationCacheKey struct {
+	tenantID string
+	mediaID  string
+}
+
+// representationCache caches the representations of each media, to check segment requests without reading the manifest every time.
+type representationCache struct {
+	mu      sync.Mutex
+	entries map[representationCacheKey]representationCacheEntry
+}
+
+func newRepresentationCache() *representationCache {
+	return &representationCache{
+		entries: make(map[representationCacheKey]representationCacheEntry),
+	}
+}
+
+func (c *representationCache) get(key representationCacheKey) ([]mpd.Representation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.representations, true
+}
+
+func (c *representationCache) set(key representationCacheKey, representations []mpd.Representation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= representationCacheMaxSize {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= representationCacheMaxSize {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = representationCacheEntry{
+		representations: representations,
+		expiresAt:       now.Add(representationCacheTTL),
+	}
+}
+
+func (c *representationCache) remove(key representationCacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+func allowsRepresentation(constraints entity.RenditionConstraints, r mpd.Representation) bool {
+	if constraints.AudioOnly && r.IsVideo() {
+		return false
+	}
+	if constraints.MaxHeight > 0 && r.IsVideo() && r.Height > constraints.MaxHeight {
+		return false
+	}
+	if constraints.MaxBandwidth > 0 && r.Bandwidth > constraints.MaxBandwidth {
+		return false
+	}
+	return true
+}
+
+// getRepresentations returns the representations of the media, and whether they are read from the cache.
+func (u *Usecase) getRepresentations(ctx context.Context, tenant Tenant, mediaID string) ([]mpd.Representation, bool, error) {
+	if representations, ok := u.representations.get(representationCacheKey{tenantID: tenant.ID, mediaID: mediaID}); ok {
+		return representations, true, nil
+	}
+	representations, err := u.loadRepresentations(ctx, tenant, mediaID)
+	return representations, false, err
+}
+
+func (u *Usecase) loadRepresentations(ctx context.Context, tenant Tenant, mediaID string) ([]mpd.Representation, error) {
+	file, err := tenant.EncodedRepo.GetObject(ctx, mediaID, mpd.FileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer file.Close()
+
+	manifest, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	representations, err := mpd.Representations(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	u.representations.set(representationCacheKey{tenantID: tenant.ID, mediaID: mediaID}, representations)
+	return representations, nil
+}
+
+// invalidateRepresentations drops the cached representations after the media is re-encoded.
+func (u *Usecase) invalidateRepresentations(tenantID string, mediaID string) {
+	u.representations.remove(representationCacheKey{tenantID: tenantID, mediaID: mediaID})
+}
+
+func matchRepresentation(representations []mpd.Representation, fileName string) (mpd.Representation, bool) {
+	for _, r := range representations {
+		if r.MatchSegment(fileName) {
+			return r, true
+		}
+	}
+	return mpd.Representation{}, false
+}
+
+// CanAccessMediaFile reports whether the token's rendition constraints allow the file.
+// Access to the media itself must be checked with CanAccessMedia beforehand.
+func (u *Usecase) CanAccessMediaFile(ctx context.Context, userToken entity.UserToken, mediaID string, fileName string) (bool, error) {
+	if userToken.Renditions.IsZero() {
+		return true, nil
+	}
+	if err := validateMediaFilePath(mediaID, fileName); err != nil {
+		return false, err
+	}
+	// マニフェストは配信時にフィルタする
+	if path.Ext(fileName) == mpd.Ext {
+		return true, nil
+	}
+
+	tenant, err := u.tenant(userToken.TenantID)
+	if err != nil {
+		return false, err
+	}
+	representations, cached, err := u.getRepresentations(ctx, tenant, mediaID)
+	if err != nil {
+		return false, err
+	}
+	r, ok := matchRepresentation(representations, fileName)
+	if !ok && cached {
+		// 他のPodで再エンコードされ、セグメント名が変わっている可能性があるので読み直す
+		representations, err = u.loadRepresentations(ctx, tenant, mediaID)
+		if err != nil {
+			return false, err
+		}
+		r, ok = matchRepresentation(representations, fileName)
+	}
+	if !ok {
+		// どのRepresentationにも属さないファイルは制限付きトークンでは許可しない
+		return false, nil
+	}
+	return allowsRepresentation(userToken.Renditions, r), nil
+}
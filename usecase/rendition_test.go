@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/mpd"
+)
+
+type dirEncodedRepository struct {
+	EncodedObjectRepository
+	dir   string
+	reads int
+}
+
+func (r *dirEncodedRepository) GetObject(ctx context.Context, mediaID string, fileName string) (io.ReadSeekCloser, error) {
+	r.reads++
+	return os.Open(filepath.Join(r.dir, mediaID, fileName))
+}
+
+func writeManifest(t *testing.T, dir string, mediaID string, segmentTemplate string) {
+	t.Helper()
+	manifest := `<MPD><Period><AdaptationSet mimeType="video/mp4"><SegmentTemplate media="` + segmentTemplate + `"/>` +
+		`<Representation id="0" height="1080" bandwidth="5000000"/>` +
+		`<Representation id="1" height="480" bandwidth="1000000"/>` +
+		`</AdaptationSet></Period></MPD>`
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, mediaID), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, mediaID, mpd.FileName), []byte(manifest), 0o644))
+}
+
+func TestUsecase_CanAccessMediaFile(t *testing.T) {
+	ctx := context.Background()
+	repo := &dirEncodedRepository{dir: t.TempDir()}
+	writeManifest(t, repo.dir, "movie-1", "chunk-$RepresentationID$-$Number%05d$.m4s")
+	u := &Usecase{
+		tenants:         map[string]Tenant{entity.DefaultTenantID: {Tenant: entity.Tenant{ID: entity.DefaultTenantID}, EncodedRepo: repo}},
+		representations: newRepresentationCache(),
+	}
+	userToken := entity.UserToken{
+		TenantID:   entity.DefaultTenantID,
+		MediaIDs:   []string{"movie-1"},
+		Renditions: entity.RenditionConstraints{MaxHeight: 720},
+	}
+
+	t.Run("without constraints", func(t *testing.T) {
+		ok, err := u.CanAccessMediaFile(ctx, entity.UserToken{MediaIDs: []string{"movie-1"}}, "movie-1", "chunk-0-00001.m4s")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("manifest is filtered on delivery", func(t *testing.T) {
+		ok, err := u.CanAccessMediaFile(ctx, userToken, "movie-1", mpd.FileName)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("allowed rendition", func(t *testing.T) {
+		ok, err := u.CanAccessMediaFile(ctx, userToken, "movie-1", "chunk-1-00001.m4s")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("denied rendition", func(t *testing.T) {
+		ok, err := u.CanAccessMediaFile(ctx, userToken, "movie-1", "chunk-0-00001.m4s")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("representations are cached", func(t *testing.T) {
+		repo.reads = 0
+		_, err := u.CanAccessMediaFile(ctx, userToken, "movie-1", "chunk-1-00002.m4s")
+		require.NoError(t, err)
+		assert.Equal(t, 0, repo.reads)
+	})
+
+	t.Run("re-read the manifest re-encoded on another host", func(t *testing.T) {
+		writeManifest(t, repo.dir, "movie-1", "seg-$RepresentationID$-$Number%05d$.m4s")
+		repo.reads = 0
+		ok, err := u.CanAccessMediaFile(ctx, userToken, "movie-1", "seg-1-00001.m4s")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, repo.reads)
+
+		ok, err = u.CanAccessMediaFile(ctx, userToken, "movie-1", "seg-0-00001.m4s")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 1, repo.reads)
+	})
+
+	t.Run("unknown file", func(t *testing.T) {
+		repo.reads = 0
+		ok, err := u.CanAccessMediaFile(ctx, userToken, "movie-1", "other.m4s")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 1, repo.reads)
+	})
+
+	t.Run("invalidated after re-encode", func(t *testing.T) {
+		u.invalidateRepresentations(entity.DefaultTenantID, "movie-1")
+		repo.reads = 0
+		ok, err := u.CanAccessMediaFile(ctx, userToken, "movie-1", "seg-1-00002.m4s")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, repo.reads)
+	})
+}
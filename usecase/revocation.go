@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// revocationKey identifies a token ID or a subject in a tenant.
+type revocationKey struct {
+	tenantID string
+	value    string
+}
+
+func newRevocationKey(tenantID string, value string) revocationKey {
+	if tenantID == "" {
+		tenantID = entity.DefaultTenantID
+	}
+	return revocationKey{tenantID: tenantID, value: value}
+}
+
+type revocationCache struct {
+	mu       sync.RWMutex
+	tokenIDs map[revocationKey]struct{}
+	subjects map[revocationKey]time.Time // subject -> revokedAt
+}
+
+func newRevocationCache() *revocationCache {
+	return &revocationCache{
+		tokenIDs: make(map[revocationKey]struct{}),
+		subjects: make(map[revocationKey]time.Time),
+	}
+}
+
+func (c *revocationCache) add(revocation entity.TokenRevocation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addLocked(revocation)
+}
+
+func (c *revocationCache) addLocked(revocation entity.TokenRevocation) {
+	if revocation.TokenID != "" {
+		c.tokenIDs[newRevocationKey(revocation.TenantID, revocation.TokenID)] = struct{}{}
+	}
+	if revocation.Subject != "" {
+		key := newRevocationKey(revocation.TenantID, revocation.Subject)
+		if revokedAt, ok := c.subjects[key]; !ok || revocation.RevokedAt.After(revokedAt) {
+			c.subjects[key] = revocation.RevokedAt
+		}
+	}
+}
+
+func (c *revocationCache) replace(revocations []entity.TokenRevocation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokenIDs = make(map[revocationKey]struct{}, len(revocations))
+	c.subjects = make(map[revocationKey]time.Time)
+	for _, revocation := range revocations {
+		c.addLocked(revocation)
+	}
+}
+
+func (c *revocationCache) isRevoked(userToken entity.UserToken) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.tokenIDs[newRevocationKey(userToken.TenantID, userToken.ID)]; ok && userToken.ID != "" {
+		return true
+	}
+	if revokedAt, ok := c.subjects[newRevocationKey(userToken.TenantID, userToken.Subject)]; ok && userToken.Subject != "" {
+		return !userToken.IssuedAt.After(revokedAt)
+	}
+	return false
+}
+
+// RevokeUserToken revokes a token, or all tokens of the subject issued so far in the tenant.
+func (u *Usecase) RevokeUserToken(ctx context.Context, tenantID string, tokenID string, subject string) error {
+	if tokenID == "" && subject == "" {
+		return errors.New("token id or subject is required")
+	}
+	tenant, err := u.tenant(tenantID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	// トークンの有効期限は分からないので、外部の発行元も含めた最大TTL + 許容誤差だけ保持する
+	expiresAt := now.Add(u.revocationRetention())
+
+	for _, revocation := range []entity.TokenRevocation{
+		{TenantID: tenant.ID, TokenID: tokenID, RevokedAt: now, ExpiresAt: expiresAt},
+		{TenantID: tenant.ID, Subject: subject, RevokedAt: now, ExpiresAt: expiresAt},
+	} {
+		if revocation.TokenID == "" && revocation.Subject == "" {
+			continue
+		}
+		if err := u.revocationRepo.AddRevocation(ctx, revocation); err != nil {
+			return fmt.Errorf("failed to add revocation: %w", err)
+		}
+		u.revocations.add(revocation)
+	}
+	return nil
+}
+
+func (u *Usecase) revocationRetention() time.Duration {
+	maxTTL := u.userTokenConfig.MaxTTL
+	if u.externalTokenVerifier != nil {
+		maxTTL = max(maxTTL, u.externalTokenVerifier.MaxTTL())
+	}
+	return maxTTL + u.userTokenConfig.Leeway
+}
+
+func (u *Usecase) refreshRevocations(ctx context.Context) error {
+	now := time.Now()
+	revocations := make([]entity.TokenRevocation, 0)
+	for revocation, err := range u.revocationRepo.ListRevocations(ctx) {
+		if err != nil {
+			return fmt.Errorf("failed to list revocations: %w", err)
+		}
+
+		if now.After(revocation.ExpiresAt) {
+			if err := u.revocationRepo.RemoveRevocation(ctx, revocation); err != nil {
+				slog.Warn("failed to remove expired revocation", slog.Any("error", err))
+				// returnしない
+			}
+			continue
+		}
+		revocations = append(revocations, revocation)
+	}
+
+	u.revocations.replace(revocations)
+	slog.Debug("revocations refreshed", slog.Int("count", len(revocations)))
+	return nil
+}
+
+func (u *Usecase) runRevocationRefresher(ctx context.Context) {
+	refresh := func() {
+		if err := u.refreshRevocations(ctx); err != nil {
+			slog.Error("failed to refresh revocations", slog.Any("error", err))
+		}
+	}
+	refresh()
+
+	ticker := time.NewTicker(u.userTokenConfig.RevocationRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			refresh()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
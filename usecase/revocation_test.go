@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+type maxTTLVerifier struct {
+	ExternalTokenVerifier
+	maxTTL time.Duration
+}
+
+func (v maxTTLVerifier) MaxTTL() time.Duration {
+	return v.maxTTL
+}
+
+type recordRevocationRepository struct {
+	RevocationRepository
+	revocations []entity.TokenRevocation
+}
+
+func (r *recordRevocationRepository) AddRevocation(_ context.Context, revocation entity.TokenRevocation) error {
+	r.revocations = append(r.revocations, revocation)
+	return nil
+}
+
+func TestUsecase_RevokeUserToken_ExpiresAt(t *testing.T) {
+	tests := []struct {
+		name        string
+		externalTTL time.Duration
+		want        time.Duration
+	}{
+		{
+			name:        "user token max ttl",
+			externalTTL: time.Hour,
+			want:        24*time.Hour + 30*time.Second,
+		},
+		{
+			name:        "external issuer max ttl",
+			externalTTL: 720 * time.Hour,
+			want:        720*time.Hour + 30*time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &recordRevocationRepository{}
+			u := &Usecase{
+				tenants:               map[string]Tenant{entity.DefaultTenantID: {Tenant: entity.Tenant{ID: entity.DefaultTenantID}}},
+				externalTokenVerifier: maxTTLVerifier{maxTTL: tt.externalTTL},
+				revocationRepo:        repo,
+				revocations:           newRevocationCache(),
+				userTokenConfig: config.UserTokenConfig{
+					MaxTTL: 24 * time.Hour,
+					Leeway: 30 * time.Second,
+				},
+			}
+
+			require.NoError(t, u.RevokeUserToken(context.Background(), "", "token-1", "user-1"))
+			require.Len(t, repo.revocations, 2)
+			for _, revocation := range repo.revocations {
+				assert.Equal(t, tt.want, revocation.ExpiresAt.Sub(revocation.RevokedAt))
+			}
+		})
+	}
+}
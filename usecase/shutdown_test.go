@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsecase_shutdown(t *testing.T) {
+	t.Run("waits for running jobs", func(t *testing.T) {
+		u := &Usecase{shutdownTimeout: time.Minute}
+		workerDone := make(chan struct{})
+		close(workerDone)
+		jobCtx, cancelJobs := context.WithCancel(context.Background())
+		defer cancelJobs()
+
+		finished := make(chan struct{})
+		u.jobs.Add(1)
+		go func() {
+			defer u.jobs.Done()
+			time.Sleep(50 * time.Millisecond)
+			close(finished)
+		}()
+
+		u.shutdown(workerDone, cancelJobs)
+		assert.NoError(t, jobCtx.Err(), "jobs finished in time must not be canceled")
+		select {
+		case <-finished:
+		default:
+			t.Error("shutdown returned before the job finished")
+		}
+	})
+
+	t.Run("cancels jobs after the timeout", func(t *testing.T) {
+		u := &Usecase{shutdownTimeout: 50 * time.Millisecond}
+		workerDone := make(chan struct{})
+		jobCtx, cancelJobs := context.WithCancel(context.Background())
+		defer cancelJobs()
+
+		u.jobs.Add(1)
+		go func() {
+			defer u.jobs.Done()
+			<-jobCtx.Done()
+		}()
+		go func() {
+			defer close(workerDone)
+			<-jobCtx.Done()
+		}()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			u.shutdown(workerDone, cancelJobs)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("shutdown did not return after the timeout")
+		}
+		assert.ErrorIs(t, jobCtx.Err(), context.Canceled)
+	})
+}
@@ -0,0 +1,29 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+// Tenant is a tenant with its own buckets, signing keys and admin keys.
+type Tenant struct {
+	entity.Tenant
+	TokenIssuer   TokenIssuer
+	AdminKeyStore AdminKeyStore
+	SourceRepo    SourceRepository
+	EncodedRepo   EncodedObjectRepository
+}
+
+// tenant returns the tenant. An empty ID means the default tenant.
+func (u *Usecase) tenant(tenantID string) (Tenant, error) {
+	if tenantID == "" {
+		tenantID = entity.DefaultTenantID
+	}
+	t, ok := u.tenants[tenantID]
+	if !ok {
+		return Tenant{}, fmt.Errorf("%w: %s", domain.ErrUnknownTenant, tenantID)
+	}
+	return t, nil
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/tracer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startJobSpan starts the root span of an encode job.
+// If the source was uploaded with the W3C trace context in the object metadata (x-amz-meta-traceparent),
+// the job is linked to the trace of the request which uploaded it.
+func startJobSpan(tenantID string, source entity.SourceFile, start time.Time) (context.Context, trace.Span) {
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attribute.String("tenant.id", tenantID),
+			attribute.String("media.id", source.ID),
+		),
+	}
+
+	carrier := propagation.MapCarrier{}
+	for _, key := range (propagation.TraceContext{}).Fields() {
+		if v, ok := source.Metadata[key]; ok {
+			carrier.Set(key, v)
+		}
+	}
+	if sc := trace.SpanContextFromContext((propagation.TraceContext{}).Extract(context.Background(), carrier)); sc.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+	}
+
+	return tracer.Tracer.Start(context.Background(), "encode job", opts...)
+}
+
+// endSpan ends the span, marking it as failed if err is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
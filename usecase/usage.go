@@ -0,0 +1,329 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Code-Hex/synchro"
+	"github.com/Code-Hex/synchro/tz"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+)
+
+type UsageGroupBy string
+
+const (
+	UsageGroupByMedia  UsageGroupBy = "media"
+	UsageGroupByTenant UsageGroupBy = "tenant"
+)
+
+type usageKey struct {
+	date     string
+	tenantID string
+	mediaID  string
+}
+
+func usageKeyOf(record entity.UsageRecord) usageKey {
+	return usageKey{date: record.Date, tenantID: record.TenantID, mediaID: record.MediaID}
+}
+
+func usageDate(t time.Time) string {
+	return synchro.In[tz.AsiaTokyo](t).Format(entity.UsageDateLayout)
+}
+
+// usageRecorder keeps the usage not yet written to the repository, and the tenant usage for quotas.
+type usageRecorder struct {
+	mu      sync.Mutex
+	pending map[usageKey]entity.UsageRecord
+	// リポジトリに書き込み済みの今月のエンコード時間と、refresh時のバケットの使用量
+	totals map[string]entity.TenantUsage
+	month  string // totalsのEncodeMinutesを集計した月 (e.g. "2025-01")
+	// refresh以降にアップロードしたメディアのサイズ。再アップロードでは置き換える
+	uploaded map[usageMediaKey]uploadedSize
+
+	// refreshとflushが同時にtotalsを更新しないようにする
+	syncMu sync.Mutex
+}
+
+type usageMediaKey struct {
+	tenantID string
+	mediaID  string
+}
+
+type uploadedSize struct {
+	bytes int64
+	at    time.Time
+}
+
+func newUsageRecorder() *usageRecorder {
+	return &usageRecorder{
+		pending:  make(map[usageKey]entity.UsageRecord),
+		totals:   make(map[string]entity.TenantUsage),
+		uploaded: make(map[usageMediaKey]uploadedSize),
+	}
+}
+
+func (r *usageRecorder) add(delta entity.UsageRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addLocked(delta)
+	if delta.StoredBytes > 0 {
+		r.uploaded[usageMediaKey{tenantID: delta.TenantID, mediaID: delta.MediaID}] = uploadedSize{bytes: delta.StoredBytes, at: time.Now()}
+	}
+}
+
+func (r *usageRecorder) addLocked(delta entity.UsageRecord) {
+	key := usageKeyOf(delta)
+	record, ok := r.pending[key]
+	if !ok {
+		record = entity.UsageRecord{Date: delta.Date, TenantID: delta.TenantID, MediaID: delta.MediaID}
+	}
+	record.Add(delta)
+	r.pending[key] = record
+}
+
+// takePending returns the pending records grouped by date, and clears them.
+func (r *usageRecorder) takePending() map[string][]entity.UsageRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byDate := make(map[string][]entity.UsageRecord)
+	for key, record := range r.pending {
+		byDate[key.date] = append(byDate[key.date], record)
+	}
+	clear(r.pending)
+	return byDate
+}
+
+// flushed moves the written records from pending to totals. On failure, the records are put back to pending.
+func (r *usageRecorder) flushed(records []entity.UsageRecord, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, record := range records {
+		if !ok {
+			r.addLocked(record)
+			continue
+		}
+		r.totals[record.TenantID] = addTenantUsage(r.totals[record.TenantID], record, r.month)
+	}
+}
+
+// replaceTotals replaces the totals with the ones calculated at refreshedAt.
+// The uploads recorded before refreshedAt are included in the stored bytes of the totals.
+func (r *usageRecorder) replaceTotals(totals map[string]entity.TenantUsage, month string, refreshedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totals = totals
+	r.month = month
+	maps.DeleteFunc(r.uploaded, func(_ usageMediaKey, size uploadedSize) bool {
+		return size.at.Before(refreshedAt)
+	})
+}
+
+func (r *usageRecorder) tenantUsage(tenantID string) entity.TenantUsage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	usage := r.totals[tenantID]
+	for key, record := range r.pending {
+		if key.tenantID == tenantID {
+			usage = addTenantUsage(usage, record, r.month)
+		}
+	}
+	// 置き換え前のサイズはrefreshまで二重に数える
+	for key, size := range r.uploaded {
+		if key.tenantID == tenantID {
+			usage.StoredBytes += size.bytes
+		}
+	}
+	return usage
+}
+
+// addTenantUsage adds the encode minutes of the month. The stored bytes are taken from the bucket instead of the records.
+func addTenantUsage(usage entity.TenantUsage, record entity.UsageRecord, month string) entity.TenantUsage {
+	if strings.HasPrefix(record.Date, month) {
+		usage.EncodeMinutes += record.EncodeMinutes
+	}
+	return usage
+}
+
+func (u *Usecase) recordUsage(tenantID string, mediaID string, delta entity.UsageRecord) {
+	delta.Date = usageDate(time.Now())
+	delta.TenantID = tenantID
+	delta.MediaID = mediaID
+	u.usage.add(delta)
+}
+
+// flushUsage adds the pending records to the records of this host in the repository.
+func (u *Usecase) flushUsage(ctx context.Context) error {
+	u.usage.syncMu.Lock()
+	defer u.usage.syncMu.Unlock()
+	return u.flushUsageLocked(ctx)
+}
+
+func (u *Usecase) flushUsageLocked(ctx context.Context) error {
+	var errs []error
+	for date, records := range u.usage.takePending() {
+		if err := u.putUsage(ctx, date, records); err != nil {
+			u.usage.flushed(records, false)
+			errs = append(errs, fmt.Errorf("failed to put usage of %s: %w", date, err))
+			continue
+		}
+		u.usage.flushed(records, true)
+	}
+	return errors.Join(errs...)
+}
+
+func (u *Usecase) putUsage(ctx context.Context, date string, deltas []entity.UsageRecord) error {
+	stored, err := u.usageRepo.GetUsage(ctx, date, u.hostname)
+	if err != nil {
+		return err
+	}
+
+	merged := make(map[usageKey]entity.UsageRecord, len(stored)+len(deltas))
+	for _, record := range slices.Concat(stored, deltas) {
+		key := usageKeyOf(record)
+		m, ok := merged[key]
+		if !ok {
+			m = entity.UsageRecord{Date: record.Date, TenantID: record.TenantID, MediaID: record.MediaID}
+		}
+		m.Add(record)
+		merged[key] = m
+	}
+	return u.usageRepo.PutUsage(ctx, date, u.hostname, sortUsageRecords(slices.Collect(maps.Values(merged))))
+}
+
+// refreshUsage recalculates the tenant usage for quotas, the encode minutes of this month from the repository
+// and the stored bytes from the buckets.
+func (u *Usecase) refreshUsage(ctx context.Context) error {
+	u.usage.syncMu.Lock()
+	defer u.usage.syncMu.Unlock()
+
+	// 取り出し済みで書き込み前の記録を数え漏らさないよう、先に書き込む
+	if err := u.flushUsageLocked(ctx); err != nil {
+		return fmt.Errorf("failed to flush usage: %w", err)
+	}
+
+	refreshedAt := time.Now()
+	month := synchro.In[tz.AsiaTokyo](refreshedAt).Format("2006-01")
+	totals := make(map[string]entity.TenantUsage)
+	for record, err := range u.usageRepo.ListUsage(ctx, month+"-01", "") {
+		if err != nil {
+			return fmt.Errorf("failed to list usage: %w", err)
+		}
+		totals[record.TenantID] = addTenantUsage(totals[record.TenantID], record, month)
+	}
+
+	for _, id := range u.tenantIDs {
+		size, err := u.tenants[id].EncodedRepo.TotalSize(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get stored size of tenant %s: %w", id, err)
+		}
+		usage := totals[id]
+		usage.StoredBytes = size
+		totals[id] = usage
+	}
+	u.usage.replaceTotals(totals, month, refreshedAt)
+	return nil
+}
+
+func (u *Usecase) runUsageRecorder(ctx context.Context) {
+	if err := u.refreshUsage(ctx); err != nil {
+		slog.Error("failed to refresh usage", slog.Any("error", err))
+	}
+
+	flushTicker := time.NewTicker(u.usageConfig.FlushInterval)
+	defer flushTicker.Stop()
+	refreshTicker := time.NewTicker(u.usageConfig.RefreshInterval)
+	defer refreshTicker.Stop()
+
+	for {
+		select {
+		case <-flushTicker.C:
+			if err := u.flushUsage(ctx); err != nil {
+				slog.Error("failed to flush usage", slog.Any("error", err))
+			}
+		case <-refreshTicker.C:
+			if err := u.refreshUsage(ctx); err != nil {
+				slog.Error("failed to refresh usage", slog.Any("error", err))
+			}
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+			defer cancel()
+			if err := u.flushUsage(ctx); err != nil {
+				slog.Error("failed to flush usage", slog.Any("error", err))
+			}
+			return
+		}
+	}
+}
+
+// quotaExceeded reports whether new encodes of the tenant should be rejected.
+func (u *Usecase) quotaExceeded(tenant Tenant) bool {
+	if !u.usageConfig.EnforceQuota {
+		return false
+	}
+	return u.usage.tenantUsage(tenant.ID).Exceeds(tenant.Quota)
+}
+
+// GetUsageReport aggregates the usage of all hosts from the day `from` to the day `to` (e.g. "2025-01-31").
+// An empty tenantID means all tenants.
+func (u *Usecase) GetUsageReport(ctx context.Context, tenantID string, from string, to string, groupBy UsageGroupBy) ([]entity.UsageRecord, error) {
+	aggregated := make(map[usageKey]entity.UsageRecord)
+	for record, err := range u.usageRepo.ListUsage(ctx, from, to) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list usage: %w", err)
+		}
+		if tenantID != "" && record.TenantID != tenantID {
+			continue
+		}
+		if groupBy == UsageGroupByTenant {
+			record.MediaID = ""
+		}
+
+		key := usageKeyOf(record)
+		a, ok := aggregated[key]
+		if !ok {
+			a = entity.UsageRecord{Date: record.Date, TenantID: record.TenantID, MediaID: record.MediaID}
+		}
+		a.Add(record)
+		aggregated[key] = a
+	}
+	return sortUsageRecords(slices.Collect(maps.Values(aggregated))), nil
+}
+
+func sortUsageRecords(records []entity.UsageRecord) []entity.UsageRecord {
+	slices.SortFunc(records, func(a, b entity.UsageRecord) int {
+		return cmp.Or(
+			cmp.Compare(a.Date, b.Date),
+			cmp.Compare(a.TenantID, b.TenantID),
+			cmp.Compare(a.MediaID, b.MediaID),
+		)
+	})
+	return records
+}
+
+// servedBytesCounter records the bytes read from the media file as served bytes on Close.
+type servedBytesCounter struct {
+	io.ReadSeekCloser
+	n       int64
+	onClose func(n int64)
+}
+
+func (c *servedBytesCounter) Read(p []byte) (int, error) {
+	n, err := c.ReadSeekCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *servedBytesCounter) Close() error {
+	c.onClose(c.n)
+	return c.ReadSeekCloser.Close()
+}
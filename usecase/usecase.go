@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"iter"
+	"math"
+	"net/url"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
@@ -13,46 +16,145 @@ import (
 )
 
 type Usecase struct {
-	tokenIssuer TokenIssuer
-	encoder     Encoder
-	sourceRepo  SourceRepository
-	encodedRepo EncodedObjectRepository
-
-	encodeQueue   chan encodeRequest
-	encodeTimeout time.Duration
-	hostname      string
+	tenants   map[string]Tenant
+	tenantIDs []string // 設定順
+	// 次にアップロードされたファイルを探すテナント
+	nextTenant int
+	// 外部で発行されたトークンの検証
+	externalTokenVerifier ExternalTokenVerifier
+	adminTokenVerifier    AdminTokenVerifier
+	encoder               Encoder
+
+	revocationRepo RevocationRepository
+	revocations    *revocationCache
+
+	usageRepo   UsageRepository
+	usage       *usageRecorder
+	usageConfig config.UsageConfig
+
+	jobLogRepo JobLogRepository
+
+	mediaGroups     entity.MediaGroups
+	representations *representationCache
+
+	encodeQueue chan encodeRequest
+	// 実行中のエンコードとアップロード
+	jobs            sync.WaitGroup
+	shutdownTimeout time.Duration
+	readinessConfig config.ReadinessConfig
+	sourceConfig    config.SourceConfig
+	// MaxUploadSizeを含む
+	sourceConstraints entity.SourceConstraints
+	janitorConfig     config.JanitorConfig
+	active            *activeJobs
+	encodeTimeout     time.Duration
+	presignExpiry     time.Duration
+	userTokenConfig   config.UserTokenConfig
+	hostname          string
+}
+
+type TokenVerifier interface {
+	ParseUserToken(ctx context.Context, token string) (entity.UserToken, error)
+}
+
+type ExternalTokenVerifier interface {
+	TokenVerifier
+	// MaxTTL is the longest remaining lifetime of the accepted tokens.
+	MaxTTL() time.Duration
 }
 
 type TokenIssuer interface {
-	CreateUserToken(mediaIDs []string) (string, error)
-	GetMediaIDsFromToken(token string) ([]string, error)
+	TokenVerifier
+	CreateUserToken(userToken entity.UserToken) (string, error)
+	JWKS() (entity.JSONWebKeySet, error)
+	SignIntegrityManifest(digest string) (signature string, kid string, err error)
+	VerifyIntegrityManifest(signature string, kid string, digest string) error
+}
+
+type AdminKeyStore interface {
+	Authenticate(key string) (entity.AdminPrincipal, bool)
+}
+
+type AdminTokenVerifier interface {
+	VerifyAdminToken(ctx context.Context, token string) (entity.AdminPrincipal, error)
 }
 
 type SourceRepository interface {
+	Ping(ctx context.Context) error
 	ListUploadedFiles(ctx context.Context) iter.Seq2[entity.SourceFile, error]
+	// GetObjectTags returns domain.ErrObjectNotFound if the source does not exist.
+	GetObjectTags(ctx context.Context, id string) (map[string]string, error)
 	SetObjectTags(ctx context.Context, id string, tags map[string]string) error
 	RemoveObjectTags(ctx context.Context, id string) error
 	GetSourceContent(ctx context.Context, id string) (io.ReadSeekCloser, error)
+	// SourceURL returns a URL which ffmpeg can read the source from.
+	SourceURL(ctx context.Context, id string, expiry time.Duration) (*url.URL, error)
 	DeleteSourceContent(ctx context.Context, id string) error
 }
 
 type EncodedObjectRepository interface {
-	Upload(ctx context.Context, mediaID string, localDir string) error
+	Ping(ctx context.Context) error
+	Upload(ctx context.Context, mediaID string, localDir string) ([]entity.IntegrityFile, error)
+	PutFile(ctx context.Context, mediaID string, fileName string, content []byte, contentType string) error
+	Stat(ctx context.Context, mediaID string, fileName string) (int64, error)
+	TotalSize(ctx context.Context) (int64, error)
 	GetObject(ctx context.Context, mediaID string, fileName string) (io.ReadSeekCloser, error)
+	PresignedGetObject(ctx context.Context, mediaID string, fileName string, expiry time.Duration) (*url.URL, error)
+}
+
+type RevocationRepository interface {
+	Ping(ctx context.Context) error
+	AddRevocation(ctx context.Context, revocation entity.TokenRevocation) error
+	RemoveRevocation(ctx context.Context, revocation entity.TokenRevocation) error
+	ListRevocations(ctx context.Context) iter.Seq2[entity.TokenRevocation, error]
+}
+
+type UsageRepository interface {
+	GetUsage(ctx context.Context, date string, hostname string) ([]entity.UsageRecord, error)
+	PutUsage(ctx context.Context, date string, hostname string, records []entity.UsageRecord) error
+	ListUsage(ctx context.Context, from string, to string) iter.Seq2[entity.UsageRecord, error]
+}
+
+type JobLogRepository interface {
+	PutJobLog(ctx context.Context, tenantID string, mediaID string, content []byte) error
+	GetJobLog(ctx context.Context, tenantID string, mediaID string) (io.ReadCloser, error)
 }
 
 type Encoder interface {
-	Encode(id string, path string, audioOnly bool) (string, error)
+	Check(ctx context.Context) []entity.HealthCheck
+	Version(ctx context.Context) (string, error)
+	Probe(ctx context.Context, path string) (entity.MediaInfo, error)
+	Encode(ctx context.Context, source entity.EncodeSource, profile entity.EncodingProfile) (string, error)
 	GetOutDirPrefix() string
+	RemoveOutput(outDir string) error
+	RemoveOrphanOutputs(active func(tenantID string, mediaID string) bool, retention time.Duration) (int, error)
+	RemoveOldLogs(active func(tenantID string, mediaID string) bool, retention time.Duration) (int, error)
+	LogFilePath(tenantID string, mediaID string) string
 }
 
 func NewUsecase(
 	cfg config.Config,
-	tokenIssuer TokenIssuer,
+	tenants []Tenant,
+	externalTokenVerifier ExternalTokenVerifier,
+	adminTokenVerifier AdminTokenVerifier,
 	encoder Encoder,
-	sourceRepo SourceRepository,
-	encodedRepo EncodedObjectRepository,
+	revocationRepo RevocationRepository,
+	usageRepo UsageRepository,
+	jobLogRepo JobLogRepository,
+	mediaGroups entity.MediaGroups,
 ) (*Usecase, error) {
+	tenantMap := make(map[string]Tenant, len(tenants))
+	tenantIDs := make([]string, 0, len(tenants))
+	for _, t := range tenants {
+		if _, ok := tenantMap[t.ID]; ok {
+			return nil, fmt.Errorf("duplicate tenant id: %s", t.ID)
+		}
+		tenantMap[t.ID] = t
+		tenantIDs = append(tenantIDs, t.ID)
+	}
+	if _, ok := tenantMap[entity.DefaultTenantID]; !ok {
+		return nil, fmt.Errorf("%s tenant is required", entity.DefaultTenantID)
+	}
 
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -60,12 +162,37 @@ func NewUsecase(
 	}
 
 	return &Usecase{
-		tokenIssuer:   tokenIssuer,
-		encoder:       encoder,
-		sourceRepo:    sourceRepo,
-		encodedRepo:   encodedRepo,
-		encodeQueue:   make(chan encodeRequest),
-		encodeTimeout: cfg.EncodeTimeout,
-		hostname:      hostname,
+		tenants:               tenantMap,
+		tenantIDs:             tenantIDs,
+		externalTokenVerifier: externalTokenVerifier,
+		adminTokenVerifier:    adminTokenVerifier,
+		encoder:               encoder,
+		revocationRepo:        revocationRepo,
+		revocations:           newRevocationCache(),
+		usageRepo:             usageRepo,
+		jobLogRepo:            jobLogRepo,
+		usage:                 newUsageRecorder(),
+		usageConfig:           cfg.UsageConfig,
+		mediaGroups:           mediaGroups,
+		representations:       newRepresentationCache(),
+		encodeQueue:           make(chan encodeRequest),
+		readinessConfig:       cfg.ReadinessConfig,
+		sourceConfig:          cfg.SourceConfig,
+		sourceConstraints: entity.SourceConstraints{
+			AllowedFormats: cfg.SourceConfig.AllowedFormats,
+			AllowedCodecs:  cfg.SourceConfig.AllowedCodecs,
+			MaxDuration:    cfg.SourceConfig.MaxDuration,
+			MaxWidth:       cfg.SourceConfig.MaxWidth,
+			MaxHeight:      cfg.SourceConfig.MaxHeight,
+			MinStreams:     cfg.SourceConfig.MinStreams,
+			MaxSize:        int64(min(cfg.MaxUploadSize, math.MaxInt64)),
+		},
+		janitorConfig:   cfg.JanitorConfig,
+		active:          newActiveJobs(),
+		encodeTimeout:   cfg.EncodeTimeout,
+		shutdownTimeout: cfg.ShutdownTimeout,
+		presignExpiry:   cfg.MediaDeliveryConfig.PresignExpiry,
+		userTokenConfig: cfg.UserTokenConfig,
+		hostname:        hostname,
 	}, nil
 }
@@ -1,21 +1,130 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/walnuts1018/mpeg-dash-encoder/domain"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/metrics"
+	"github.com/walnuts1018/mpeg-dash-encoder/util/random"
 )
 
+const userTokenIDLength = 32
+
 func (u *Usecase) CreateUserToken(
+	tenantID string,
+	subject string,
 	mediaIDs []string,
-) (string, error) {
-	return u.tokenIssuer.CreateUserToken(mediaIDs)
+	ttl time.Duration,
+	audience []string,
+	renditions entity.RenditionConstraints,
+) (string, entity.UserToken, error) {
+	switch {
+	case ttl < 0:
+		return "", entity.UserToken{}, fmt.Errorf("%w: ttl must be positive", domain.ErrInvalidTokenTTL)
+	case ttl == 0:
+		ttl = u.userTokenConfig.DefaultTTL
+	case ttl > u.userTokenConfig.MaxTTL:
+		return "", entity.UserToken{}, fmt.Errorf("%w: ttl must be less than %s", domain.ErrInvalidTokenTTL, u.userTokenConfig.MaxTTL)
+	}
+
+	if renditions.MaxHeight < 0 || renditions.MaxBandwidth < 0 {
+		return "", entity.UserToken{}, fmt.Errorf("%w: must not be negative", domain.ErrInvalidRenditionConstraints)
+	}
+
+	for _, grant := range mediaIDs {
+		if err := entity.ValidateMediaGrant(grant); err != nil {
+			return "", entity.UserToken{}, err
+		}
+	}
+
+	tenant, err := u.tenant(tenantID)
+	if err != nil {
+		return "", entity.UserToken{}, err
+	}
+
+	if u.userTokenConfig.Audience != "" {
+		if len(audience) == 0 {
+			audience = []string{u.userTokenConfig.Audience}
+		} else if !slices.Contains(audience, u.userTokenConfig.Audience) {
+			return "", entity.UserToken{}, fmt.Errorf("%w: audience must contain %s", domain.ErrInvalidTokenAudience, u.userTokenConfig.Audience)
+		}
+	}
+
+	id, err := random.String(userTokenIDLength, random.Alphanumeric)
+	if err != nil {
+		return "", entity.UserToken{}, fmt.Errorf("failed to generate token id: %w", err)
+	}
+
+	now := time.Now()
+	userToken := entity.UserToken{
+		ID:         id,
+		TenantID:   tenant.ID,
+		Subject:    subject,
+		MediaIDs:   mediaIDs,
+		Audience:   audience,
+		Renditions: renditions,
+		IssuedAt:   now,
+		NotBefore:  now,
+		ExpiresAt:  now.Add(ttl),
+	}
+
+	token, err := tenant.TokenIssuer.CreateUserToken(userToken)
+	if err != nil {
+		return "", entity.UserToken{}, err
+	}
+	return token, userToken, nil
 }
 
-func (u *Usecase) GetMediaIDsFromToken(token string) ([]string, error) {
-	ids, err := u.tokenIssuer.GetMediaIDsFromToken(token)
+// GetUserToken parses the token. The tenant is resolved from the issuer of the token.
+func (u *Usecase) GetUserToken(ctx context.Context, token string) (entity.UserToken, error) {
+	userToken, err := u.parseUserToken(ctx, token)
 	if err != nil {
-		return nil, errors.Join(err, domain.ErrInvalidToken)
+		metrics.TokenValidationFailures.WithLabelValues("invalid").Inc()
+		return entity.UserToken{}, err
+	}
+	if u.revocations.isRevoked(userToken) {
+		metrics.TokenValidationFailures.WithLabelValues("revoked").Inc()
+		return entity.UserToken{}, errors.Join(domain.ErrTokenRevoked, domain.ErrInvalidToken)
+	}
+	return userToken, nil
+}
+
+func (u *Usecase) parseUserToken(ctx context.Context, token string) (entity.UserToken, error) {
+	errs := make([]error, 0, len(u.tenantIDs)+2)
+	for _, id := range u.tenantIDs {
+		userToken, err := u.tenants[id].TokenIssuer.ParseUserToken(ctx, token)
+		if err == nil {
+			return userToken, nil
+		}
+		errs = append(errs, err)
+	}
+
+	userToken, err := u.externalTokenVerifier.ParseUserToken(ctx, token)
+	if err != nil {
+		return entity.UserToken{}, errors.Join(append(errs, err, domain.ErrInvalidToken)...)
+	}
+	if _, err := u.tenant(userToken.TenantID); err != nil {
+		return entity.UserToken{}, errors.Join(err, domain.ErrInvalidToken)
+	}
+	return userToken, nil
+}
+
+// GetJWKS returns the public keys of all tenants.
+func (u *Usecase) GetJWKS() (entity.JSONWebKeySet, error) {
+	jwks := entity.JSONWebKeySet{
+		Keys: make([]entity.JSONWebKey, 0),
+	}
+	for _, id := range u.tenantIDs {
+		tenantJWKS, err := u.tenants[id].TokenIssuer.JWKS()
+		if err != nil {
+			return entity.JSONWebKeySet{}, fmt.Errorf("failed to get jwks of tenant %s: %w", id, err)
+		}
+		jwks.Keys = append(jwks.Keys, tenantJWKS.Keys...)
 	}
-	return ids, nil
+	return jwks, nil
 }
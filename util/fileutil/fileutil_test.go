@@ -63,3 +63,86 @@ func TestCreateFileRecursive(t *testing.T) {
 		})
 	}
 }
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{
+		"dash.mpd":                 100,
+		"init0.m4s":                200,
+		filepath.Join("sub", "a"):  300,
+		filepath.Join("sub", "b"):  0,
+		filepath.Join("sub2", "c"): 50,
+	}
+	for name, size := range files {
+		f, err := CreateFileRecursive(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("CreateFileRecursive() error = %v", err)
+		}
+		if _, err := f.Write(make([]byte, size)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		f.Close()
+	}
+
+	got, err := DirSize(dir)
+	if err != nil {
+		t.Fatalf("DirSize() error = %v", err)
+	}
+	if got != 650 {
+		t.Errorf("DirSize() = %v, want %v", got, 650)
+	}
+
+	if _, err := DirSize(filepath.Join(dir, "not-exist")); err == nil {
+		t.Errorf("DirSize() error = nil, want error")
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := CheckWritable(dir); err != nil {
+		t.Errorf("CheckWritable() error = %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("os.ReadDir() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("CheckWritable() left %d files", len(entries))
+	}
+
+	if err := CheckWritable(filepath.Join(dir, "not-exist")); err == nil {
+		t.Errorf("CheckWritable() error = nil, want error")
+	}
+}
+
+func TestFreeSpace(t *testing.T) {
+	got, err := FreeSpace(t.TempDir())
+	if err != nil {
+		t.Fatalf("FreeSpace() error = %v", err)
+	}
+	if got == 0 {
+		t.Errorf("FreeSpace() = 0, want > 0")
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	gotMD5, gotSHA256, err := Checksums(path)
+	if err != nil {
+		t.Fatalf("Checksums() error = %v", err)
+	}
+	if want := "5d41402abc4b2a76b9719d911017c592"; gotMD5 != want {
+		t.Errorf("Checksums() md5 = %v, want %v", gotMD5, want)
+	}
+	if want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; gotSHA256 != want {
+		t.Errorf("Checksums() sha256 = %v, want %v", gotSHA256, want)
+	}
+
+	if _, _, err := Checksums(filepath.Join(t.TempDir(), "not-exist")); err == nil {
+		t.Errorf("Checksums() error = nil, want error")
+	}
+}
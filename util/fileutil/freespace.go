@@ -0,0 +1,6 @@
+package fileutil
+
+import "errors"
+
+// ErrFreeSpaceNotSupported is returned by FreeSpace on the platforms where the free space cannot be checked.
+var ErrFreeSpaceNotSupported = errors.New("free space is not supported on this platform")
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package fileutil
+
+// FreeSpace returns ErrFreeSpaceNotSupported.
+func FreeSpace(dir string) (uint64, error) {
+	return 0, ErrFreeSpaceNotSupported
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package fileutil
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// FreeSpace returns the bytes available to unprivileged users in the file system of dir.
+func FreeSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("failed to statfs %s: %w", dir, err)
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil //nolint:gosec
+}
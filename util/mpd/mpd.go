@@ -0,0 +1,436 @@
+package mpd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const Ext = ".mpd"
+
+// FileName is the manifest file name written by the encoder.
+const FileName = "dash" + Ext
+
+// IsManifest reports whether the file refers to other files, which must not be visible before them.
+func IsManifest(fileName string) bool {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case Ext, ".m3u8":
+		return true
+	default:
+		return false
+	}
+}
+
+// 元のバイト列を極力保ったまま書き換えるため、encoding/xmlでは位置の特定だけ行い、置換は元のバイト列に対して行う
+type edit struct {
+	from int64
+	to   int64
+	text []byte
+}
+
+type startElement struct {
+	xml.StartElement
+	from        int64
+	to          int64
+	selfClosing bool
+}
+
+type walker struct {
+	src   []byte
+	edits []edit
+
+	onStart    func(w *walker, e startElement) error
+	onEnd      func(w *walker, e startElement, end int64) error
+	onCharData func(w *walker, parent startElement, from, to int64, data xml.CharData) error
+}
+
+func (w *walker) walk() error {
+	d := xml.NewDecoder(bytes.NewReader(w.src))
+	stack := make([]startElement, 0, 8)
+	for {
+		from := d.InputOffset()
+		tok, err := d.RawToken()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if len(stack) > 0 {
+					return fmt.Errorf("failed to parse mpd: element <%s> is not closed", qualifiedName(stack[len(stack)-1].Name))
+				}
+				return nil
+			}
+			return fmt.Errorf("failed to parse mpd: %w", err)
+		}
+		to := d.InputOffset()
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := startElement{
+				StartElement: t.Copy(),
+				from:         from,
+				to:           to,
+				selfClosing:  bytes.HasSuffix(w.src[from:to], []byte("/>")),
+			}
+			stack = append(stack, e)
+			if w.onStart != nil {
+				if err := w.onStart(w, e); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return errors.New("failed to parse mpd: unexpected end element")
+			}
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if e.Name != t.Name {
+				return fmt.Errorf("failed to parse mpd: element <%s> closed by </%s>", qualifiedName(e.Name), qualifiedName(t.Name))
+			}
+			if w.onEnd != nil {
+				if err := w.onEnd(w, e, to); err != nil {
+					return err
+				}
+			}
+		case xml.CharData:
+			if w.onCharData != nil && len(stack) > 0 {
+				if err := w.onCharData(w, stack[len(stack)-1], from, to, t); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (w *walker) replace(from, to int64, text []byte) {
+	w.edits = append(w.edits, edit{from: from, to: to, text: text})
+}
+
+func (w *walker) result() []byte {
+	edits := slices.Clone(w.edits)
+	slices.SortFunc(edits, func(a, b edit) int {
+		return int(a.from - b.from)
+	})
+
+	var buf bytes.Buffer
+	var cursor int64
+	for _, e := range edits {
+		if e.from < cursor {
+			// 既に削除された範囲の内側に対する編集
+			continue
+		}
+		buf.Write(w.src[cursor:e.from])
+		buf.Write(e.text)
+		cursor = e.to
+	}
+	buf.Write(w.src[cursor:])
+	return buf.Bytes()
+}
+
+func renderStartElement(e startElement) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('<')
+	buf.WriteString(qualifiedName(e.Name))
+	for _, attr := range e.Attr {
+		buf.WriteByte(' ')
+		buf.WriteString(qualifiedName(attr.Name))
+		buf.WriteString(`="`)
+		//nolint:errcheck
+		xml.EscapeText(&buf, []byte(attr.Value))
+		buf.WriteByte('"')
+	}
+	if e.selfClosing {
+		buf.WriteString("/>")
+	} else {
+		buf.WriteByte('>')
+	}
+	return buf.Bytes()
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// AppendQuery appends query to every segment and base URL in the manifest,
+// so that players which cannot set headers keep sending it with each request.
+func AppendQuery(manifest []byte, query url.Values) ([]byte, error) {
+	if len(query) == 0 {
+		return manifest, nil
+	}
+	encoded := query.Encode()
+
+	w := &walker{
+		src: manifest,
+		onStart: func(w *walker, e startElement) error {
+			if e.Name.Local != "SegmentTemplate" {
+				return nil
+			}
+			changed := false
+			for i, attr := range e.Attr {
+				switch attr.Name.Local {
+				case "media", "initialization", "index":
+					e.Attr[i].Value = appendRawQuery(attr.Value, encoded)
+					changed = true
+				}
+			}
+			if changed {
+				w.replace(e.from, e.to, renderStartElement(e))
+			}
+			return nil
+		},
+		onCharData: func(w *walker, parent startElement, from, to int64, data xml.CharData) error {
+			if parent.Name.Local != "BaseURL" {
+				return nil
+			}
+			text := strings.TrimSpace(string(data))
+			if text == "" {
+				return nil
+			}
+			var buf bytes.Buffer
+			//nolint:errcheck
+			xml.EscapeText(&buf, []byte(appendRawQuery(text, encoded)))
+			w.replace(from, to, buf.Bytes())
+			return nil
+		},
+	}
+	if err := w.walk(); err != nil {
+		return nil, err
+	}
+	return w.result(), nil
+}
+
+func appendRawQuery(u string, rawQuery string) string {
+	if strings.Contains(u, "?") {
+		return u + "&" + rawQuery
+	}
+	return u + "?" + rawQuery
+}
+
+// Representation is a Representation element with attributes inherited from its AdaptationSet.
+type Representation struct {
+	ID          string
+	ContentType string // "video", "audio", "text" ...
+	Bandwidth   int
+	Width       int
+	Height      int
+
+	initialization string
+	media          string
+}
+
+func (r Representation) IsVideo() bool {
+	return r.ContentType == "video"
+}
+
+func (r Representation) IsAudio() bool {
+	return r.ContentType == "audio"
+}
+
+// MatchSegment reports whether fileName is the initialization or a media segment of the representation.
+func (r Representation) MatchSegment(fileName string) bool {
+	for _, tmpl := range []string{r.initialization, r.media} {
+		if tmpl == "" {
+			continue
+		}
+		re, err := r.templateRegexp(tmpl)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(fileName) {
+			return true
+		}
+	}
+	return false
+}
+
+var templateIdentifier = regexp.MustCompile(`\$(RepresentationID|Number|Time|Bandwidth|ext|SubNumber)(%0\d+d)?\$|\$\$`)
+
+func (r Representation) templateRegexp(tmpl string) (*regexp.Regexp, error) {
+	// クエリ部分は比較対象外
+	tmpl, _, _ = strings.Cut(tmpl, "?")
+
+	var b strings.Builder
+	b.WriteByte('^')
+	last := 0
+	for _, m := range templateIdentifier.FindAllStringSubmatchIndex(tmpl, -1) {
+		b.WriteString(regexp.QuoteMeta(tmpl[last:m[0]]))
+		last = m[1]
+		if m[2] < 0 {
+			b.WriteString(`\$`) // "$$"
+			continue
+		}
+		switch tmpl[m[2]:m[3]] {
+		case "RepresentationID":
+			b.WriteString(regexp.QuoteMeta(r.ID))
+		case "Bandwidth":
+			b.WriteString(strconv.Itoa(r.Bandwidth))
+		case "ext":
+			b.WriteString(`[0-9A-Za-z]+`)
+		default:
+			b.WriteString(`[0-9]+`)
+		}
+	}
+	b.WriteString(regexp.QuoteMeta(tmpl[last:]))
+	b.WriteByte('$')
+	return regexp.Compile(b.String())
+}
+
+type representationParser struct {
+	adaptationSet      *startElement
+	adaptationTemplate *startElement
+	representation     *Representation
+	// 現在のAdaptationSet内のRepresentationの数
+	representationCount int
+}
+
+func (p *representationParser) onStart(e startElement) {
+	switch e.Name.Local {
+	case "AdaptationSet":
+		p.adaptationSet = &e
+		p.adaptationTemplate = nil
+		p.representationCount = 0
+	case "Representation":
+		r := newRepresentation(p.adaptationSet, e)
+		if p.adaptationTemplate != nil {
+			r.initialization = attr(*p.adaptationTemplate, "initialization")
+			r.media = attr(*p.adaptationTemplate, "media")
+		}
+		p.representation = &r
+	case "SegmentTemplate":
+		switch {
+		case p.representation != nil:
+			if v := attr(e, "initialization"); v != "" {
+				p.representation.initialization = v
+			}
+			if v := attr(e, "media"); v != "" {
+				p.representation.media = v
+			}
+		case p.adaptationSet != nil:
+			p.adaptationTemplate = &e
+		}
+	}
+}
+
+func (p *representationParser) onEnd(e startElement) (Representation, bool) {
+	switch e.Name.Local {
+	case "AdaptationSet":
+		p.adaptationSet = nil
+	case "Representation":
+		r := *p.representation
+		p.representation = nil
+		p.representationCount++
+		return r, true
+	}
+	return Representation{}, false
+}
+
+func newRepresentation(adaptationSet *startElement, e startElement) Representation {
+	// Representationに無い属性はAdaptationSetから引き継ぐ
+	get := func(name string) string {
+		if v := attr(e, name); v != "" || adaptationSet == nil {
+			return v
+		}
+		return attr(*adaptationSet, name)
+	}
+
+	r := Representation{
+		ID:          attr(e, "id"),
+		ContentType: get("contentType"),
+	}
+	if r.ContentType == "" {
+		r.ContentType, _, _ = strings.Cut(get("mimeType"), "/")
+	}
+	r.Bandwidth, _ = strconv.Atoi(get("bandwidth"))
+	r.Width, _ = strconv.Atoi(get("width"))
+	r.Height, _ = strconv.Atoi(get("height"))
+	return r
+}
+
+func attr(e startElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Representations returns every representation in the manifest.
+func Representations(manifest []byte) ([]Representation, error) {
+	var p representationParser
+	representations := make([]Representation, 0, 4)
+	w := &walker{
+		src: manifest,
+		onStart: func(w *walker, e startElement) error {
+			p.onStart(e)
+			return nil
+		},
+		onEnd: func(w *walker, e startElement, end int64) error {
+			if r, ok := p.onEnd(e); ok {
+				representations = append(representations, r)
+			}
+			return nil
+		},
+	}
+	if err := w.walk(); err != nil {
+		return nil, err
+	}
+	return representations, nil
+}
+
+// FilterRepresentations removes representations for which keep returns false,
+// and adaptation sets which have no representations left.
+func FilterRepresentations(manifest []byte, keep func(Representation) bool) ([]byte, error) {
+	var p representationParser
+	var kept int
+	w := &walker{
+		src: manifest,
+		onStart: func(w *walker, e startElement) error {
+			if e.Name.Local == "AdaptationSet" {
+				kept = 0
+			}
+			p.onStart(e)
+			return nil
+		},
+		onEnd: func(w *walker, e startElement, end int64) error {
+			if r, ok := p.onEnd(e); ok {
+				if keep(r) {
+					kept++
+				} else {
+					w.remove(e.from, end)
+				}
+				return nil
+			}
+			if e.Name.Local == "AdaptationSet" && kept == 0 && p.representationCount > 0 {
+				w.remove(e.from, end)
+			}
+			return nil
+		},
+	}
+	if err := w.walk(); err != nil {
+		return nil, err
+	}
+	return w.result(), nil
+}
+
+// remove removes the range together with the indentation and line break before it.
+func (w *walker) remove(from, to int64) {
+	for from > 0 && (w.src[from-1] == ' ' || w.src[from-1] == '\t') {
+		from--
+	}
+	if from > 0 && w.src[from-1] == '\n' {
+		from--
+		if from > 0 && w.src[from-1] == '\r' {
+			from--
+		}
+	}
+	w.replace(from, to, nil)
+}
@@ -0,0 +1,194 @@
+package mpd
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		want     bool
+	}{
+		{name: "dash", fileName: "dash.mpd", want: true},
+		{name: "upper case", fileName: "DASH.MPD", want: true},
+		{name: "hls", fileName: "master.m3u8", want: true},
+		{name: "segment", fileName: "chunk0-v1-00001.m4s", want: false},
+		{name: "integrity manifest", fileName: "manifest.json", want: false},
+		{name: "no ext", fileName: "mpd", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsManifest(tt.fileName))
+		})
+	}
+}
+
+func TestAppendQuery(t *testing.T) {
+	manifest, err := os.ReadFile("testdata/dash.mpd")
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		query    url.Values
+		want     string
+	}{
+		{
+			name:     "segment template",
+			manifest: `<SegmentTemplate timescale="48000" initialization="init$RepresentationID$.$ext$" media="chunk$RepresentationID$-$Number%05d$.$ext$" startNumber="1"></SegmentTemplate>`,
+			query:    url.Values{"token": {"a.b.c"}},
+			want:     `<SegmentTemplate timescale="48000" initialization="init$RepresentationID$.$ext$?token=a.b.c" media="chunk$RepresentationID$-$Number%05d$.$ext$?token=a.b.c" startNumber="1"></SegmentTemplate>`,
+		},
+		{
+			name:     "self closing with existing query",
+			manifest: `<SegmentTemplate media="chunk.m4s?x=1"/>`,
+			query:    url.Values{"token": {"a+b"}},
+			want:     `<SegmentTemplate media="chunk.m4s?x=1&amp;token=a%2Bb"/>`,
+		},
+		{
+			name:     "base url",
+			manifest: "<MPD><BaseURL> https://example.com/media/ </BaseURL></MPD>",
+			query:    url.Values{"token": {"t"}},
+			want:     "<MPD><BaseURL>https://example.com/media/?token=t</BaseURL></MPD>",
+		},
+		{
+			name:     "empty query",
+			manifest: `<SegmentTemplate media="chunk.m4s"/>`,
+			query:    url.Values{},
+			want:     `<SegmentTemplate media="chunk.m4s"/>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AppendQuery([]byte(tt.manifest), tt.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+
+	t.Run("ffmpeg output", func(t *testing.T) {
+		got, err := AppendQuery(manifest, url.Values{"token": {"a.b.c"}})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, strings.Count(string(got), `media="chunk$RepresentationID$-$Number%05d$.$ext$?token=a.b.c"`))
+		assert.Equal(t, 4, strings.Count(string(got), `initialization="init$RepresentationID$.$ext$?token=a.b.c"`))
+		// 書き換え対象以外はそのまま
+		assert.Equal(t, strings.Count(string(manifest), "\n"), strings.Count(string(got), "\n"))
+		assert.Contains(t, string(got), `xsi:schemaLocation="urn:mpeg:DASH:schema:MPD:2011`)
+	})
+
+	t.Run("invalid xml", func(t *testing.T) {
+		_, err := AppendQuery([]byte("<MPD><Period></MPD>"), url.Values{"token": {"t"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestRepresentations(t *testing.T) {
+	manifest, err := os.ReadFile("testdata/dash.mpd")
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+
+	got, err := Representations(manifest)
+	assert.NoError(t, err)
+	if assert.Len(t, got, 4) {
+		assert.Equal(t, "3", got[0].ID)
+		assert.True(t, got[0].IsAudio())
+		assert.Equal(t, 128000, got[0].Bandwidth)
+
+		assert.Equal(t, "2", got[3].ID)
+		assert.True(t, got[3].IsVideo())
+		assert.Equal(t, 1080, got[3].Height)
+		assert.Equal(t, 7800000, got[3].Bandwidth)
+	}
+
+	t.Run("inherit from adaptation set", func(t *testing.T) {
+		got, err := Representations([]byte(`<MPD><Period><AdaptationSet mimeType="video/mp4" height="480"><SegmentTemplate media="seg-$RepresentationID$-$Time$.m4s"/><Representation id="v" bandwidth="1000"/></AdaptationSet></Period></MPD>`))
+		assert.NoError(t, err)
+		if assert.Len(t, got, 1) {
+			assert.True(t, got[0].IsVideo())
+			assert.Equal(t, 480, got[0].Height)
+			assert.True(t, got[0].MatchSegment("seg-v-12345.m4s"))
+		}
+	})
+}
+
+func TestRepresentation_MatchSegment(t *testing.T) {
+	manifest, err := os.ReadFile("testdata/dash.mpd")
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+	representations, err := Representations(manifest)
+	if err != nil {
+		t.Fatalf("failed to parse testdata: %v", err)
+	}
+	video1080 := representations[3]
+
+	tests := []struct {
+		name     string
+		fileName string
+		want     bool
+	}{
+		{name: "init", fileName: "init2.m4s", want: true},
+		{name: "chunk", fileName: "chunk2-00001.m4s", want: true},
+		{name: "other init", fileName: "init1.m4s", want: false},
+		{name: "other chunk", fileName: "chunk1-00001.m4s", want: false},
+		{name: "id prefix", fileName: "chunk20-00001.m4s", want: false},
+		{name: "manifest", fileName: "dash.mpd", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, video1080.MatchSegment(tt.fileName))
+		})
+	}
+}
+
+func TestFilterRepresentations(t *testing.T) {
+	manifest, err := os.ReadFile("testdata/dash.mpd")
+	if err != nil {
+		t.Fatalf("failed to read testdata: %v", err)
+	}
+
+	t.Run("max height", func(t *testing.T) {
+		got, err := FilterRepresentations(manifest, func(r Representation) bool {
+			return !r.IsVideo() || r.Height <= 720
+		})
+		assert.NoError(t, err)
+
+		representations, err := Representations(got)
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(representations))
+		for _, r := range representations {
+			ids = append(ids, r.ID)
+		}
+		assert.Equal(t, []string{"3", "0", "1"}, ids)
+		assert.NotContains(t, string(got), `height="1080"`)
+		// 空行を残さない
+		assert.NotContains(t, string(got), "\n\n")
+	})
+
+	t.Run("audio only", func(t *testing.T) {
+		got, err := FilterRepresentations(manifest, func(r Representation) bool {
+			return r.IsAudio()
+		})
+		assert.NoError(t, err)
+		assert.NotContains(t, string(got), `contentType="video"`)
+		assert.Contains(t, string(got), `contentType="audio"`)
+		assert.Equal(t, 1, strings.Count(string(got), "<AdaptationSet"))
+	})
+
+	t.Run("keep all", func(t *testing.T) {
+		got, err := FilterRepresentations(manifest, func(r Representation) bool {
+			return true
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, string(manifest), string(got))
+	})
+}
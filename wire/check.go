@@ -1,13 +1,26 @@
 package wire
 
 import (
+	"github.com/walnuts1018/mpeg-dash-encoder/infra/adminkey"
 	"github.com/walnuts1018/mpeg-dash-encoder/infra/ffmpeg"
 	"github.com/walnuts1018/mpeg-dash-encoder/infra/jwt"
+	"github.com/walnuts1018/mpeg-dash-encoder/infra/local"
 	"github.com/walnuts1018/mpeg-dash-encoder/infra/minio"
 	"github.com/walnuts1018/mpeg-dash-encoder/usecase"
 )
 
 var _ usecase.TokenIssuer = &jwt.Manager{}
+var _ usecase.ExternalTokenVerifier = &jwt.ExternalVerifier{}
+var _ usecase.AdminKeyStore = &adminkey.KeyStore{}
+var _ usecase.AdminTokenVerifier = &jwt.OIDCVerifier{}
 var _ usecase.Encoder = &ffmpeg.FFmpeg{}
 var _ usecase.SourceRepository = &minio.SourceClient{}
 var _ usecase.EncodedObjectRepository = &minio.EncodedObjectClient{}
+var _ usecase.RevocationRepository = &minio.RevocationClient{}
+var _ usecase.UsageRepository = &minio.UsageClient{}
+var _ usecase.JobLogRepository = &minio.JobLogClient{}
+var _ usecase.SourceRepository = &local.SourceDir{}
+var _ usecase.EncodedObjectRepository = &local.EncodedObjectDir{}
+var _ usecase.RevocationRepository = &local.RevocationDir{}
+var _ usecase.UsageRepository = &local.UsageDir{}
+var _ usecase.JobLogRepository = &local.JobLogDir{}
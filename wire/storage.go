@@ -0,0 +1,90 @@
+package wire
+
+import (
+	"fmt"
+
+	miniogo "github.com/minio/minio-go/v7"
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/infra/local"
+	"github.com/walnuts1018/mpeg-dash-encoder/infra/minio"
+	"github.com/walnuts1018/mpeg-dash-encoder/usecase"
+)
+
+// storage creates the repositories of the backend selected by STORAGE_BACKEND.
+type storage struct {
+	backend      config.StorageBackend
+	localDir     string
+	uploadConfig config.MinIOUploadConfig
+	client       *miniogo.Client
+	publicClient *minio.PublicClient
+}
+
+func newStorage(cfg config.Config) (*storage, error) {
+	s := &storage{
+		backend:      cfg.StorageBackend,
+		localDir:     cfg.LocalStorageDir,
+		uploadConfig: cfg.MinIOUploadConfig,
+	}
+	if s.backend == config.StorageBackendLocal {
+		return s, nil
+	}
+
+	client, err := minio.NewMinIOClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	publicClient, err := minio.NewMinIOPublicClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	s.publicClient = publicClient
+	return s, nil
+}
+
+func (s *storage) sourceRepository(bucketName config.SourceClientBucketName, prefix string) (usecase.SourceRepository, error) {
+	if s.backend == config.StorageBackendLocal {
+		return local.NewSourceDir(s.localDir, bucketName, prefix)
+	}
+	return minio.NewSourceClient(bucketName, prefix, s.client), nil
+}
+
+func (s *storage) encodedObjectRepository(bucketName config.EncodedObjectBucketName, prefix string) (usecase.EncodedObjectRepository, error) {
+	if s.backend == config.StorageBackendLocal {
+		return local.NewEncodedObjectDir(s.localDir, bucketName, prefix)
+	}
+	return minio.NewEncodedObjectClient(bucketName, prefix, s.uploadConfig, s.client, s.publicClient), nil
+}
+
+func newRevocationRepository(s *storage, bucketName config.SystemBucketName) (usecase.RevocationRepository, error) {
+	if s.backend == config.StorageBackendLocal {
+		repo, err := local.NewRevocationDir(s.localDir, bucketName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create revocation repository: %w", err)
+		}
+		return repo, nil
+	}
+	return minio.NewRevocationClient(bucketName, s.client), nil
+}
+
+func newUsageRepository(s *storage, bucketName config.SystemBucketName) (usecase.UsageRepository, error) {
+	if s.backend == config.StorageBackendLocal {
+		repo, err := local.NewUsageDir(s.localDir, bucketName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create usage repository: %w", err)
+		}
+		return repo, nil
+	}
+	return minio.NewUsageClient(bucketName, s.client), nil
+}
+
+func newJobLogRepository(s *storage, bucketName config.SystemBucketName) (usecase.JobLogRepository, error) {
+	if s.backend == config.StorageBackendLocal {
+		repo, err := local.NewJobLogDir(s.localDir, bucketName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create job log repository: %w", err)
+		}
+		return repo, nil
+	}
+	return minio.NewJobLogClient(bucketName, s.client), nil
+}
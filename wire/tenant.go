@@ -0,0 +1,55 @@
+package wire
+
+import (
+	"fmt"
+
+	"github.com/walnuts1018/mpeg-dash-encoder/config"
+	"github.com/walnuts1018/mpeg-dash-encoder/domain/entity"
+	"github.com/walnuts1018/mpeg-dash-encoder/infra/adminkey"
+	"github.com/walnuts1018/mpeg-dash-encoder/infra/jwt"
+	"github.com/walnuts1018/mpeg-dash-encoder/usecase"
+)
+
+func newTenants(
+	cfg config.Config,
+	userTokenConfig config.UserTokenConfig,
+	store *storage,
+) ([]usecase.Tenant, error) {
+	tenantConfigs, err := config.LoadTenants(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenants: %w", err)
+	}
+
+	tenants := make([]usecase.Tenant, 0, len(tenantConfigs))
+	for _, t := range tenantConfigs {
+		manager, err := jwt.NewTenantManager(t.ID, t.JWTSigningKey, t.JWTKeysFile, userTokenConfig)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+		keyStore, err := adminkey.NewKeyStore(t.AdminKeysFile, t.AdminToken)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+		sourceRepo, err := store.sourceRepository(t.SourceBucket, t.SourcePrefix)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+		encodedRepo, err := store.encodedObjectRepository(t.OutputBucket, t.OutputPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+
+		tenants = append(tenants, usecase.Tenant{
+			Tenant: entity.Tenant{
+				ID:      t.ID,
+				Profile: t.Profile,
+				Quota:   t.Quota,
+			},
+			TokenIssuer:   manager,
+			AdminKeyStore: keyStore,
+			SourceRepo:    sourceRepo,
+			EncodedRepo:   encodedRepo,
+		})
+	}
+	return tenants, nil
+}
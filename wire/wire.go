@@ -11,7 +11,7 @@ import (
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
 	"github.com/walnuts1018/mpeg-dash-encoder/infra/ffmpeg"
 	"github.com/walnuts1018/mpeg-dash-encoder/infra/jwt"
-	"github.com/walnuts1018/mpeg-dash-encoder/infra/minio"
+	"github.com/walnuts1018/mpeg-dash-encoder/infra/mediagroup"
 	"github.com/walnuts1018/mpeg-dash-encoder/router"
 	"github.com/walnuts1018/mpeg-dash-encoder/router/handler"
 	"github.com/walnuts1018/mpeg-dash-encoder/router/middleware"
@@ -24,11 +24,15 @@ func CreateUsecase(
 ) (*usecase.Usecase, error) {
 	wire.Build(
 		UsecaseConfigSet,
-		jwtSet,
+		externalJWTSet,
+		oidcSet,
 		ffmpegSet,
-		minio.NewMinIOClient,
-		minioSourceClientSet,
-		minioEncodedObjectClientSet,
+		newStorage,
+		newTenants,
+		newRevocationRepository,
+		newUsageRepository,
+		newJobLogRepository,
+		mediagroup.NewMediaGroups,
 		usecase.NewUsecase,
 	)
 	return &usecase.Usecase{}, nil
@@ -40,7 +44,6 @@ func CreateRouter(
 	usecase *usecase.Usecase,
 ) (*gin.Engine, error) {
 	wire.Build(
-		RouterConfigSet,
 		middleware.NewMiddleware,
 		handler.NewHandler,
 		router.NewRouter,
@@ -49,19 +52,27 @@ func CreateRouter(
 	return &gin.Engine{}, nil
 }
 
-var jwtSet = wire.NewSet(
-	jwt.NewManager,
-	wire.Bind(new(usecase.TokenIssuer), new(*jwt.Manager)),
-)
+func CreateMetricsRouter(
+	ctx context.Context,
+	cfg config.Config,
+	usecase *usecase.Usecase,
+) (*gin.Engine, error) {
+	wire.Build(
+		handler.NewHandler,
+		router.NewMetricsRouter,
+	)
+
+	return &gin.Engine{}, nil
+}
 
-var minioSourceClientSet = wire.NewSet(
-	minio.NewSourceClient,
-	wire.Bind(new(usecase.SourceRepository), new(*minio.SourceClient)),
+var externalJWTSet = wire.NewSet(
+	jwt.NewExternalVerifier,
+	wire.Bind(new(usecase.ExternalTokenVerifier), new(*jwt.ExternalVerifier)),
 )
 
-var minioEncodedObjectClientSet = wire.NewSet(
-	minio.NewEncodedObjectClient,
-	wire.Bind(new(usecase.EncodedObjectRepository), new(*minio.EncodedObjectClient)),
+var oidcSet = wire.NewSet(
+	jwt.NewOIDCVerifier,
+	wire.Bind(new(usecase.AdminTokenVerifier), new(*jwt.OIDCVerifier)),
 )
 
 var ffmpegSet = wire.NewSet(
@@ -70,12 +81,10 @@ var ffmpegSet = wire.NewSet(
 )
 
 var UsecaseConfigSet = wire.FieldsOf(new(config.Config),
-	"JWTSigningKey",
-	"MinIOSourceUploadBucket",
-	"MinIOOutputBucket",
+	"TrustedIssuersFile",
+	"AdminOIDCConfig",
+	"MediaGroupsFile",
+	"UserTokenConfig",
+	"MinIOSystemBucket",
 	"FFmpegConfig",
 )
-
-var RouterConfigSet = wire.FieldsOf(new(config.Config),
-	"AdminToken",
-)
@@ -13,7 +13,7 @@ import (
 	"github.com/walnuts1018/mpeg-dash-encoder/config"
 	"github.com/walnuts1018/mpeg-dash-encoder/infra/ffmpeg"
 	"github.com/walnuts1018/mpeg-dash-encoder/infra/jwt"
-	"github.com/walnuts1018/mpeg-dash-encoder/infra/minio"
+	"github.com/walnuts1018/mpeg-dash-encoder/infra/mediagroup"
 	"github.com/walnuts1018/mpeg-dash-encoder/router"
 	"github.com/walnuts1018/mpeg-dash-encoder/router/handler"
 	"github.com/walnuts1018/mpeg-dash-encoder/router/middleware"
@@ -23,22 +23,49 @@ import (
 // Injectors from wire.go:
 
 func CreateUsecase(ctx context.Context, cfg config.Config) (*usecase.Usecase, error) {
-	jwtSigningKey := cfg.JWTSigningKey
-	manager := jwt.NewManager(jwtSigningKey)
+	trustedIssuersFile := cfg.TrustedIssuersFile
+	userTokenConfig := cfg.UserTokenConfig
+	externalVerifier, err := jwt.NewExternalVerifier(trustedIssuersFile, userTokenConfig)
+	if err != nil {
+		return nil, err
+	}
+	adminOIDCConfig := cfg.AdminOIDCConfig
+	oidcVerifier, err := jwt.NewOIDCVerifier(adminOIDCConfig, userTokenConfig)
+	if err != nil {
+		return nil, err
+	}
 	fFmpegConfig := cfg.FFmpegConfig
 	fFmpeg, err := ffmpeg.NewFFMPEG(fFmpegConfig)
 	if err != nil {
 		return nil, err
 	}
-	sourceClientBucketName := cfg.MinIOSourceUploadBucket
-	client, err := minio.NewMinIOClient(cfg)
+	wireStorage, err := newStorage(cfg)
+	if err != nil {
+		return nil, err
+	}
+	v, err := newTenants(cfg, userTokenConfig, wireStorage)
+	if err != nil {
+		return nil, err
+	}
+	systemBucketName := cfg.MinIOSystemBucket
+	revocationRepository, err := newRevocationRepository(wireStorage, systemBucketName)
+	if err != nil {
+		return nil, err
+	}
+	usageRepository, err := newUsageRepository(wireStorage, systemBucketName)
+	if err != nil {
+		return nil, err
+	}
+	jobLogRepository, err := newJobLogRepository(wireStorage, systemBucketName)
 	if err != nil {
 		return nil, err
 	}
-	sourceClient := minio.NewSourceClient(sourceClientBucketName, client)
-	encodedObjectBucketName := cfg.MinIOOutputBucket
-	encodedObjectClient := minio.NewEncodedObjectClient(encodedObjectBucketName, client)
-	usecaseUsecase, err := usecase.NewUsecase(cfg, manager, fFmpeg, sourceClient, encodedObjectClient)
+	mediaGroupsFile := cfg.MediaGroupsFile
+	mediaGroups, err := mediagroup.NewMediaGroups(mediaGroupsFile)
+	if err != nil {
+		return nil, err
+	}
+	usecaseUsecase, err := usecase.NewUsecase(cfg, v, externalVerifier, oidcVerifier, fFmpeg, revocationRepository, usageRepository, jobLogRepository, mediaGroups)
 	if err != nil {
 		return nil, err
 	}
@@ -50,8 +77,7 @@ func CreateRouter(ctx context.Context, cfg config.Config, usecase2 *usecase.Usec
 	if err != nil {
 		return nil, err
 	}
-	adminToken := cfg.AdminToken
-	middlewareMiddleware := middleware.NewMiddleware(adminToken, usecase2)
+	middlewareMiddleware := middleware.NewMiddleware(usecase2)
 	engine, err := router.NewRouter(cfg, handlerHandler, middlewareMiddleware)
 	if err != nil {
 		return nil, err
@@ -59,23 +85,31 @@ func CreateRouter(ctx context.Context, cfg config.Config, usecase2 *usecase.Usec
 	return engine, nil
 }
 
-// wire.go:
+func CreateMetricsRouter(ctx context.Context, cfg config.Config, usecase2 *usecase.Usecase) (*gin.Engine, error) {
+	handlerHandler, err := handler.NewHandler(cfg, usecase2)
+	if err != nil {
+		return nil, err
+	}
+	engine, err := router.NewMetricsRouter(cfg, handlerHandler)
+	if err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
 
-var jwtSet = wire.NewSet(jwt.NewManager, wire.Bind(new(usecase.TokenIssuer), new(*jwt.Manager)))
+// wire.go:
 
-var minioSourceClientSet = wire.NewSet(minio.NewSourceClient, wire.Bind(new(usecase.SourceRepository), new(*minio.SourceClient)))
+var externalJWTSet = wire.NewSet(jwt.NewExternalVerifier, wire.Bind(new(usecase.ExternalTokenVerifier), new(*jwt.ExternalVerifier)))
 
-var minioEncodedObjectClientSet = wire.NewSet(minio.NewEncodedObjectClient, wire.Bind(new(usecase.EncodedObjectRepository), new(*minio.EncodedObjectClient)))
+var oidcSet = wire.NewSet(jwt.NewOIDCVerifier, wire.Bind(new(usecase.AdminTokenVerifier), new(*jwt.OIDCVerifier)))
 
 var ffmpegSet = wire.NewSet(ffmpeg.NewFFMPEG, wire.Bind(new(usecase.Encoder), new(*ffmpeg.FFmpeg)))
 
 var UsecaseConfigSet = wire.FieldsOf(new(config.Config),
-	"JWTSigningKey",
-	"MinIOSourceUploadBucket",
-	"MinIOOutputBucket",
+	"TrustedIssuersFile",
+	"AdminOIDCConfig",
+	"MediaGroupsFile",
+	"UserTokenConfig",
+	"MinIOSystemBucket",
 	"FFmpegConfig",
 )
-
-var RouterConfigSet = wire.FieldsOf(new(config.Config),
-	"AdminToken",
-)